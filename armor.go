@@ -0,0 +1,289 @@
+package msign
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// attached signatures embed the message and its signature in one armored file:
+//
+//	-----BEGIN MSIGN SIGNED MESSAGE-----
+//	Digest: SHA-512
+//	<message, base64 without padding, 64 characters per line>
+//	-----END MSIGN SIGNED MESSAGE-----
+//	SIG:<signature as exported>
+//
+// the digest is announced up front so the message can be hashed while streaming
+
+const (
+	ArmorBegin = "-----BEGIN MSIGN SIGNED MESSAGE-----" // first line of an attached signature
+	ArmorEnd   = "-----END MSIGN SIGNED MESSAGE-----"   // line closing the message
+
+	armorDigest   = "Digest:"
+	armorLineSize = 48 // message bytes per line, 64 base64 characters
+)
+
+var (
+	ErrInvalidArmor = errors.New("invalid armored message")
+	ErrNilKey       = errors.New("nil key")
+)
+
+type attachedWriter struct {
+	w      io.Writer
+	key    PrivateKey
+	opts   *SignOptions
+	digest Digest
+	hash   hash.Hash
+	line   []byte // message bytes of the pending line
+	err    error  // sticky write error
+}
+
+// NewAttachedWriter writes the armor header to w and returns a writer for the
+// message, Close appends the signature made with key
+func NewAttachedWriter(w io.Writer, key PrivateKey, opts *SignOptions) (io.WriteCloser, error) {
+	if w == nil {
+		return nil, ErrNilWriter
+	}
+	if key == nil {
+		return nil, ErrNilKey
+	}
+
+	digest := signDigest(key, opts)
+	h, err := digest.New()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.WriteString(w, ArmorBegin+"\n"+armorDigest+" "+digest.String()+"\n")
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachedWriter{
+		w:      w,
+		key:    key,
+		opts:   opts,
+		digest: digest,
+		hash:   h,
+		line:   make([]byte, 0, armorLineSize),
+	}, nil
+}
+
+func (a *attachedWriter) Write(p []byte) (int, error) {
+	if a.err != nil {
+		return 0, a.err
+	}
+
+	a.hash.Write(p)
+
+	n := 0
+	for n < len(p) {
+		take := min(armorLineSize-len(a.line), len(p)-n)
+		a.line = append(a.line, p[n:n+take]...)
+		n += take
+
+		if len(a.line) == armorLineSize {
+			a.err = a.flush()
+			if a.err != nil {
+				return n, a.err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Close writes the last message line, the end marker and the signature
+func (a *attachedWriter) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+	a.err = fs.ErrClosed
+
+	if len(a.line) > 0 {
+		err := a.flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(a.w, ArmorEnd+"\n")
+	if err != nil {
+		return err
+	}
+
+	sig, err := a.key.SignWithOptions(&prehashed{digest: a.digest, sum: a.hash.Sum(nil)}, a.opts)
+	if err != nil {
+		return err
+	}
+
+	return sig.export(a.w)
+}
+
+func (a *attachedWriter) flush() error {
+	_, err := io.WriteString(a.w, base64.RawURLEncoding.EncodeToString(a.line)+"\n")
+	a.line = a.line[:0]
+	return err
+}
+
+// SignAttached writes message and its signature as one armored file
+func SignAttached(w io.Writer, message io.Reader, key PrivateKey, opts *SignOptions) error {
+	if message == nil {
+		return ErrNilReader
+	}
+
+	aw, err := NewAttachedWriter(w, key, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(aw, message)
+	if err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+type attachedReader struct {
+	br     *bufio.Reader
+	key    PublicKey
+	opts   *VerifyOptions
+	digest Digest
+	hash   hash.Hash // nil until the header was read
+	buf    []byte    // decoded message bytes not returned yet
+	err    error     // sticky, io.EOF once the signature was verified
+}
+
+// NewAttachedReader returns the message of an armored file as it is read.
+// The signature comes last, so the content must not be trusted before Read
+// returns io.EOF: a signature that doesn't verify with key is reported
+// instead of io.EOF
+func NewAttachedReader(r io.Reader, key PublicKey, opts *VerifyOptions) io.Reader {
+	switch {
+	case r == nil:
+		return &attachedReader{err: ErrNilReader}
+	case key == nil:
+		return &attachedReader{err: ErrNilKey}
+	}
+
+	return &attachedReader{br: bufio.NewReader(r), key: key, opts: opts}
+}
+
+func (a *attachedReader) Read(p []byte) (int, error) {
+	for len(a.buf) == 0 {
+		if a.err != nil {
+			return 0, a.err
+		}
+		a.err = a.next()
+	}
+
+	n := copy(p, a.buf)
+	a.buf = a.buf[n:]
+	return n, nil
+}
+
+// next decodes the next line into buf, at the end marker it verifies the signature
+func (a *attachedReader) next() error {
+	if a.hash == nil {
+		return a.header()
+	}
+
+	line, err := readLine(a.br)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	if line == ArmorEnd {
+		return a.verify()
+	}
+
+	a.buf, err = base64.RawURLEncoding.DecodeString(line)
+	if err != nil {
+		return ErrInvalidArmor
+	}
+	a.hash.Write(a.buf)
+
+	return nil
+}
+
+func (a *attachedReader) header() error {
+	line, err := readLine(a.br)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if line != ArmorBegin {
+		return ErrInvalidArmor
+	}
+
+	line, err = readLine(a.br)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	name, ok := strings.CutPrefix(line, armorDigest)
+	if !ok {
+		return ErrInvalidArmor
+	}
+
+	a.digest, err = parseDigest(strings.TrimSpace(name))
+	if err != nil {
+		return err
+	}
+
+	if !a.opts.allowed(a.digest) {
+		return ErrDigestNotAllowed
+	}
+
+	a.hash, err = a.digest.New()
+	return err
+}
+
+func (a *attachedReader) verify() error {
+	raw, err := importLine(a.br, PrefixSIG, ErrInvalidSigFormat)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	sig, err := decodeSignature(raw)
+	if err != nil {
+		return err
+	}
+
+	if sig.Digest() != a.digest {
+		return ErrInvalidSignature
+	}
+
+	v, err := a.key.VerifyWithOptions(&prehashed{digest: a.digest, sum: a.hash.Sum(nil)}, sig, a.opts)
+	if err != nil {
+		return err
+	}
+	if !v {
+		return ErrInvalidSignature
+	}
+
+	return io.EOF
+}
+
+// VerifyAttached verifies an armored file and returns its message, which is
+// buffered in memory, use NewAttachedReader for large payloads
+func VerifyAttached(r io.Reader, key PublicKey, opts *VerifyOptions) (io.Reader, error) {
+	message, err := io.ReadAll(NewAttachedReader(r, key, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(message), nil
+}
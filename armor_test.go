@@ -0,0 +1,132 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSignAttached(t *testing.T) {
+	messages := []string{"", "Hello World!", strings.Repeat("x", armorLineSize), strings.Repeat("0123456789", 100)}
+
+	for _, version := range testKeyVersions {
+		priv, pub, err := NewPrivateKeyWithVersion(version)
+		if err != nil {
+			t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+		}
+
+		for _, opts := range []*SignOptions{nil, {Digest: DigestSHA3_256, EmbedFingerprint: true}} {
+			for _, msg := range messages {
+				buf := new(bytes.Buffer)
+				if err = SignAttached(buf, strings.NewReader(msg), priv, opts); err != nil {
+					t.Fatalf("SignAttached() failed: %v", err)
+				}
+
+				r, err := VerifyAttached(strings.NewReader(strings.ReplaceAll(buf.String(), "\n", "\r\n")), pub, nil)
+				if err != nil {
+					t.Fatalf("VerifyAttached() version %d failed: %v", version, err)
+				}
+
+				got, _ := io.ReadAll(r)
+				if string(got) != msg {
+					t.Errorf("VerifyAttached() version %d failed by message mismatch", version)
+				}
+			}
+		}
+	}
+}
+
+func TestAttachedReader_Streaming(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	// the payload is generated and checked through hashes, never held in memory
+	payload := func() io.Reader {
+		return io.LimitReader(strings.NewReader(strings.Repeat("streaming payload ", 1<<16)), 1<<20+7)
+	}
+	want := sha256.New()
+	io.Copy(want, payload())
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(SignAttached(pw, payload(), priv, nil))
+	}()
+
+	got := sha256.New()
+	_, err = io.Copy(got, NewAttachedReader(pr, pub, nil))
+	if err != nil {
+		t.Fatalf("NewAttachedReader() failed: %v", err)
+	}
+
+	if !bytes.Equal(got.Sum(nil), want.Sum(nil)) {
+		t.Errorf("NewAttachedReader() failed by message mismatch")
+	}
+}
+
+func TestAttached_Bad(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = SignAttached(buf, strings.NewReader("Hello World!"), priv, nil); err != nil {
+		t.Fatalf("SignAttached() failed: %v", err)
+	}
+	armored := buf.String()
+
+	_, err = VerifyAttached(strings.NewReader(armored), other, nil)
+	if err != ErrKeyIdMismatch {
+		t.Errorf("VerifyAttached() with other key failed: %v", err)
+	}
+
+	// change the last character of the message line
+	lines := strings.Split(armored, "\n")
+	lines[2] = lines[2][:len(lines[2])-1] + "_"
+	_, err = VerifyAttached(strings.NewReader(strings.Join(lines, "\n")), pub, nil)
+	if err != ErrInvalidSignature {
+		t.Errorf("VerifyAttached() with modified message failed: %v", err)
+	}
+
+	_, err = VerifyAttached(strings.NewReader(armored), pub, &VerifyOptions{AllowedDigests: []Digest{DigestSHA256}})
+	if err != ErrDigestNotAllowed {
+		t.Errorf("VerifyAttached() with digest allow-list failed: %v", err)
+	}
+
+	_, err = VerifyAttached(strings.NewReader(armored[:strings.Index(armored, ArmorEnd)]), pub, nil)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("VerifyAttached() truncated failed: %v", err)
+	}
+
+	_, err = VerifyAttached(strings.NewReader(testSignature), pub, nil)
+	if err != ErrInvalidArmor {
+		t.Errorf("VerifyAttached() without armor failed: %v", err)
+	}
+
+	_, err = VerifyAttached(strings.NewReader(strings.Replace(armored, "SHA-512", "MD5", 1)), pub, nil)
+	if err != ErrUnknownDigest {
+		t.Errorf("VerifyAttached() with unknown digest failed: %v", err)
+	}
+
+	_, err = NewAttachedWriter(buf, nil, nil)
+	if err != ErrNilKey {
+		t.Errorf("NewAttachedWriter() failed: %v", err)
+	}
+
+	w, err := NewAttachedWriter(io.Discard, priv, nil)
+	if err != nil {
+		t.Fatalf("NewAttachedWriter() failed: %v", err)
+	}
+	w.Close()
+	if _, err = w.Write([]byte("late")); err == nil {
+		t.Errorf("Write() after Close() failed: %v", err)
+	}
+}
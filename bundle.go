@@ -0,0 +1,104 @@
+package msign
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"iter"
+	"strings"
+)
+
+// BundleReader decodes a stream of prefixed lines (KEY:, PUB:, SIG:, CRT:, TST:,
+// CSG:, ENV:) one item at a time, blank and comment lines are skipped
+type BundleReader struct {
+	br  *bufio.Reader
+	err error // sticky read error
+}
+
+// NewBundleReader returns a reader decoding the items of r
+func NewBundleReader(r io.Reader) *BundleReader {
+	if r == nil {
+		return &BundleReader{err: ErrNilReader}
+	}
+
+	return &BundleReader{br: bufio.NewReader(r)}
+}
+
+// Next returns the next item, or io.EOF once the stream is exhausted.
+// A line that fails to decode returns its error without stopping the reader,
+// read errors are returned by every later call
+func (b *BundleReader) Next() (*Item, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	line, err := readLine(b.br)
+	if err != nil {
+		b.err = err
+		return nil, err
+	}
+
+	return importMsign(line)
+}
+
+// Items iterates over the remaining items, stopping at the end of the stream
+// or after yielding a read error
+func (b *BundleReader) Items() iter.Seq2[*Item, error] {
+	return func(yield func(*Item, error) bool) {
+		for {
+			item, err := b.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			if !yield(item, err) || b.err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ReadBundle decodes every item of r, failing on the first invalid line
+func ReadBundle(r io.Reader) ([]*Item, error) {
+	var items []*Item
+
+	for item, err := range NewBundleReader(r).Items() {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// BundleWriter writes several items into one stream, one line each
+type BundleWriter struct {
+	w io.Writer
+}
+
+// NewBundleWriter returns a writer appending items to w
+func NewBundleWriter(w io.Writer) *BundleWriter {
+	return &BundleWriter{w: w}
+}
+
+// Write appends an item, see Export
+func (b *BundleWriter) Write(item any) error {
+	return Export(b.w, item)
+}
+
+// Comment appends a comment line, skipped when reading the bundle back
+func (b *BundleWriter) Comment(text string) error {
+	if b.w == nil {
+		return ErrNilWriter
+	}
+
+	for line := range strings.Lines(text) {
+		_, err := io.WriteString(b.w, PrefixComment+" "+strings.TrimRight(line, "\r\n")+"\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func testCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "msign test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		t.Fatalf("CreateCertificate() failed: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() failed: %v", err)
+	}
+
+	return cert
+}
+
+func TestBundle(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	sig1, err := priv.Sign(strings.NewReader("first"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	sig2, err := priv.SignWithOptions(strings.NewReader("second"), &SignOptions{Digest: DigestSHA256})
+	if err != nil {
+		t.Fatalf("SignWithOptions() failed: %v", err)
+	}
+	cert := testCertificate(t)
+
+	buf := new(bytes.Buffer)
+	bw := NewBundleWriter(buf)
+	if err = bw.Comment("release bundle\nsecond line"); err != nil {
+		t.Fatalf("Comment() failed: %v", err)
+	}
+	for _, item := range []any{pub, cert, sig1, sig2} {
+		if err = bw.Write(item); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	items, err := ReadBundle(strings.NewReader(strings.ReplaceAll(buf.String(), "\n", "\r\n")))
+	if err != nil {
+		t.Fatalf("ReadBundle() failed: %v", err)
+	}
+
+	if len(items) != 4 {
+		t.Fatalf("ReadBundle() failed by item count: %v", len(items))
+	}
+
+	if items[0].PublicKey == nil || !bytes.Equal(items[0].PublicKey.Id(), pub.Id()) {
+		t.Errorf("ReadBundle() failed by public key mismatch")
+	}
+	if items[1].Certificate == nil || !bytes.Equal(items[1].Certificate.Raw, cert.Raw) {
+		t.Errorf("ReadBundle() failed by certificate mismatch")
+	}
+
+	for i, msg := range []string{"first", "second"} {
+		sig := items[2+i].Signature
+		if sig == nil {
+			t.Fatalf("ReadBundle() failed by missing signature %d", i)
+		}
+		v, err := items[0].PublicKey.Verify(strings.NewReader(msg), sig)
+		if err != nil || !v {
+			t.Errorf("Verify() failed: %v", err)
+		}
+	}
+
+	// single item importers still read the first item only
+	_, err = ImportPublicKey(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Errorf("ImportPublicKey() failed: %v", err)
+	}
+}
+
+func TestBundle_Attestations(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	sig, err := priv.Sign(strings.NewReader("release"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	token, err := TimestampSignature(sig, priv, 7)
+	if err != nil {
+		t.Fatalf("TimestampSignature() failed: %v", err)
+	}
+	csg, err := Countersign(sig, priv, []byte("approved"))
+	if err != nil {
+		t.Fatalf("Countersign() failed: %v", err)
+	}
+	env, err := NewSignedEnvelope([]byte("payload"), priv)
+	if err != nil {
+		t.Fatalf("NewSignedEnvelope() failed: %v", err)
+	}
+
+	// everything the writer accepts is read back
+	buf := new(bytes.Buffer)
+	bw := NewBundleWriter(buf)
+	for _, item := range []any{sig, token, csg, env} {
+		if err = bw.Write(item); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	items, err := ReadBundle(buf)
+	if err != nil || len(items) != 4 {
+		t.Fatalf("ReadBundle() failed: %v", err)
+	}
+	if _, err = items[1].Timestamp.Verify(items[0].Signature, Keyring{pub}); err != nil {
+		t.Errorf("ReadBundle() failed by timestamp: %v", err)
+	}
+	if _, err = items[2].Countersignature.Verify(items[0].Signature, Keyring{pub}); err != nil {
+		t.Errorf("ReadBundle() failed by countersignature: %v", err)
+	}
+	if _, err = items[3].Envelope.Verify(Keyring{pub}, nil, 0); err != nil {
+		t.Errorf("ReadBundle() failed by envelope: %v", err)
+	}
+
+	for i, want := range []any{sig, token, csg, env} {
+		var got, exported bytes.Buffer
+		Export(&got, items[i].Value())
+		Export(&exported, want)
+		if got.String() != exported.String() {
+			t.Errorf("Value() of item %d failed: %s", i, got.String())
+		}
+	}
+
+	inputs := map[string]error{"TST:AAAA": ErrInvalidTimestamp, "CSG:AAAA": ErrInvalidCountersignature, "ENV:AAAA": ErrInvalidSignedEnvelope}
+	for line, want := range inputs {
+		if _, err = ImportAny(strings.NewReader(line)); err != want {
+			t.Errorf("ImportAny(%s) failed: %v", line, err)
+		}
+	}
+}
+
+func TestBundleReader_Items(t *testing.T) {
+	input := testPublicKey + "XYZ:unknown\n" + testBadSignature_5 + testSignature
+
+	var (
+		items []*Item
+		errs  []error
+	)
+	for item, err := range NewBundleReader(strings.NewReader(input)).Items() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if len(items) != 2 || items[0].PublicKey == nil || items[1].Signature == nil {
+		t.Errorf("Items() failed by item count: %v", len(items))
+	}
+
+	if len(errs) != 2 || errs[0] != ErrUnsupportedFormat || errs[1] != ErrInvalidSigFormat {
+		t.Errorf("Items() failed by errors: %v", errs)
+	}
+
+	_, err := ReadBundle(strings.NewReader(input))
+	if err != ErrUnsupportedFormat {
+		t.Errorf("ReadBundle() failed: %v", err)
+	}
+}
+
+func TestBundleReader_Bad(t *testing.T) {
+	br := NewBundleReader(iotest.ErrReader(ErrNilWriter))
+	_, err := br.Next()
+	if err != ErrNilWriter {
+		t.Errorf("Next() failed: %v", err)
+	}
+
+	count := 0
+	for range br.Items() {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Items() should stop after a read error: %v", count)
+	}
+
+	_, err = NewBundleReader(nil).Next()
+	if err != ErrNilReader {
+		t.Errorf("Next() failed: %v", err)
+	}
+
+	_, err = NewBundleReader(strings.NewReader("")).Next()
+	if err != io.EOF {
+		t.Errorf("Next() failed: %v", err)
+	}
+
+	err = NewBundleWriter(nil).Write(nil)
+	if err != ErrNilWriter {
+		t.Errorf("Write() failed: %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package msign
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"unicode/utf8"
+)
+
+// minimal CBOR (RFC 8949) for COSE: integers, byte and text strings, arrays,
+// maps, tags, booleans and null. Encoding is deterministic (RFC 8949 section
+// 4.2.1): shortest arguments, definite lengths and map keys sorted by their
+// encoding. Decoding refuses what the encoder would never produce besides
+// unsorted map keys: long arguments, indefinite lengths, floats, duplicate keys
+
+const (
+	cborMajorUint   = 0
+	cborMajorNegint = 1
+	cborMajorBytes  = 2
+	cborMajorText   = 3
+	cborMajorArray  = 4
+	cborMajorMap    = 5
+	cborMajorTag    = 6
+	cborMajorSimple = 7
+
+	cborFalse = 20
+	cborTrue  = 21
+	cborNull  = 22
+
+	cborMaxDepth = 16
+)
+
+var ErrInvalidCBOR = errors.New("invalid CBOR")
+
+// cborPair is a map entry, decoded maps keep the order of their entries
+type cborPair struct {
+	Key   any
+	Value any
+}
+
+type cborMap []cborPair
+
+// cborTag is a tagged data item
+type cborTag struct {
+	Number  uint64
+	Content any
+}
+
+// get returns the value of key and whether the map holds it
+func (m cborMap) get(key any) (any, bool) {
+	for _, p := range m {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+// cborMarshal encodes int, int64, uint64, []byte, string, []any, cborMap,
+// cborTag, bool and nil values
+func cborMarshal(v any) ([]byte, error) {
+	return cborAppend(nil, v)
+}
+
+// cborUnmarshal decodes a single data item filling the whole input, integers
+// decode as int64
+func cborUnmarshal(data []byte) (any, error) {
+	d := &cborDecoder{b: data}
+
+	v, err := d.item(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) != 0 {
+		return nil, ErrInvalidCBOR
+	}
+
+	return v, nil
+}
+
+// utility functions
+
+func cborHead(b []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(b, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(b, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), arg)
+}
+
+func cborAppend(b []byte, v any) ([]byte, error) {
+	switch x := v.(type) {
+	case int:
+		return cborAppend(b, int64(x))
+	case int64:
+		if x < 0 {
+			return cborHead(b, cborMajorNegint, uint64(-1-x)), nil
+		}
+		return cborHead(b, cborMajorUint, uint64(x)), nil
+	case uint64:
+		return cborHead(b, cborMajorUint, x), nil
+	case []byte:
+		return append(cborHead(b, cborMajorBytes, uint64(len(x))), x...), nil
+	case string:
+		if !utf8.ValidString(x) {
+			return nil, ErrInvalidCBOR
+		}
+		return append(cborHead(b, cborMajorText, uint64(len(x))), x...), nil
+	case []any:
+		b = cborHead(b, cborMajorArray, uint64(len(x)))
+		for _, e := range x {
+			var err error
+			b, err = cborAppend(b, e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case cborMap:
+		return cborAppendMap(b, x)
+	case cborTag:
+		return cborAppend(cborHead(b, cborMajorTag, x.Number), x.Content)
+	case bool:
+		if x {
+			return append(b, cborMajorSimple<<5|cborTrue), nil
+		}
+		return append(b, cborMajorSimple<<5|cborFalse), nil
+	case nil:
+		return append(b, cborMajorSimple<<5|cborNull), nil
+	}
+
+	return nil, ErrInvalidCBOR
+}
+
+func cborAppendMap(b []byte, m cborMap) ([]byte, error) {
+	entries := make([][2][]byte, len(m))
+	for i, p := range m {
+		key, err := cborMarshal(p.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := cborMarshal(p.Value)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = [2][]byte{key, value}
+	}
+
+	slices.SortFunc(entries, func(a, b [2][]byte) int {
+		return bytes.Compare(a[0], b[0])
+	})
+
+	b = cborHead(b, cborMajorMap, uint64(len(entries)))
+	for i, e := range entries {
+		if i > 0 && bytes.Equal(e[0], entries[i-1][0]) {
+			return nil, ErrInvalidCBOR
+		}
+		b = append(append(b, e[0]...), e[1]...)
+	}
+
+	return b, nil
+}
+
+type cborDecoder struct {
+	b []byte
+}
+
+// head reads the initial byte and the argument, refusing non shortest forms
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if len(d.b) == 0 {
+		return 0, 0, ErrInvalidCBOR
+	}
+
+	major, info := d.b[0]>>5, d.b[0]&0x1f
+	d.b = d.b[1:]
+
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	if major == cborMajorSimple {
+		return 0, 0, ErrInvalidCBOR // floats and two byte simple values
+	}
+
+	size := 0
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, 0, ErrInvalidCBOR // reserved or indefinite length
+	}
+	if len(d.b) < size {
+		return 0, 0, ErrInvalidCBOR
+	}
+
+	var arg uint64
+	for _, c := range d.b[:size] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.b = d.b[size:]
+
+	if len(cborHead(nil, major, arg)) != 1+size {
+		return 0, 0, ErrInvalidCBOR
+	}
+
+	return major, arg, nil
+}
+
+func (d *cborDecoder) item(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, ErrInvalidCBOR
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborMajorUint:
+		if arg > math.MaxInt64 {
+			return nil, ErrInvalidCBOR
+		}
+		return int64(arg), nil
+	case cborMajorNegint:
+		if arg > math.MaxInt64 {
+			return nil, ErrInvalidCBOR
+		}
+		return -1 - int64(arg), nil
+	case cborMajorBytes, cborMajorText:
+		if arg > uint64(len(d.b)) {
+			return nil, ErrInvalidCBOR
+		}
+		data := d.b[:arg:arg]
+		d.b = d.b[arg:]
+		if major == cborMajorText {
+			if !utf8.Valid(data) {
+				return nil, ErrInvalidCBOR
+			}
+			return string(data), nil
+		}
+		return bytes.Clone(data), nil
+	case cborMajorArray:
+		if arg > uint64(len(d.b)) {
+			return nil, ErrInvalidCBOR // each element takes at least one byte
+		}
+		array := make([]any, arg)
+		for i := range array {
+			array[i], err = d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case cborMajorMap:
+		if arg > uint64(len(d.b))/2 {
+			return nil, ErrInvalidCBOR
+		}
+		m := make(cborMap, 0, arg)
+		for range arg {
+			key, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if !cborValidKey(key) {
+				return nil, ErrInvalidCBOR
+			}
+			if _, dup := m.get(key); dup {
+				return nil, ErrInvalidCBOR
+			}
+			value, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, cborPair{Key: key, Value: value})
+		}
+		return m, nil
+	case cborMajorTag:
+		content, err := d.item(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return cborTag{Number: arg, Content: content}, nil
+	case cborMajorSimple:
+		switch arg {
+		case cborFalse:
+			return false, nil
+		case cborTrue:
+			return true, nil
+		case cborNull:
+			return nil, nil
+		}
+	}
+
+	return nil, ErrInvalidCBOR
+}
+
+// cborValidKey reports whether a decoded map key is an integer or text,
+// the only key types COSE uses
+func cborValidKey(key any) bool {
+	switch key.(type) {
+	case int64, string:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package msign
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// RFC 8949 appendix A
+var testCBOR = []struct {
+	value any
+	hex   string
+}{
+	{int64(0), "00"},
+	{int64(1), "01"},
+	{int64(10), "0a"},
+	{int64(23), "17"},
+	{int64(24), "1818"},
+	{int64(25), "1819"},
+	{int64(100), "1864"},
+	{int64(1000), "1903e8"},
+	{int64(1000000), "1a000f4240"},
+	{int64(1000000000000), "1b000000e8d4a51000"},
+	{int64(-1), "20"},
+	{int64(-10), "29"},
+	{int64(-100), "3863"},
+	{int64(-1000), "3903e7"},
+	{false, "f4"},
+	{true, "f5"},
+	{nil, "f6"},
+	{[]byte{}, "40"},
+	{[]byte{1, 2, 3, 4}, "4401020304"},
+	{"", "60"},
+	{"a", "6161"},
+	{"IETF", "6449455446"},
+	{"\"\\", "62225c"},
+	{"ü", "62c3bc"},
+	{"水", "63e6b0b4"},
+	{[]any{}, "80"},
+	{[]any{int64(1), int64(2), int64(3)}, "83010203"},
+	{[]any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}, "8301820203820405"},
+	{cborMap{}, "a0"},
+	{cborMap{{int64(1), int64(2)}, {int64(3), int64(4)}}, "a201020304"},
+	{cborMap{{"a", int64(1)}, {"b", []any{int64(2), int64(3)}}}, "a26161016162820203"},
+	{[]any{"a", cborMap{{"b", "c"}}}, "826161a161626163"},
+	{cborTag{Number: 23, Content: []byte{1, 2, 3, 4}}, "d74401020304"},
+	{cborTag{Number: 32, Content: "http://www.example.com"}, "d82076687474703a2f2f7777772e6578616d706c652e636f6d"},
+}
+
+func TestCBOR(t *testing.T) {
+	for _, test := range testCBOR {
+		data, err := cborMarshal(test.value)
+		if err != nil || hex.EncodeToString(data) != test.hex {
+			t.Errorf("cborMarshal(%#v) failed: %x %v", test.value, data, err)
+		}
+
+		want, _ := hex.DecodeString(test.hex)
+		v, err := cborUnmarshal(want)
+		if err != nil || !reflect.DeepEqual(v, test.value) {
+			t.Errorf("cborUnmarshal(%s) failed: %#v %v", test.hex, v, err)
+		}
+	}
+}
+
+func TestCBOR_Deterministic(t *testing.T) {
+	// keys sort by their encoding: 0a < 20 < 6162, int is encoded as int64
+	m := cborMap{{"b", int64(1)}, {10, int64(2)}, {int64(-1), int64(3)}}
+
+	data, err := cborMarshal(m)
+	if err != nil || hex.EncodeToString(data) != "a30a022003616201" {
+		t.Errorf("cborMarshal() failed: %x %v", data, err)
+	}
+
+	_, err = cborMarshal(cborMap{{1, "x"}, {int64(1), "y"}})
+	if err != ErrInvalidCBOR {
+		t.Errorf("cborMarshal() with duplicate key failed: %v", err)
+	}
+}
+
+func TestCBOR_Bad(t *testing.T) {
+	inputs := []string{
+		"",                   // empty
+		"1817",               // 23 with a one byte argument
+		"190017",             // 23 with a two byte argument
+		"1b8000000000000000", // beyond int64
+		"5f42010243030405ff", // indefinite length byte string
+		"9f01ff",             // indefinite length array
+		"f93c00",             // half float
+		"f814",               // false as a two byte simple value
+		"f7",                 // undefined
+		"62c3",               // truncated text
+		"61ff",               // invalid UTF-8
+		"a201020103",         // duplicate key
+		"a1400102",           // byte string key
+		"9a7fffffff",         // array longer than the input
+		"0000",               // trailing data
+		"818181818181818181818181818181818181818100", // nested too deep
+	}
+
+	for _, input := range inputs {
+		data, _ := hex.DecodeString(input)
+		if _, err := cborUnmarshal(data); err != ErrInvalidCBOR {
+			t.Errorf("cborUnmarshal(%s) failed: %v", input, err)
+		}
+	}
+
+	_, err := cborMarshal(1.5)
+	if err != ErrInvalidCBOR {
+		t.Errorf("cborMarshal() with float failed: %v", err)
+	}
+}
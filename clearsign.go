@@ -0,0 +1,150 @@
+package msign
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// cleartext signed documents keep the text readable next to its signature:
+//
+//	-----BEGIN MSIGN SIGNED TEXT-----
+//	<text, lines starting with "-" are escaped as "- -">
+//	-----BEGIN MSIGN SIGNATURE-----
+//	SIG:<signature as exported>
+//	-----END MSIGN SIGNATURE-----
+//
+// the signature covers the canonical text: LF line endings, no trailing
+// whitespace on any line and no trailing blank lines or final newline
+
+const (
+	ClearSignBegin     = "-----BEGIN MSIGN SIGNED TEXT-----"
+	ClearSignSignature = "-----BEGIN MSIGN SIGNATURE-----"
+	ClearSignEnd       = "-----END MSIGN SIGNATURE-----"
+
+	clearSignEscape = "- "
+)
+
+// CanonicalText returns text as it is signed by ClearSign
+func CanonicalText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var b strings.Builder
+	for line := range strings.Lines(text) {
+		b.WriteString(strings.TrimRight(line, " \t\r\n"))
+		b.WriteByte('\n')
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// ClearSign writes text in canonical form followed by its signature
+func ClearSign(w io.Writer, key PrivateKey, text string) error {
+	if w == nil {
+		return ErrNilWriter
+	}
+	if key == nil {
+		return ErrNilKey
+	}
+
+	text = CanonicalText(text)
+	sig, err := key.Sign(strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(ClearSignBegin + "\n")
+	if text != "" {
+		for line := range strings.Lines(text) {
+			if strings.HasPrefix(line, "-") {
+				b.WriteString(clearSignEscape)
+			}
+			b.WriteString(strings.TrimSuffix(line, "\n") + "\n")
+		}
+	}
+	b.WriteString(ClearSignSignature + "\n")
+
+	_, err = io.WriteString(w, b.String())
+	if err != nil {
+		return err
+	}
+
+	err = sig.export(w)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, ClearSignEnd+"\n")
+	return err
+}
+
+// VerifyClearSigned verifies a document written by ClearSign with the matching
+// key of keyring, returning the canonical text and the signing key.
+// Anything before the begin marker, e.g. mail headers, is ignored
+func VerifyClearSigned(r io.Reader, keyring Keyring) (string, PublicKey, error) {
+	if r == nil {
+		return "", nil, ErrNilReader
+	}
+
+	br := bufio.NewReader(r)
+
+	// skip to the begin marker
+	for {
+		line, err := br.ReadString('\n')
+		if strings.TrimSpace(line) == ClearSignBegin {
+			break
+		}
+		if err == io.EOF {
+			return "", nil, ErrInvalidArmor
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	var b strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == ClearSignSignature {
+			break
+		}
+
+		b.WriteString(strings.TrimPrefix(line, clearSignEscape) + "\n")
+	}
+
+	raw, err := importLine(br, PrefixSIG, ErrInvalidSigFormat)
+	if err != nil {
+		return "", nil, err
+	}
+
+	sig, err := decodeSignature(raw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	end, err := readLine(br)
+	if end != ClearSignEnd {
+		if err == nil || err == io.EOF {
+			err = ErrInvalidArmor
+		}
+		return "", nil, err
+	}
+
+	text := CanonicalText(b.String())
+	pub, err := keyring.Verify(strings.NewReader(text), sig)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return text, pub, nil
+}
@@ -0,0 +1,123 @@
+package msign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testAdvisory = `Security advisory MSIGN-2026-01
+-------------------------------
+
+- upgrade to 1.2.3
+- rotate keys
+
+# thanks
+`
+
+func TestCanonicalText(t *testing.T) {
+	inputs := map[string]string{
+		"":                      "",
+		"a\r\nb\r\n":            "a\nb",
+		"a \t\nb\n\n\n":         "a\nb",
+		"a\rb  ":                "a\nb",
+		"\n  indented\nlast":    "\n  indented\nlast",
+		"trailing\r\n \r\n\t\n": "trailing",
+	}
+
+	for input, want := range inputs {
+		if got := CanonicalText(input); got != want {
+			t.Errorf("CanonicalText(%q) failed: %q", input, got)
+		}
+	}
+}
+
+func TestClearSign(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	keyring := Keyring{other, pub}
+
+	buf := new(bytes.Buffer)
+	if err = ClearSign(buf, priv, testAdvisory); err != nil {
+		t.Fatalf("ClearSign() failed: %v", err)
+	}
+	doc := buf.String()
+
+	if !strings.Contains(doc, "\n- - upgrade to 1.2.3\n") {
+		t.Errorf("ClearSign() failed by dash escaping:\n%s", doc)
+	}
+
+	// mail transport: headers, CRLF line endings and trailing whitespace
+	mailed := "Subject: advisory\r\n\r\n" + strings.ReplaceAll(doc, "\n", "  \r\n") + "\r\n"
+
+	for _, input := range []string{doc, mailed} {
+		text, key, err := VerifyClearSigned(strings.NewReader(input), keyring)
+		if err != nil {
+			t.Fatalf("VerifyClearSigned() failed: %v", err)
+		}
+		if text != CanonicalText(testAdvisory) {
+			t.Errorf("VerifyClearSigned() failed by text mismatch: %q", text)
+		}
+		if !bytes.Equal(key.Fingerprint(), pub.Fingerprint()) {
+			t.Errorf("VerifyClearSigned() failed by key mismatch")
+		}
+	}
+
+	buf.Reset()
+	if err = ClearSign(buf, priv, ""); err != nil {
+		t.Fatalf("ClearSign() failed: %v", err)
+	}
+	text, _, err := VerifyClearSigned(buf, keyring)
+	if err != nil || text != "" {
+		t.Errorf("VerifyClearSigned() with empty text failed: %q %v", text, err)
+	}
+}
+
+func TestVerifyClearSigned_Bad(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = ClearSign(buf, priv, testAdvisory); err != nil {
+		t.Fatalf("ClearSign() failed: %v", err)
+	}
+	doc := buf.String()
+
+	_, _, err = VerifyClearSigned(strings.NewReader(strings.Replace(doc, "1.2.3", "1.2.4", 1)), Keyring{pub})
+	if err != ErrInvalidSignature {
+		t.Errorf("VerifyClearSigned() with modified text failed: %v", err)
+	}
+
+	_, _, err = VerifyClearSigned(strings.NewReader(doc), Keyring{})
+	if err != ErrKeyNotFound {
+		t.Errorf("VerifyClearSigned() with empty keyring failed: %v", err)
+	}
+
+	_, _, err = VerifyClearSigned(strings.NewReader(testAdvisory), Keyring{pub})
+	if err != ErrInvalidArmor {
+		t.Errorf("VerifyClearSigned() without markers failed: %v", err)
+	}
+
+	_, _, err = VerifyClearSigned(strings.NewReader(strings.TrimSuffix(doc, ClearSignEnd+"\n")), Keyring{pub})
+	if err != ErrInvalidArmor {
+		t.Errorf("VerifyClearSigned() without end marker failed: %v", err)
+	}
+
+	_, _, err = VerifyClearSigned(strings.NewReader(doc[:strings.Index(doc, ClearSignSignature)]), Keyring{pub})
+	if err == nil {
+		t.Errorf("VerifyClearSigned() truncated failed: %v", err)
+	}
+
+	err = ClearSign(buf, nil, testAdvisory)
+	if err != ErrNilKey {
+		t.Errorf("ClearSign() failed: %v", err)
+	}
+}
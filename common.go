@@ -2,6 +2,7 @@ package msign
 
 import (
 	"bufio"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
@@ -13,10 +14,21 @@ const (
 	PrefixSIG = "SIG:" // signature prefix
 	PrefixPUB = "PUB:" // public key prefix
 	PrefixKEY = "KEY:" // private key prefix
+	PrefixCRT = "CRT:" // X.509 certificate prefix, DER encoded
+	PrefixTST = "TST:" // timestamp token prefix
+	PrefixCSG = "CSG:" // countersignature prefix
+	PrefixENV = "ENV:" // signed envelope prefix
+
+	PrefixComment = "#" // comment line prefix, skipped by importers
 )
 
 const (
-	VersionOne = 1 // msign version 1
+	VersionOne   = 1 // msign version 1
+	VersionTwo   = 2 // msign version 2 (signature with digest algorithm)
+	VersionThree = 3 // msign version 3 (ECDSA P-256)
+	VersionFour  = 4 // msign version 4 (RSA-PSS)
+	VersionFive  = 5 // msign version 5 (hybrid Ed25519 + ML-DSA-65)
+	VersionSix   = 6 // msign version 6 (signature with full key fingerprint)
 )
 
 const (
@@ -32,12 +44,26 @@ var (
 	ErrUnknownType      = errors.New("unknown export type")
 	ErrNilWriter        = errors.New("nil writer")
 	ErrNilReader        = errors.New("nil reader")
+	ErrUnknownDigest    = errors.New("unknown digest algorithm")
+	ErrDigestNotAllowed = errors.New("digest algorithm not allowed")
+	ErrUnknownVersion   = errors.New("unknown key version")
 )
 
 func NewPrivateKey() (PrivateKey, PublicKey, error) {
 	return newPrivateKeyV1()
 }
 
+// NewPrivateKeyWithVersion generates a key pair of the given format version,
+// e.g. VersionThree for ECDSA P-256 or VersionFour for RSA-PSS
+func NewPrivateKeyWithVersion(version byte) (PrivateKey, PublicKey, error) {
+	t, ok := keyTypes[version]
+	if !ok || t.generate == nil {
+		return nil, nil, ErrUnknownVersion
+	}
+
+	return t.generate()
+}
+
 func (k KeyId) String() string {
 	return hex.EncodeToString(k)
 }
@@ -47,92 +73,100 @@ func ImportPublicKey(r io.Reader) (PublicKey, error) {
 		return nil, ErrNilReader
 	}
 
-	br := bufio.NewReader(r)
-	line, err := br.ReadString('\n')
+	pub, err := importLine(bufio.NewReader(r), PrefixPUB, ErrInvalidPubFormat)
 	if err != nil {
 		return nil, err
 	}
 
-	if !strings.HasPrefix(line, PrefixPUB) {
-		return nil, ErrInvalidPubFormat
+	return decodePublicKey(pub)
+}
+
+func ImportPrivateKey(r io.Reader) (PrivateKey, error) {
+	if r == nil {
+		return nil, ErrNilReader
 	}
 
-	line = strings.TrimPrefix(line, PrefixPUB) // remove prefix
-	bd := base64.NewDecoder(base64.RawURLEncoding, strings.NewReader(line))
-	pub, err := io.ReadAll(bd)
+	key, err := importLine(bufio.NewReader(r), PrefixKEY, ErrInvalidKeyFormat)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(pub) > sizeVersion {
-		if pub[0] == VersionOne {
-			return getPublicKeyV1(pub)
-		}
-	}
-
-	return nil, ErrInvalidPubFormat
+	return decodePrivateKey(key)
 }
 
-func ImportPrivateKey(r io.Reader) (PrivateKey, error) {
+func ImportSignature(r io.Reader) (Signature, error) {
 	if r == nil {
 		return nil, ErrNilReader
 	}
 
-	br := bufio.NewReader(r)
-	line, err := br.ReadString('\n')
+	sig, err := importLine(bufio.NewReader(r), PrefixSIG, ErrInvalidSigFormat)
 	if err != nil {
 		return nil, err
 	}
 
-	if !strings.HasPrefix(line, PrefixKEY) {
-		return nil, ErrInvalidKeyFormat
+	return decodeSignature(sig)
+}
+
+// readLine returns the next line that is neither blank nor a comment, trimmed of
+// surrounding whitespace and CR, the last line may miss its newline
+func readLine(br *bufio.Reader) (string, error) {
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, PrefixComment) {
+			return line, nil
+		}
+
+		if err == io.EOF {
+			return "", io.EOF
+		}
 	}
+}
 
-	line = strings.TrimPrefix(line, PrefixKEY) // remove prefix
-	bd := base64.NewDecoder(base64.RawURLEncoding, strings.NewReader(line))
-	key, err := io.ReadAll(bd)
+// importLine reads the next line and decodes its base64 payload after the prefix
+func importLine(br *bufio.Reader, prefix string, errFormat error) ([]byte, error) {
+	line, err := readLine(br)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(key) > sizeVersion {
-		if key[0] == VersionOne {
-			return getPrivateKeyV1(key)
-		}
-	}
-
-	return nil, ErrInvalidKeyFormat
+	return decodeLine(line, prefix, errFormat)
 }
 
-func ImportSignature(r io.Reader) (Signature, error) {
-	if r == nil {
-		return nil, ErrNilReader
+// decodeLine decodes the base64 payload of an already read line
+func decodeLine(line string, prefix string, errFormat error) ([]byte, error) {
+	if !strings.HasPrefix(line, prefix) {
+		return nil, errFormat
 	}
 
-	br := bufio.NewReader(r)
-	line, err := br.ReadString('\n')
-	if err != nil {
-		return nil, err
-	}
+	line = strings.TrimSpace(strings.TrimPrefix(line, prefix)) // remove prefix
+	return base64.RawURLEncoding.DecodeString(line)
+}
 
-	if !strings.HasPrefix(line, PrefixSIG) {
-		return nil, ErrInvalidSigFormat
+// exportBytes writes the prefixed base64 line used by all export methods
+func exportBytes(w io.Writer, prefix string, data []byte) error {
+	_, err := w.Write([]byte(prefix))
+	if err != nil {
+		return err
 	}
 
-	line = strings.TrimPrefix(line, PrefixSIG) // remove prefix
-	bd := base64.NewDecoder(base64.RawURLEncoding, strings.NewReader(line))
-	sig, err := io.ReadAll(bd)
+	be := base64.NewEncoder(base64.RawURLEncoding, w)
+	_, err = be.Write(data)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if len(sig) > sizeVersion {
-		if sig[0] == VersionOne {
-			return getSignatureV1(sig)
-		}
+	err = be.Close()
+	if err != nil {
+		return err
 	}
 
-	return nil, ErrInvalidSigFormat
+	_, err = w.Write([]byte("\n"))
+	return err
 }
 
 func Export(w io.Writer, item any) error {
@@ -147,6 +181,16 @@ func Export(w io.Writer, item any) error {
 		return i.export(w)
 	case Signature:
 		return i.export(w)
+	case *x509.Certificate:
+		return exportBytes(w, PrefixCRT, i.Raw)
+	case *TimestampToken:
+		return i.export(w)
+	case *Countersignature:
+		return i.export(w)
+	case *SignatureChain:
+		return i.export(w)
+	case *SignedEnvelope:
+		return i.export(w)
 	}
 
 	return ErrUnknownType
@@ -224,8 +224,9 @@ func TestImportPublicKey(t *testing.T) {
 }
 
 func TestImportPublicKey_Bad(t *testing.T) {
+	// a missing trailing newline is tolerated
 	_, err := ImportPublicKey(strings.NewReader(testBadPublicKey_1))
-	if err != io.EOF {
+	if err != nil {
 		t.Errorf("ImportPublicKey() failed: %v", err)
 	}
 	_, err = ImportPublicKey(strings.NewReader(testBadPublicKey_2))
@@ -267,8 +268,9 @@ func TestImportPrivateKey(t *testing.T) {
 }
 
 func TestImportPrivateKey_Bad(t *testing.T) {
+	// a missing trailing newline is tolerated
 	_, err := ImportPrivateKey(strings.NewReader(testBadPrivateKey_1))
-	if err != io.EOF {
+	if err != nil {
 		t.Errorf("ImportPrivateKey() failed: %v", err)
 	}
 	_, err = ImportPrivateKey(strings.NewReader(testBadPrivateKey_2))
@@ -310,8 +312,9 @@ func TestImportSignature(t *testing.T) {
 }
 
 func TestImportSignature_Bad(t *testing.T) {
+	// a missing trailing newline is tolerated
 	_, err := ImportSignature(strings.NewReader(testBadSignature_1))
-	if err != io.EOF {
+	if err != nil {
 		t.Errorf("ImportSignature() failed: %v", err)
 	}
 	_, err = ImportSignature(strings.NewReader(testBadSignature_2))
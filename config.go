@@ -0,0 +1,156 @@
+package msign
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// Signed configuration files are accepted only with a valid signature of a
+// trusted key. The signature is either detached, a SIG: line in a file next
+// to the configuration, or embedded by writing the configuration with
+// ClearSign. Embedded configurations load as their canonical text
+
+const (
+	ConfigSignatureSuffix = ".sig"
+
+	DefaultConfigPollInterval = 5 * time.Second
+)
+
+var ErrConfigNotSigned = errors.New("configuration file is not signed")
+
+// ConfigLoader loads the configuration file at Path signed by a key of Keyring
+type ConfigLoader struct {
+	Path          string
+	SignaturePath string // detached signature, empty uses Path+ConfigSignatureSuffix
+	Embedded      bool   // the file is clear signed and SignaturePath is unused
+	Keyring       Keyring
+}
+
+// Load returns the verified configuration and the key that signed it
+func (l *ConfigLoader) Load() ([]byte, PublicKey, error) {
+	data, pub, _, err := l.load()
+	return data, pub, err
+}
+
+// LoadJSON decodes the verified configuration into v and returns the key that signed it
+func (l *ConfigLoader) LoadJSON(v any) (PublicKey, error) {
+	data, pub, err := l.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return pub, nil
+}
+
+// Watch loads the configuration, passes it to reload and then polls every
+// interval, zero uses DefaultConfigPollInterval. Once a new signature appears
+// and verifies the configuration is passed to reload again, a changed file
+// without a new valid signature is ignored. Watch returns the error of the
+// first load or, once ctx is done, its error
+func (l *ConfigLoader) Watch(ctx context.Context, interval time.Duration, reload func(data []byte, pub PublicKey)) error {
+	if interval <= 0 {
+		interval = DefaultConfigPollInterval
+	}
+
+	data, pub, mark, err := l.load()
+	if err != nil {
+		return err
+	}
+	reload(data, pub)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := l.signatureMark()
+		if err != nil || bytes.Equal(current, mark) {
+			continue
+		}
+
+		// keep the last valid configuration until the new one verifies
+		data, pub, current, err = l.load()
+		if err != nil {
+			continue
+		}
+
+		mark = current
+		reload(data, pub)
+	}
+}
+
+// utility functions
+
+// load returns the verified configuration, its key and the signature mark it
+// was verified with
+func (l *ConfigLoader) load() ([]byte, PublicKey, []byte, error) {
+	mark, err := l.signatureMark()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if l.Embedded {
+		if !bytes.Contains(mark, []byte(ClearSignBegin)) {
+			return nil, nil, nil, ErrConfigNotSigned
+		}
+
+		text, pub, err := VerifyClearSigned(bytes.NewReader(mark), l.Keyring)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return []byte(text), pub, mark, nil
+	}
+
+	sig, err := ImportSignature(bytes.NewReader(mark))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	pub, err := l.Keyring.Verify(bytes.NewReader(data), sig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return data, pub, mark, nil
+}
+
+// signatureMark returns what changes with a new signature: the detached
+// signature file or the whole clear signed file
+func (l *ConfigLoader) signatureMark() ([]byte, error) {
+	if l.Embedded {
+		return os.ReadFile(l.Path)
+	}
+
+	path := l.SignaturePath
+	if path == "" {
+		path = l.Path + ConfigSignatureSuffix
+	}
+
+	mark, err := os.ReadFile(path)
+	if os.IsNotExist(err) || (err == nil && strings.TrimSpace(string(mark)) == "") {
+		return nil, ErrConfigNotSigned
+	}
+
+	return mark, err
+}
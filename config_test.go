@@ -0,0 +1,185 @@
+package msign
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSignConfig writes data to path and its detached signature by key
+func testSignConfig(t *testing.T, key PrivateKey, path, data string) {
+	sig, err := key.Sign(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err = Export(&b, sig); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+
+	if err = os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	if err = os.WriteFile(path+ConfigSignatureSuffix, b.Bytes(), 0o644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+}
+
+func TestConfigLoader(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	stranger, _, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "service.json")
+	testSignConfig(t, priv, path, `{"listen":":8080","workers":4}`)
+
+	l := &ConfigLoader{Path: path, Keyring: Keyring{pub}}
+	var config struct {
+		Listen  string `json:"listen"`
+		Workers int    `json:"workers"`
+	}
+	key, err := l.LoadJSON(&config)
+	if err != nil || key != pub {
+		t.Fatalf("LoadJSON() failed: %v", err)
+	}
+	if config.Listen != ":8080" || config.Workers != 4 {
+		t.Errorf("LoadJSON() failed: %+v", config)
+	}
+
+	// tampered, unsigned and foreign configurations are refused
+	os.WriteFile(path, []byte(`{"listen":":8080","workers":400}`), 0o644)
+	if _, _, err = l.Load(); err != ErrInvalidSignature {
+		t.Errorf("Load() of tampered configuration failed: %v", err)
+	}
+
+	testSignConfig(t, stranger, path, `{"listen":":8080"}`)
+	if _, _, err = l.Load(); err != ErrKeyNotFound {
+		t.Errorf("Load() with unknown key failed: %v", err)
+	}
+
+	os.Remove(path + ConfigSignatureSuffix)
+	if _, _, err = l.Load(); err != ErrConfigNotSigned {
+		t.Errorf("Load() without signature failed: %v", err)
+	}
+
+	// a signature at another path
+	testSignConfig(t, priv, path, "not json")
+	os.Rename(path+ConfigSignatureSuffix, path+".asc")
+	l.SignaturePath = path + ".asc"
+	if data, _, err := l.Load(); err != nil || string(data) != "not json" {
+		t.Errorf("Load() with signature path failed: %v", err)
+	}
+	if _, err = l.LoadJSON(&config); err == nil {
+		t.Errorf("LoadJSON() of invalid JSON failed")
+	}
+}
+
+func TestConfigLoader_Embedded(t *testing.T) {
+	priv, pub, err := NewPrivateKeyWithVersion(VersionFour)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err = ClearSign(&b, priv, "{\r\n  \"debug\": true  \r\n}\r\n"); err != nil {
+		t.Fatalf("ClearSign() failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "service.json.asc")
+	os.WriteFile(path, b.Bytes(), 0o644)
+
+	l := &ConfigLoader{Path: path, Embedded: true, Keyring: Keyring{pub}}
+	var config struct {
+		Debug bool `json:"debug"`
+	}
+	if _, err = l.LoadJSON(&config); err != nil || !config.Debug {
+		t.Fatalf("LoadJSON() failed: %v", err)
+	}
+
+	os.WriteFile(path, bytes.Replace(b.Bytes(), []byte("true"), []byte("false"), 1), 0o644)
+	if _, _, err = l.Load(); err != ErrInvalidSignature {
+		t.Errorf("Load() of tampered configuration failed: %v", err)
+	}
+
+	os.WriteFile(path, []byte(`{"debug": true}`), 0o644)
+	if _, _, err = l.Load(); err != ErrConfigNotSigned {
+		t.Errorf("Load() without signature failed: %v", err)
+	}
+
+	l.Path = filepath.Join(t.TempDir(), "missing")
+	if _, _, err = l.Load(); !os.IsNotExist(err) {
+		t.Errorf("Load() of missing file failed: %v", err)
+	}
+}
+
+func TestConfigLoader_Watch(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	stranger, _, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "service.conf")
+	l := &ConfigLoader{Path: path, Keyring: Keyring{pub}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err = l.Watch(ctx, time.Millisecond, func([]byte, PublicKey) {}); err != ErrConfigNotSigned {
+		t.Errorf("Watch() without configuration failed: %v", err)
+	}
+
+	testSignConfig(t, priv, path, "v1")
+	loaded := make(chan string, 8)
+	done := make(chan error)
+	go func() {
+		done <- l.Watch(ctx, time.Millisecond, func(data []byte, key PublicKey) {
+			if key != pub {
+				t.Errorf("Watch() failed by key")
+			}
+			loaded <- string(data)
+		})
+	}()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-loaded:
+			if got != want {
+				t.Errorf("Watch() loaded %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Watch() did not load %q", want)
+		}
+	}
+	expect("v1")
+
+	// neither a changed file without signature nor a foreign signature reloads
+	os.WriteFile(path, []byte("v2"), 0o644)
+	sig, _ := stranger.Sign(strings.NewReader("v2"))
+	var b bytes.Buffer
+	Export(&b, sig)
+	os.WriteFile(path+ConfigSignatureSuffix, b.Bytes(), 0o644)
+	time.Sleep(50 * time.Millisecond)
+
+	testSignConfig(t, priv, path, "v3")
+	expect("v3")
+
+	cancel()
+	if err = <-done; err != context.Canceled {
+		t.Errorf("Watch() after cancel failed: %v", err)
+	}
+	if len(loaded) != 0 {
+		t.Errorf("Watch() reloaded without a new valid signature: %q", <-loaded)
+	}
+}
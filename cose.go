@@ -0,0 +1,264 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+)
+
+// COSE_Sign1 messages (RFC 9052) with EdDSA over version 1 keys. The alg is
+// taken from the protected header only and must match the type of the
+// verifying key, the kid is the msign KeyId
+
+const (
+	coseTagSign1   = 18
+	coseSignature1 = "Signature1"
+
+	coseHeaderAlg  = 1
+	coseHeaderCrit = 2
+	coseHeaderKid  = 4
+
+	coseAlgEdDSA = -8
+
+	coseKeyKty = 1
+	coseKeyKid = 2
+	coseKeyAlg = 3
+	coseKeyCrv = -1
+	coseKeyX   = -2
+	coseKeyD   = -4
+
+	coseKtyOKP     = 1
+	coseCrvEd25519 = 6
+)
+
+var (
+	ErrInvalidCOSE   = errors.New("invalid COSE message")
+	ErrCOSEAlgorithm = errors.New("unsupported COSE algorithm")
+)
+
+// coseAlgorithms maps raw signature algorithms to their COSE identifiers
+var coseAlgorithms = map[string]int64{
+	algEdDSA: coseAlgEdDSA,
+}
+
+type coseSign1 struct {
+	protected []byte // serialized protected header
+	alg       string
+	kid       []byte
+	payload   []byte
+	signature []byte
+}
+
+// SignCOSE returns a tagged COSE_Sign1 message of payload, externalAAD is
+// authenticated without being part of the message and may be nil
+func SignCOSE(key PrivateKey, payload, externalAAD []byte) ([]byte, error) {
+	signer, ok := key.(rawSigner)
+	if !ok {
+		return nil, ErrCOSEAlgorithm
+	}
+
+	protected, err := cborMarshal(cborMap{{Key: int64(coseHeaderAlg), Value: coseAlgorithms[signer.rawAlgorithm()]}})
+	if err != nil {
+		return nil, err
+	}
+
+	tbs, err := coseToBeSigned(protected, externalAAD, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := signer.signRaw(tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	unprotected := cborMap{{Key: int64(coseHeaderKid), Value: []byte(key.Id())}}
+	return cborMarshal(cborTag{Number: coseTagSign1, Content: []any{protected, unprotected, payload, sig}})
+}
+
+// VerifyCOSE verifies a tagged or untagged COSE_Sign1 message with the keyring
+// and returns its payload and the key that signed it
+func VerifyCOSE(data []byte, keyring Keyring, externalAAD []byte) ([]byte, PublicKey, error) {
+	m, err := parseCOSESign1(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := false
+	for _, pub := range keyring {
+		if m.kid != nil && !bytes.Equal(pub.Id(), m.kid) {
+			continue
+		}
+		found = true
+
+		err = m.verify(pub, externalAAD)
+		if err == nil {
+			return m.payload, pub, nil
+		}
+
+		// a kid naming a key of another type is algorithm confusion,
+		// without kid every key of the keyring is tried
+		if m.kid != nil {
+			return nil, nil, err
+		}
+	}
+
+	if !found {
+		return nil, nil, ErrKeyNotFound
+	}
+
+	return nil, nil, ErrInvalidSignature
+}
+
+// EncodeCOSEKey returns the COSE_Key of an Ed25519 public key
+func EncodeCOSEKey(pub PublicKey) ([]byte, error) {
+	var key cborMap
+
+	switch p := pub.(type) {
+	case *publicKeyV1:
+		key = cborMap{
+			{Key: int64(coseKeyKty), Value: int64(coseKtyOKP)},
+			{Key: int64(coseKeyAlg), Value: int64(coseAlgEdDSA)},
+			{Key: int64(coseKeyCrv), Value: int64(coseCrvEd25519)},
+			{Key: int64(coseKeyX), Value: p.bytes[:]},
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	key = append(key, cborPair{Key: int64(coseKeyKid), Value: []byte(pub.Id())})
+	return cborMarshal(key)
+}
+
+// DecodeCOSEKey decodes the COSE_Key of a public key, keys holding private
+// material are refused
+func DecodeCOSEKey(data []byte) (PublicKey, error) {
+	v, err := cborUnmarshal(data)
+	if err != nil {
+		return nil, ErrInvalidPubFormat
+	}
+
+	key, ok := v.(cborMap)
+	if !ok {
+		return nil, ErrInvalidPubFormat
+	}
+	if _, ok = key.get(int64(coseKeyD)); ok {
+		return nil, ErrInvalidPubFormat
+	}
+
+	kty, _ := key.get(int64(coseKeyKty))
+	crv, _ := key.get(int64(coseKeyCrv))
+	alg, hasAlg := key.get(int64(coseKeyAlg))
+	x, _ := key.get(int64(coseKeyX))
+	xb, _ := x.([]byte)
+
+	if kty != int64(coseKtyOKP) || crv != int64(coseCrvEd25519) {
+		return nil, ErrUnsupportedFormat
+	}
+	if hasAlg && alg != int64(coseAlgEdDSA) {
+		return nil, ErrCOSEAlgorithm
+	}
+	if len(xb) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPubFormat
+	}
+
+	return publicKeyFromCrypto(ed25519.PublicKey(xb))
+}
+
+// utility functions
+
+func parseCOSESign1(data []byte) (*coseSign1, error) {
+	v, err := cborUnmarshal(data)
+	if err != nil {
+		return nil, ErrInvalidCOSE
+	}
+
+	if tag, ok := v.(cborTag); ok {
+		if tag.Number != coseTagSign1 {
+			return nil, ErrInvalidCOSE
+		}
+		v = tag.Content
+	}
+
+	array, ok := v.([]any)
+	if !ok || len(array) != 4 {
+		return nil, ErrInvalidCOSE
+	}
+
+	// detached payloads (null) are not supported
+	m := &coseSign1{}
+	protectedOk := setAs(&m.protected, array[0])
+	payloadOk := setAs(&m.payload, array[2])
+	signatureOk := setAs(&m.signature, array[3])
+	unprotected, ok := array[1].(cborMap)
+	if !ok || !protectedOk || !payloadOk || !signatureOk {
+		return nil, ErrInvalidCOSE
+	}
+
+	protected := cborMap{}
+	if len(m.protected) != 0 {
+		v, err = cborUnmarshal(m.protected)
+		if protected, ok = v.(cborMap); err != nil || !ok {
+			return nil, ErrInvalidCOSE
+		}
+	}
+
+	// no critical header is understood
+	if _, ok = protected.get(int64(coseHeaderCrit)); ok {
+		return nil, ErrInvalidCOSE
+	}
+
+	alg, _ := protected.get(int64(coseHeaderAlg))
+	for name, id := range coseAlgorithms {
+		if alg == id {
+			m.alg = name
+		}
+	}
+	if m.alg == "" {
+		return nil, ErrCOSEAlgorithm
+	}
+
+	kid, ok := protected.get(int64(coseHeaderKid))
+	if !ok {
+		kid, ok = unprotected.get(int64(coseHeaderKid))
+	}
+	if ok {
+		if m.kid, ok = kid.([]byte); !ok {
+			return nil, ErrInvalidCOSE
+		}
+	}
+
+	return m, nil
+}
+
+func (m *coseSign1) verify(pub PublicKey, externalAAD []byte) error {
+	verifier, ok := pub.(rawVerifier)
+	if !ok || verifier.rawAlgorithm() != m.alg {
+		return ErrCOSEAlgorithm
+	}
+
+	tbs, err := coseToBeSigned(m.protected, externalAAD, m.payload)
+	if err != nil {
+		return err
+	}
+
+	if !verifier.verifyRaw(tbs, m.signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// setAs stores v in dst when it has the type of dst
+func setAs[T any](dst *T, v any) bool {
+	t, ok := v.(T)
+	if ok {
+		*dst = t
+	}
+	return ok
+}
+
+// coseToBeSigned returns the Sig_structure of a COSE_Sign1 message
+func coseToBeSigned(protected, externalAAD, payload []byte) ([]byte, error) {
+	return cborMarshal([]any{coseSignature1, protected, externalAAD, payload})
+}
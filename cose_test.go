@@ -0,0 +1,144 @@
+package msign
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 9052 appendix C.2.1, an ES256 message signed with key "11" of appendix C.7.1
+const testCOSESign1 = "d28443a10126a10442313154546869732069732074686520636f6e74656e742e58408eb33e4ca31d1c465ab05aac34cc6b23d58fef5c083106c4d25a91aef0b0117e2af9a291aa32e14ab834dc56ed2a223444547e01f11d3b0916e5a4c345cacb36"
+
+func TestCOSE_RFC9052(t *testing.T) {
+	data, _ := hex.DecodeString(testCOSESign1)
+
+	v, err := cborUnmarshal(data)
+	if err != nil {
+		t.Fatalf("cborUnmarshal() failed: %v", err)
+	}
+	tag, ok := v.(cborTag)
+	array, _ := tag.Content.([]any)
+	if !ok || tag.Number != coseTagSign1 || len(array) != 4 {
+		t.Fatalf("cborUnmarshal() failed by structure: %#v", v)
+	}
+	unprotected, _ := array[1].(cborMap)
+	kid, _ := unprotected.get(int64(coseHeaderKid))
+	if !bytes.Equal(array[0].([]byte), []byte{0xa1, 0x01, 0x26}) || !bytes.Equal(kid.([]byte), []byte("11")) || string(array[2].([]byte)) != "This is the content." {
+		t.Errorf("cborUnmarshal() failed by content: %#v", array)
+	}
+
+	// only EdDSA is supported
+	if _, err = parseCOSESign1(data); err != ErrCOSEAlgorithm {
+		t.Errorf("parseCOSESign1() failed: %v", err)
+	}
+}
+
+func TestCOSE(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	for _, payload := range [][]byte{nil, []byte("sensor reading 21.5")} {
+		data, err := SignCOSE(priv, payload, []byte("device-7"))
+		if err != nil {
+			t.Fatalf("SignCOSE() failed: %v", err)
+		}
+
+		got, key, err := VerifyCOSE(data, Keyring{other, pub}, []byte("device-7"))
+		if err != nil || !bytes.Equal(got, payload) || key != pub {
+			t.Errorf("VerifyCOSE() failed: %v", err)
+		}
+
+		_, _, err = VerifyCOSE(data, Keyring{pub}, nil)
+		if err != ErrInvalidSignature {
+			t.Errorf("VerifyCOSE() with other AAD failed: %v", err)
+		}
+	}
+
+	// COSE_Key round trip
+	data, err := EncodeCOSEKey(pub)
+	if err != nil {
+		t.Fatalf("EncodeCOSEKey() failed: %v", err)
+	}
+	got, err := DecodeCOSEKey(data)
+	if err != nil || !bytes.Equal(got.Fingerprint(), pub.Fingerprint()) {
+		t.Errorf("DecodeCOSEKey() failed: %v", err)
+	}
+}
+
+func TestCOSE_Bad(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	ecPriv, ecPub, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+	kid := []byte(pub.Id())
+
+	// sign1 builds a message with an arbitrary protected header, signed with priv
+	sign1 := func(protected cborMap, unprotected cborMap, payload any) []byte {
+		p, _ := cborMarshal(protected)
+		var body []byte
+		if b, ok := payload.([]byte); ok {
+			body = b
+		}
+		tbs, _ := coseToBeSigned(p, nil, body)
+		sig, _ := priv.(rawSigner).signRaw(tbs)
+		data, _ := cborMarshal(cborTag{Number: coseTagSign1, Content: []any{p, unprotected, payload, sig}})
+		return data
+	}
+	eddsa := cborMap{{int64(coseHeaderAlg), int64(coseAlgEdDSA)}}
+	kidHeader := cborMap{{int64(coseHeaderKid), kid}}
+
+	inputs := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"valid", sign1(eddsa, kidHeader, []byte("x")), nil},
+		{"alg unprotected", sign1(cborMap{}, cborMap{{int64(coseHeaderAlg), int64(coseAlgEdDSA)}, {int64(coseHeaderKid), kid}}, []byte("x")), ErrCOSEAlgorithm},
+		{"alg ES256 for an Ed25519 kid", sign1(cborMap{{int64(coseHeaderAlg), int64(-7)}}, kidHeader, []byte("x")), ErrCOSEAlgorithm},
+		{"alg HMAC", sign1(cborMap{{int64(coseHeaderAlg), int64(5)}}, kidHeader, []byte("x")), ErrCOSEAlgorithm},
+		{"crit", sign1(cborMap{{int64(coseHeaderAlg), int64(coseAlgEdDSA)}, {int64(coseHeaderCrit), []any{int64(99)}}}, kidHeader, []byte("x")), ErrInvalidCOSE},
+		{"detached", sign1(eddsa, kidHeader, nil), ErrInvalidCOSE},
+		{"no kid", sign1(eddsa, cborMap{}, []byte("x")), nil},
+		{"other tag", []byte{0xd1, 0x80}, ErrInvalidCOSE},
+		{"not CBOR", []byte("hello"), ErrInvalidCOSE},
+	}
+
+	for _, input := range inputs {
+		_, _, err := VerifyCOSE(input.data, Keyring{ecPub, pub}, nil)
+		if err != input.want {
+			t.Errorf("VerifyCOSE() %s failed: %v", input.name, err)
+		}
+	}
+
+	// ES256 is not supported, its raw signatures would be plain version 3 signatures
+	if _, err = SignCOSE(ecPriv, []byte("x"), nil); err != ErrCOSEAlgorithm {
+		t.Errorf("SignCOSE() with ECDSA key failed: %v", err)
+	}
+
+	for _, pub := range []PublicKey{nil, ecPub} {
+		if _, err = EncodeCOSEKey(pub); err != ErrUnsupportedFormat {
+			t.Errorf("EncodeCOSEKey(%v) failed: %v", pub, err)
+		}
+	}
+
+	ec2, _ := cborMarshal(cborMap{{int64(coseKeyKty), int64(2)}, {int64(coseKeyCrv), int64(1)}, {int64(coseKeyX), make([]byte, 32)}, {int64(-3), make([]byte, 32)}})
+	if _, err = DecodeCOSEKey(ec2); err != ErrUnsupportedFormat {
+		t.Errorf("DecodeCOSEKey() of EC2 key failed: %v", err)
+	}
+
+	withD, _ := cborMarshal(cborMap{{int64(coseKeyKty), int64(coseKtyOKP)}, {int64(coseKeyD), []byte{1}}})
+	_, err = DecodeCOSEKey(withD)
+	if err != ErrInvalidPubFormat {
+		t.Errorf("DecodeCOSEKey() with private key failed: %v", err)
+	}
+}
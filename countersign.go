@@ -0,0 +1,259 @@
+package msign
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Countersignatures sign an existing signature: the message signed is the
+// raw binary form of the target, prefixed by its big endian uint32 length,
+// followed by optional metadata such as a role or an approval note. The raw
+// binary form of a countersignature is
+//
+//	version (1) | metadata length, big endian (2) | metadata | signature
+//
+// and it exports as a CSG: line. A chain is a signature followed by
+// countersignatures, each over the link before it including its metadata
+//
+//	SIG:<signature>
+//	CSG:<countersignature of the signature>
+//	CSG:<countersignature of the countersignature>
+
+const (
+	countersignatureVersion = 1
+	maxCountersignMetadata  = 1<<16 - 1
+)
+
+// labelCountersign separates countersigned messages from anything else the
+// same key signs, so a plain signature can't pass as a countersignature
+const labelCountersign = "msign countersignature v1\x00"
+
+var ErrInvalidCountersignature = errors.New("invalid countersignature")
+
+type Countersignature struct {
+	Metadata  []byte
+	Signature Signature
+}
+
+// SignatureChain is a signature and its countersignatures in signing order
+type SignatureChain struct {
+	Signature         Signature
+	Countersignatures []*Countersignature
+}
+
+// ChainSigner is a verified link of a chain
+type ChainSigner struct {
+	Key      PublicKey
+	Metadata []byte // nil for the first signer
+}
+
+// Countersign signs target and metadata, which may be nil, with key
+func Countersign(target Signature, key PrivateKey, metadata []byte) (*Countersignature, error) {
+	if target == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	return countersign(target.marshal(), key, metadata)
+}
+
+// ParseCountersignature decodes the raw binary form of a countersignature
+func ParseCountersignature(data []byte) (*Countersignature, error) {
+	if len(data) < 3 || data[0] != countersignatureVersion {
+		return nil, ErrInvalidCountersignature
+	}
+
+	n := int(binary.BigEndian.Uint16(data[1:]))
+	if len(data) < 3+n {
+		return nil, ErrInvalidCountersignature
+	}
+
+	sig, err := decodeSignature(data[3+n:])
+	if err != nil {
+		return nil, ErrInvalidCountersignature
+	}
+
+	var metadata []byte
+	if n > 0 {
+		metadata = bytes.Clone(data[3 : 3+n])
+	}
+
+	return &Countersignature{Metadata: metadata, Signature: sig}, nil
+}
+
+// ImportCountersignature reads a countersignature exported as CSG: line
+func ImportCountersignature(r io.Reader) (*Countersignature, error) {
+	if r == nil {
+		return nil, ErrNilReader
+	}
+
+	raw, err := importLine(bufio.NewReader(r), PrefixCSG, ErrInvalidCountersignature)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCountersignature(raw)
+}
+
+// Verify checks the countersignature of target with the matching key of
+// keyring and returns that key
+func (c *Countersignature) Verify(target Signature, keyring Keyring) (PublicKey, error) {
+	if target == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	return c.verify(target.marshal(), keyring)
+}
+
+// Countersign appends a countersignature of the last link by key
+func (c *SignatureChain) Countersign(key PrivateKey, metadata []byte) error {
+	if c.Signature == nil {
+		return ErrInvalidSignature
+	}
+
+	cs, err := countersign(c.last(), key, metadata)
+	if err != nil {
+		return err
+	}
+
+	c.Countersignatures = append(c.Countersignatures, cs)
+	return nil
+}
+
+// Verify checks the signature of message and every countersignature with
+// keyring and returns the signers in chain order
+func (c *SignatureChain) Verify(message io.Reader, keyring Keyring) ([]ChainSigner, error) {
+	if c.Signature == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	pub, err := keyring.Verify(message, c.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	signers := []ChainSigner{{Key: pub}}
+	target := c.Signature.marshal()
+	for _, cs := range c.Countersignatures {
+		pub, err = cs.verify(target, keyring)
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, ChainSigner{Key: pub, Metadata: cs.Metadata})
+		target = cs.marshal()
+	}
+
+	return signers, nil
+}
+
+// ReadSignatureChain reads a SIG: line followed by CSG: lines up to the end of r
+func ReadSignatureChain(r io.Reader) (*SignatureChain, error) {
+	if r == nil {
+		return nil, ErrNilReader
+	}
+
+	br := bufio.NewReader(r)
+	raw, err := importLine(br, PrefixSIG, ErrInvalidSigFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := decodeSignature(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &SignatureChain{Signature: sig}
+	for {
+		raw, err = importLine(br, PrefixCSG, ErrInvalidCountersignature)
+		if err == io.EOF {
+			return c, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cs, err := ParseCountersignature(raw)
+		if err != nil {
+			return nil, err
+		}
+		c.Countersignatures = append(c.Countersignatures, cs)
+	}
+}
+
+// utility functions
+
+func countersign(target []byte, key PrivateKey, metadata []byte) (*Countersignature, error) {
+	if key == nil {
+		return nil, ErrNilKey
+	}
+	if len(metadata) > maxCountersignMetadata {
+		return nil, ErrInvalidCountersignature
+	}
+
+	sig, err := key.Sign(bytes.NewReader(countersignMessage(target, metadata)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Countersignature{Metadata: bytes.Clone(metadata), Signature: sig}, nil
+}
+
+func (c *Countersignature) verify(target []byte, keyring Keyring) (PublicKey, error) {
+	if c.Signature == nil || len(c.Metadata) > maxCountersignMetadata {
+		return nil, ErrInvalidCountersignature
+	}
+
+	return keyring.Verify(bytes.NewReader(countersignMessage(target, c.Metadata)), c.Signature)
+}
+
+// countersignMessage is the message a countersignature signs
+func countersignMessage(target, metadata []byte) []byte {
+	m := make([]byte, 0, len(labelCountersign)+4+len(target)+len(metadata))
+	m = append(m, labelCountersign...)
+	m = binary.BigEndian.AppendUint32(m, uint32(len(target)))
+	m = append(m, target...)
+	return append(m, metadata...)
+}
+
+// last returns the raw binary form of the last link
+func (c *SignatureChain) last() []byte {
+	if n := len(c.Countersignatures); n > 0 {
+		return c.Countersignatures[n-1].marshal()
+	}
+	return c.Signature.marshal()
+}
+
+func (c *Countersignature) marshal() []byte {
+	b := []byte{countersignatureVersion}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(c.Metadata)))
+	b = append(b, c.Metadata...)
+	return append(b, c.Signature.marshal()...)
+}
+
+func (c *Countersignature) export(w io.Writer) error {
+	return exportBytes(w, PrefixCSG, c.marshal())
+}
+
+func (c *SignatureChain) export(w io.Writer) error {
+	if c.Signature == nil {
+		return ErrInvalidSignature
+	}
+
+	err := c.Signature.export(w)
+	if err != nil {
+		return err
+	}
+
+	for _, cs := range c.Countersignatures {
+		err = cs.export(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
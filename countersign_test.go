@@ -0,0 +1,204 @@
+package msign
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestCountersign(t *testing.T) {
+	build, buildPub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	qa, qaPub, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	sig, err := build.Sign(strings.NewReader("release"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	other, err := build.Sign(strings.NewReader("other"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	cs, err := Countersign(sig, qa, []byte("qa-approved"))
+	if err != nil {
+		t.Fatalf("Countersign() failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err = Export(&b, cs); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if !strings.HasPrefix(b.String(), PrefixCSG) {
+		t.Errorf("Export() failed by prefix: %s", b.String())
+	}
+
+	got, err := ImportCountersignature(&b)
+	if err != nil || string(got.Metadata) != "qa-approved" {
+		t.Fatalf("ImportCountersignature() failed: %v", err)
+	}
+
+	key, err := got.Verify(sig, Keyring{buildPub, qaPub})
+	if err != nil || key != qaPub {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	_, err = got.Verify(other, Keyring{qaPub})
+	if err != ErrInvalidSignature {
+		t.Errorf("Verify() of other signature failed: %v", err)
+	}
+
+	got.Metadata = []byte("qa-rejected")
+	_, err = got.Verify(sig, Keyring{qaPub})
+	if err != ErrInvalidSignature {
+		t.Errorf("Verify() with other metadata failed: %v", err)
+	}
+
+	// without metadata
+	cs, err = Countersign(sig, qa, nil)
+	if err != nil {
+		t.Fatalf("Countersign() failed: %v", err)
+	}
+	data, _ := Marshal(cs)
+	got, err = ParseCountersignature(data)
+	if err != nil || got.Metadata != nil {
+		t.Fatalf("ParseCountersignature() failed: %v", err)
+	}
+	if _, err = got.Verify(sig, Keyring{qaPub}); err != nil {
+		t.Errorf("Verify() without metadata failed: %v", err)
+	}
+}
+
+func TestSignatureChain(t *testing.T) {
+	var privs []PrivateKey
+	var keyring Keyring
+	for _, version := range []byte{VersionOne, VersionThree, VersionFour} {
+		priv, pub, err := NewPrivateKeyWithVersion(version)
+		if err != nil {
+			t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+		}
+		privs = append(privs, priv)
+		keyring = append(keyring, pub)
+	}
+
+	sig, err := privs[0].Sign(strings.NewReader("release"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	c := &SignatureChain{Signature: sig}
+	if err = c.Countersign(privs[1], []byte("qa")); err != nil {
+		t.Fatalf("Countersign() failed: %v", err)
+	}
+	if err = c.Countersign(privs[2], []byte("release-manager")); err != nil {
+		t.Fatalf("Countersign() failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err = Export(&b, c); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], PrefixSIG) {
+		t.Errorf("Export() failed by lines: %q", lines)
+	}
+
+	got, err := ReadSignatureChain(strings.NewReader("# release chain\n" + b.String()))
+	if err != nil {
+		t.Fatalf("ReadSignatureChain() failed: %v", err)
+	}
+
+	signers, err := got.Verify(strings.NewReader("release"), keyring)
+	if err != nil || len(signers) != 3 {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	for i, want := range []string{"", "qa", "release-manager"} {
+		if signers[i].Key != keyring[i] || string(signers[i].Metadata) != want {
+			t.Errorf("Verify() failed by signer %d: %s", i, signers[i].Metadata)
+		}
+	}
+
+	_, err = got.Verify(strings.NewReader("tampered"), keyring)
+	if err != ErrInvalidSignature {
+		t.Errorf("Verify() of other message failed: %v", err)
+	}
+
+	_, err = got.Verify(strings.NewReader("release"), keyring[:2])
+	if err != ErrKeyNotFound {
+		t.Errorf("Verify() without the last key failed: %v", err)
+	}
+
+	// later links cover the metadata of earlier ones
+	got.Countersignatures[0].Metadata = []byte("qb")
+	_, err = got.Verify(strings.NewReader("release"), keyring)
+	if err != ErrInvalidSignature {
+		t.Errorf("Verify() with other metadata failed: %v", err)
+	}
+
+	// links cannot be dropped from the middle
+	c.Countersignatures = c.Countersignatures[1:]
+	_, err = c.Verify(strings.NewReader("release"), keyring)
+	if err != ErrInvalidSignature {
+		t.Errorf("Verify() without first countersignature failed: %v", err)
+	}
+}
+
+func TestCountersign_Bad(t *testing.T) {
+	priv, _, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	sig, err := priv.Sign(strings.NewReader("release"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	if _, err = Countersign(nil, priv, nil); err != ErrInvalidSignature {
+		t.Errorf("Countersign() of nil signature failed: %v", err)
+	}
+	if _, err = Countersign(sig, nil, nil); err != ErrNilKey {
+		t.Errorf("Countersign() with nil key failed: %v", err)
+	}
+	if _, err = Countersign(sig, priv, make([]byte, 1<<16)); err != ErrInvalidCountersignature {
+		t.Errorf("Countersign() with large metadata failed: %v", err)
+	}
+	if err = (&SignatureChain{}).Countersign(priv, nil); err != ErrInvalidSignature {
+		t.Errorf("Countersign() of empty chain failed: %v", err)
+	}
+
+	cs, _ := Countersign(sig, priv, []byte("qa"))
+	data, _ := Marshal(cs)
+	inputs := [][]byte{nil, {countersignatureVersion, 0}, append([]byte{2}, data[1:]...), data[:5], {countersignatureVersion, 0xff, 0xff, 1}}
+	for _, input := range inputs {
+		if _, err := ParseCountersignature(input); err != ErrInvalidCountersignature {
+			t.Errorf("ParseCountersignature(%x) failed: %v", input, err)
+		}
+	}
+
+	// a plain signature of the unlabeled message is no countersignature
+	msg := binary.BigEndian.AppendUint32(nil, uint32(len(sig.marshal())))
+	msg = append(append(msg, sig.marshal()...), "qa"...)
+	plain, err := priv.Sign(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	forged := &Countersignature{Metadata: []byte("qa"), Signature: plain}
+	if _, err = forged.Verify(sig, Keyring{priv.Public()}); err != ErrInvalidSignature {
+		t.Errorf("Verify() of plain signature failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	Export(&b, sig)
+	b.WriteString("PUB:AAAA\n")
+	if _, err = ReadSignatureChain(&b); err != ErrInvalidCountersignature {
+		t.Errorf("ReadSignatureChain() with other line failed: %v", err)
+	}
+	if _, err = ReadSignatureChain(strings.NewReader("CSG:AAAA\n")); err != ErrInvalidSigFormat {
+		t.Errorf("ReadSignatureChain() without signature failed: %v", err)
+	}
+}
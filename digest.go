@@ -0,0 +1,142 @@
+package msign
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"io"
+	"slices"
+)
+
+// Digest identifies the message digest algorithm a signature is computed over
+type Digest byte
+
+const (
+	DigestSHA512   Digest = 1 // SHA-512 (implied by version 1 signatures)
+	DigestSHA256   Digest = 2 // SHA-256
+	DigestSHA3_256 Digest = 3 // SHA3-256
+	DigestSHA3_512 Digest = 4 // SHA3-512
+)
+
+func (d Digest) String() string {
+	switch d {
+	case DigestSHA512:
+		return "SHA-512"
+	case DigestSHA256:
+		return "SHA-256"
+	case DigestSHA3_256:
+		return "SHA3-256"
+	case DigestSHA3_512:
+		return "SHA3-512"
+	}
+
+	return "unknown"
+}
+
+// parseDigest returns the digest named by String
+func parseDigest(name string) (Digest, error) {
+	for _, d := range []Digest{DigestSHA512, DigestSHA256, DigestSHA3_256, DigestSHA3_512} {
+		if d.String() == name {
+			return d, nil
+		}
+	}
+
+	return 0, ErrUnknownDigest
+}
+
+// Available reports whether the digest algorithm is known to this package
+func (d Digest) Available() bool {
+	_, err := d.New()
+	return err == nil
+}
+
+// New returns a new hash.Hash computing the digest
+func (d Digest) New() (hash.Hash, error) {
+	switch d {
+	case DigestSHA512:
+		return sha512.New(), nil
+	case DigestSHA256:
+		return sha256.New(), nil
+	case DigestSHA3_256:
+		return sha3.New256(), nil
+	case DigestSHA3_512:
+		return sha3.New512(), nil
+	}
+
+	return nil, ErrUnknownDigest
+}
+
+// hash returns the crypto.Hash matching the digest, zero when unknown
+func (d Digest) hash() crypto.Hash {
+	switch d {
+	case DigestSHA512:
+		return crypto.SHA512
+	case DigestSHA256:
+		return crypto.SHA256
+	case DigestSHA3_256:
+		return crypto.SHA3_256
+	case DigestSHA3_512:
+		return crypto.SHA3_512
+	}
+
+	return 0
+}
+
+// prehashed stands in for a message already hashed while streaming it,
+// sign and verify paths take its sum instead of reading a message
+type prehashed struct {
+	digest Digest
+	sum    []byte
+}
+
+func (p *prehashed) Read([]byte) (int, error) {
+	return 0, errPrehashed
+}
+
+var errPrehashed = errors.New("prehashed message can't be read")
+
+// sum hashes the whole message with the digest algorithm
+func (d Digest) sum(message io.Reader) ([]byte, error) {
+	if p, ok := message.(*prehashed); ok {
+		if p.digest != d {
+			return nil, ErrInvalidSignature
+		}
+		return p.sum, nil
+	}
+
+	h, err := d.New()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(h, message)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// digest returns the requested digest, zero when the default one should be used
+func (o *SignOptions) digest() Digest {
+	if o == nil {
+		return 0
+	}
+	return o.Digest
+}
+
+// allowed reports whether a signature made with the digest may be accepted
+func (o *VerifyOptions) allowed(d Digest) bool {
+	if !d.Available() {
+		return false
+	}
+
+	if o == nil || len(o.AllowedDigests) == 0 {
+		return true
+	}
+
+	return slices.Contains(o.AllowedDigests, d)
+}
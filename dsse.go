@@ -0,0 +1,114 @@
+package msign
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// DSSE envelopes (Dead Simple Signing Envelope v1). Each signature signs the
+// pre-authentication encoding of payload type and payload with PrivateKey.Sign,
+// sig holds the raw binary form of the msign signature and keyid its KeyId
+
+const dssePrefix = "DSSEv1"
+
+var (
+	ErrInvalidEnvelope = errors.New("invalid DSSE envelope")
+	ErrThreshold       = errors.New("not enough valid signatures")
+)
+
+// DSSEEnvelope is the JSON envelope, byte fields encode as standard base64
+type DSSEEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     []byte          `json:"payload"`
+	Signatures  []DSSESignature `json:"signatures"`
+}
+
+type DSSESignature struct {
+	KeyId string `json:"keyid"`
+	Sig   []byte `json:"sig"`
+}
+
+// SignDSSE returns an envelope of payload signed by every key
+func SignDSSE(payloadType string, payload []byte, keys ...PrivateKey) (*DSSEEnvelope, error) {
+	e := &DSSEEnvelope{PayloadType: payloadType, Payload: payload}
+
+	for _, key := range keys {
+		err := e.Sign(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// Sign appends the signature of key, e.g. for a second party co-signing
+func (e *DSSEEnvelope) Sign(key PrivateKey) error {
+	if key == nil {
+		return ErrNilKey
+	}
+
+	sig, err := key.Sign(bytes.NewReader(dssePAE(e.PayloadType, e.Payload)))
+	if err != nil {
+		return err
+	}
+
+	e.Signatures = append(e.Signatures, DSSESignature{KeyId: key.Id().String(), Sig: sig.marshal()})
+	return nil
+}
+
+// Verify checks the signatures with the keyring and returns the distinct keys
+// that signed the envelope, failing with ErrThreshold below threshold keys.
+// Invalid signatures and signatures of unknown keys are skipped
+func (e *DSSEEnvelope) Verify(keyring Keyring, threshold int) ([]PublicKey, error) {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	pae := dssePAE(e.PayloadType, e.Payload)
+
+	var signers []PublicKey
+	for _, s := range e.Signatures {
+		sig, err := decodeSignature(s.Sig)
+		if err != nil || sig.KeyId().String() != s.KeyId {
+			continue
+		}
+
+		pub, err := keyring.Verify(bytes.NewReader(pae), sig)
+		if err != nil || containsKey(signers, pub) {
+			continue
+		}
+
+		signers = append(signers, pub)
+	}
+
+	if len(signers) < threshold {
+		return nil, ErrThreshold
+	}
+
+	return signers, nil
+}
+
+// utility functions
+
+// dssePAE is the pre-authentication encoding of DSSE:
+// "DSSEv1" SP LEN(type) SP type SP LEN(body) SP body
+func dssePAE(payloadType string, payload []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(dssePrefix + " ")
+	b.WriteString(strconv.Itoa(len(payloadType)) + " " + payloadType + " ")
+	b.WriteString(strconv.Itoa(len(payload)) + " ")
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// containsKey reports whether keys holds a key with the fingerprint of pub
+func containsKey(keys []PublicKey, pub PublicKey) bool {
+	for _, k := range keys {
+		if bytes.Equal(k.Fingerprint(), pub.Fingerprint()) {
+			return true
+		}
+	}
+	return false
+}
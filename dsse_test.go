@@ -0,0 +1,116 @@
+package msign
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDSSEPAE(t *testing.T) {
+	// example of the DSSE protocol specification
+	got := string(dssePAE("http://example.com/HelloWorld", []byte("hello world")))
+	want := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+	if got != want {
+		t.Errorf("dssePAE() failed: %q", got)
+	}
+
+	if got = string(dssePAE("", nil)); got != "DSSEv1 0  0 " {
+		t.Errorf("dssePAE() of empty input failed: %q", got)
+	}
+}
+
+func TestDSSE(t *testing.T) {
+	var privs []PrivateKey
+	var keyring Keyring
+	for _, version := range []byte{VersionOne, VersionThree, VersionFour} {
+		priv, pub, err := NewPrivateKeyWithVersion(version)
+		if err != nil {
+			t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+		}
+		privs = append(privs, priv)
+		keyring = append(keyring, pub)
+	}
+
+	e, err := SignDSSE("text/plain", []byte("release 1.2.0"), privs[0], privs[1])
+	if err != nil {
+		t.Fatalf("SignDSSE() failed: %v", err)
+	}
+	if len(e.Signatures) != 2 || e.Signatures[0].KeyId != privs[0].Id().String() {
+		t.Errorf("SignDSSE() failed by signatures: %+v", e.Signatures)
+	}
+
+	// round trip through JSON
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	var got DSSEEnvelope
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+
+	signers, err := got.Verify(keyring, 2)
+	if err != nil || len(signers) != 2 || signers[0] != keyring[0] || signers[1] != keyring[1] {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	_, err = got.Verify(keyring, 3)
+	if err != ErrThreshold {
+		t.Errorf("Verify() with threshold 3 failed: %v", err)
+	}
+
+	// a co-signer raises the count, a repeated signature does not
+	if err = got.Sign(privs[2]); err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	got.Signatures = append(got.Signatures, got.Signatures[0])
+	signers, err = got.Verify(keyring, 3)
+	if err != nil || len(signers) != 3 {
+		t.Errorf("Verify() after co-signing failed: %v", err)
+	}
+
+	// payload type is authenticated
+	got.PayloadType = "application/json"
+	_, err = got.Verify(keyring, 1)
+	if err != ErrThreshold {
+		t.Errorf("Verify() with other payload type failed: %v", err)
+	}
+}
+
+func TestDSSE_Bad(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	_, err = SignDSSE("text/plain", nil, nil)
+	if err != ErrNilKey {
+		t.Errorf("SignDSSE() with nil key failed: %v", err)
+	}
+
+	e, err := SignDSSE("text/plain", []byte("x"), priv)
+	if err != nil {
+		t.Fatalf("SignDSSE() failed: %v", err)
+	}
+
+	_, err = e.Verify(Keyring{other}, 1)
+	if err != ErrThreshold {
+		t.Errorf("Verify() with other key failed: %v", err)
+	}
+
+	// keyid must name the signing key
+	e.Signatures[0].KeyId = other.Id().String()
+	_, err = e.Verify(Keyring{pub}, 1)
+	if err != ErrThreshold {
+		t.Errorf("Verify() with other keyid failed: %v", err)
+	}
+
+	e.Signatures[0] = DSSESignature{KeyId: pub.Id().String(), Sig: []byte("garbage")}
+	_, err = e.Verify(Keyring{pub}, 1)
+	if err != ErrThreshold {
+		t.Errorf("Verify() with garbage signature failed: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package msign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// Signed envelopes carry a short message, such as a command or an API call,
+// with its creation time and a random nonce, so verifiers reject it once
+// stale or seen before. The raw binary form is
+//
+//	version (1) | created, big endian unix nanoseconds (8) | nonce (16) | payload length, big endian (4) | payload | signature
+//
+// and the signature covers everything before it, prefixed by a fixed label.
+// Envelopes export as ENV: lines
+
+const (
+	envelopeVersion  = 1
+	sizeEnvelopeHead = 1 + 8 + sizeEnvelopeNonce + 4
+
+	sizeEnvelopeNonce = 16
+
+	DefaultEnvelopeMaxAge = 5 * time.Minute
+
+	labelEnvelope = "msign signed envelope v1\x00"
+)
+
+var ErrInvalidSignedEnvelope = errors.New("invalid signed envelope")
+
+type SignedEnvelope struct {
+	Created   time.Time
+	Nonce     []byte
+	Payload   []byte
+	Signature Signature
+}
+
+// NewSignedEnvelope signs payload with key at the current time and a fresh nonce
+func NewSignedEnvelope(payload []byte, key PrivateKey) (*SignedEnvelope, error) {
+	if key == nil {
+		return nil, ErrNilKey
+	}
+
+	e := &SignedEnvelope{Created: time.Now().UTC(), Nonce: make([]byte, sizeEnvelopeNonce), Payload: bytes.Clone(payload)}
+	_, err := rand.Read(e.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Signature, err = key.Sign(bytes.NewReader(e.message()))
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// ParseSignedEnvelope decodes the raw binary form of an envelope, see Marshal
+func ParseSignedEnvelope(data []byte) (*SignedEnvelope, error) {
+	if len(data) < sizeEnvelopeHead || data[0] != envelopeVersion {
+		return nil, ErrInvalidSignedEnvelope
+	}
+
+	n := int(binary.BigEndian.Uint32(data[sizeEnvelopeHead-4:]))
+	if len(data)-sizeEnvelopeHead < n {
+		return nil, ErrInvalidSignedEnvelope
+	}
+
+	sig, err := decodeSignature(data[sizeEnvelopeHead+n:])
+	if err != nil {
+		return nil, ErrInvalidSignedEnvelope
+	}
+
+	return &SignedEnvelope{
+		Created:   time.Unix(0, int64(binary.BigEndian.Uint64(data[1:]))).UTC(),
+		Nonce:     bytes.Clone(data[9 : 9+sizeEnvelopeNonce]),
+		Payload:   bytes.Clone(data[sizeEnvelopeHead : sizeEnvelopeHead+n]),
+		Signature: sig,
+	}, nil
+}
+
+// ImportSignedEnvelope reads an envelope exported as ENV: line
+func ImportSignedEnvelope(r io.Reader) (*SignedEnvelope, error) {
+	if r == nil {
+		return nil, ErrNilReader
+	}
+
+	raw, err := importLine(bufio.NewReader(r), PrefixENV, ErrInvalidSignedEnvelope)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSignedEnvelope(raw)
+}
+
+// Verify checks the signature of the envelope with keyring and returns the
+// signing key. The envelope must be created within maxAge of now, zero uses
+// DefaultEnvelopeMaxAge, and its nonce is recorded in nonces, which rejects
+// a replay with ErrRequestReplayed. A nil cache skips the replay check
+func (e *SignedEnvelope) Verify(keyring Keyring, nonces NonceCache, maxAge time.Duration) (PublicKey, error) {
+	return e.verify(keyring, nonces, maxAge, time.Now())
+}
+
+// utility functions
+
+func (e *SignedEnvelope) verify(keyring Keyring, nonces NonceCache, maxAge time.Duration, now time.Time) (PublicKey, error) {
+	if e.Signature == nil || len(e.Nonce) != sizeEnvelopeNonce {
+		return nil, ErrInvalidSignedEnvelope
+	}
+
+	if maxAge <= 0 {
+		maxAge = DefaultEnvelopeMaxAge
+	}
+	if e.Created.Before(now.Add(-maxAge)) || e.Created.After(now.Add(maxAge)) {
+		return nil, ErrRequestStale
+	}
+
+	pub, err := keyring.Verify(bytes.NewReader(e.message()), e.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// only authentic envelopes may occupy the cache, for as long as they would be accepted
+	if nonces != nil {
+		fresh, err := nonces.Add(e.Signature.KeyId().String()+"/"+base64.RawURLEncoding.EncodeToString(e.Nonce), now, 2*maxAge)
+		if err != nil {
+			return nil, err
+		}
+		if !fresh {
+			return nil, ErrRequestReplayed
+		}
+	}
+
+	return pub, nil
+}
+
+// message is the message the envelope signature signs
+func (e *SignedEnvelope) message() []byte {
+	return append([]byte(labelEnvelope), e.body()...)
+}
+
+// body returns the raw binary form before the signature
+func (e *SignedEnvelope) body() []byte {
+	b := make([]byte, sizeEnvelopeHead, sizeEnvelopeHead+len(e.Payload))
+	b[0] = envelopeVersion
+	binary.BigEndian.PutUint64(b[1:], uint64(e.Created.UnixNano()))
+	copy(b[9:], e.Nonce)
+	binary.BigEndian.PutUint32(b[sizeEnvelopeHead-4:], uint32(len(e.Payload)))
+	return append(b, e.Payload...)
+}
+
+func (e *SignedEnvelope) marshal() []byte {
+	return append(e.body(), e.Signature.marshal()...)
+}
+
+func (e *SignedEnvelope) export(w io.Writer) error {
+	return exportBytes(w, PrefixENV, e.marshal())
+}
@@ -0,0 +1,114 @@
+package msign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedEnvelope(t *testing.T) {
+	priv, pub, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	e, err := NewSignedEnvelope([]byte(`{"cmd":"restart"}`), priv)
+	if err != nil {
+		t.Fatalf("NewSignedEnvelope() failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err = Export(&b, e); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if !strings.HasPrefix(b.String(), PrefixENV) {
+		t.Errorf("Export() failed by prefix: %s", b.String())
+	}
+
+	got, err := ImportSignedEnvelope(&b)
+	if err != nil {
+		t.Fatalf("ImportSignedEnvelope() failed: %v", err)
+	}
+	if string(got.Payload) != `{"cmd":"restart"}` || !got.Created.Equal(e.Created) || !bytes.Equal(got.Nonce, e.Nonce) {
+		t.Errorf("ImportSignedEnvelope() failed: %+v", got)
+	}
+
+	nonces := NewMemoryNonceCache(0)
+	key, err := got.Verify(Keyring{other, pub}, nonces, 0)
+	if err != nil || key != pub {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if _, err = got.Verify(Keyring{pub}, nonces, 0); err != ErrRequestReplayed {
+		t.Errorf("Verify() of replay failed: %v", err)
+	}
+	if _, err = got.Verify(Keyring{pub}, nil, 0); err != nil {
+		t.Errorf("Verify() without cache failed: %v", err)
+	}
+
+	// the replay is remembered for as long as the envelope is accepted
+	if _, err = got.verify(Keyring{pub}, nonces, 0, e.Created.Add(DefaultEnvelopeMaxAge)); err != ErrRequestReplayed {
+		t.Errorf("verify() of late replay failed: %v", err)
+	}
+
+	now := e.Created
+	inputs := []struct {
+		name   string
+		modify func(e *SignedEnvelope)
+		maxAge time.Duration
+		at     time.Time
+		want   error
+	}{
+		{"payload", func(e *SignedEnvelope) { e.Payload = []byte(`{"cmd":"shutdown"}`) }, 0, now, ErrInvalidSignature},
+		{"nonce", func(e *SignedEnvelope) { e.Nonce = make([]byte, sizeEnvelopeNonce) }, 0, now, ErrInvalidSignature},
+		{"created", func(e *SignedEnvelope) { e.Created = e.Created.Add(time.Second) }, 0, now, ErrInvalidSignature},
+		{"short nonce", func(e *SignedEnvelope) { e.Nonce = e.Nonce[:8] }, 0, now, ErrInvalidSignedEnvelope},
+		{"no signature", func(e *SignedEnvelope) { e.Signature = nil }, 0, now, ErrInvalidSignedEnvelope},
+		{"stale", func(e *SignedEnvelope) {}, 0, now.Add(6 * time.Minute), ErrRequestStale},
+		{"future", func(e *SignedEnvelope) {}, 0, now.Add(-6 * time.Minute), ErrRequestStale},
+		{"max age", func(e *SignedEnvelope) {}, time.Hour, now.Add(6 * time.Minute), nil},
+	}
+
+	for _, input := range inputs {
+		c := *e
+		input.modify(&c)
+		if _, err := c.verify(Keyring{pub}, NewMemoryNonceCache(0), input.maxAge, input.at); err != input.want {
+			t.Errorf("verify() %s failed: %v", input.name, err)
+		}
+	}
+}
+
+func TestSignedEnvelope_Bad(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	if _, err = NewSignedEnvelope(nil, nil); err != ErrNilKey {
+		t.Errorf("NewSignedEnvelope() with nil key failed: %v", err)
+	}
+
+	// a plain signature of the unlabeled bytes is no envelope signature
+	plain, _ := NewSignedEnvelope([]byte("payload"), priv)
+	plain.Signature, _ = priv.Sign(bytes.NewReader(plain.body()))
+	if _, err = plain.Verify(Keyring{pub}, nil, 0); err != ErrInvalidSignature {
+		t.Errorf("Verify() of plain signature failed: %v", err)
+	}
+
+	e, _ := NewSignedEnvelope([]byte("payload"), priv)
+	data, _ := Marshal(e)
+	inputs := [][]byte{nil, data[:sizeEnvelopeHead-1], append([]byte{2}, data[1:]...), data[:sizeEnvelopeHead+3], data[:len(data)-1]}
+	for _, input := range inputs {
+		if _, err := ParseSignedEnvelope(input); err != ErrInvalidSignedEnvelope {
+			t.Errorf("ParseSignedEnvelope(%x) failed: %v", input, err)
+		}
+	}
+
+	if _, err = ImportSignedEnvelope(strings.NewReader("SIG:AAAA\n")); err != ErrInvalidSignedEnvelope {
+		t.Errorf("ImportSignedEnvelope() of other line failed: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// msign version 6 implementation, a signature of any other version wrapped
+// together with the full fingerprint of its key
+
+const (
+	sizeFingerprint = sha256.Size // fingerprint size in bytes
+	sizeGroup       = 2           // bytes per group of the grouped hex rendering
+	emojiBase       = 0x1F400     // first code point of the emoji rendering table
+)
+
+// Fingerprint is the SHA-256 of the key public material, the KeyId is its prefix
+type Fingerprint []byte
+
+func (f Fingerprint) String() string {
+	return hex.EncodeToString(f)
+}
+
+// Grouped renders the fingerprint as upper case hex in space separated groups,
+// e.g. "386E 418A 77CA ..."
+func (f Fingerprint) Grouped() string {
+	var sb strings.Builder
+	for i := 0; i < len(f); i += sizeGroup {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strings.ToUpper(hex.EncodeToString(f[i:min(i+sizeGroup, len(f))])))
+	}
+	return sb.String()
+}
+
+// Base32 renders the fingerprint as unpadded RFC 4648 base32
+func (f Fingerprint) Base32() string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(f)
+}
+
+// Emoji renders every byte of the fingerprint as one emoji, slice the
+// fingerprint first for a shorter rendering
+func (f Fingerprint) Emoji() string {
+	var sb strings.Builder
+	for _, b := range f {
+		sb.WriteRune(rune(emojiBase + int(b)))
+	}
+	return sb.String()
+}
+
+// KeyId returns the short key id matching the fingerprint
+func (f Fingerprint) KeyId() KeyId {
+	if len(f) < sizeIDv1 {
+		return nil
+	}
+
+	id := make(KeyId, sizeIDv1)
+	copy(id, f[:sizeIDv1])
+	return id
+}
+
+type signatureV6 struct {
+	fingerprint [sizeFingerprint]byte
+	inner       Signature
+}
+
+func (s *signatureV6) KeyId() KeyId {
+	return s.inner.KeyId()
+}
+
+func (s *signatureV6) Fingerprint() Fingerprint {
+	fp := make(Fingerprint, sizeFingerprint)
+	copy(fp, s.fingerprint[:])
+	return fp
+}
+
+func (s *signatureV6) Digest() Digest {
+	return s.inner.Digest()
+}
+
+func (s *signatureV6) marshal() []byte {
+	inner := s.inner.marshal()
+
+	sigmsg := make([]byte, sizeVersion+sizeCheckv1+sizeFingerprint+len(inner))
+	sigmsg[0] = VersionSix // version
+
+	copy(sigmsg[sizeVersion+sizeCheckv1:], s.fingerprint[:])      // copy fingerprint
+	copy(sigmsg[sizeVersion+sizeCheckv1+sizeFingerprint:], inner) // copy wrapped signature
+
+	check := sha256.Sum256(sigmsg[sizeVersion+sizeCheckv1:])
+	copy(sigmsg[sizeVersion:], check[:sizeCheckv1]) // copy check
+
+	return sigmsg
+}
+
+func (s *signatureV6) export(w io.Writer) error {
+	return exportBytes(w, PrefixSIG, s.marshal())
+}
+
+func (s *signatureV6) MarshalText() ([]byte, error) {
+	return textOf(PrefixSIG, s), nil
+}
+
+// utility functions
+
+// fingerprintOf hashes the public material of a key
+func fingerprintOf(public []byte) Fingerprint {
+	sum := sha256.Sum256(public)
+	return sum[:]
+}
+
+// embed wraps the signature into version 6 when the options ask for it
+func (o *SignOptions) embed(sig Signature, fingerprint Fingerprint) Signature {
+	if o == nil || !o.EmbedFingerprint {
+		return sig
+	}
+
+	wrapped := &signatureV6{inner: sig}
+	copy(wrapped.fingerprint[:], fingerprint)
+	return wrapped
+}
+
+// innerSignature unwraps a version 6 signature after matching its fingerprint
+// with the verifying key, other versions are returned as they are.
+// Holders of a signature are unwrapped first
+func innerSignature(sign Signature, fingerprint Fingerprint) (Signature, error) {
+	// pointers first, the value methods of a nil pointer would panic
+	switch h := sign.(type) {
+	case signatureHolder:
+		sign = h.heldSignature()
+	case signatureHolderValue:
+		sign = h.holder().heldSignature()
+	}
+
+	sig, ok := sign.(*signatureV6)
+	if !ok {
+		return sign, nil
+	}
+
+	if !bytes.Equal(sig.fingerprint[:], fingerprint) {
+		return nil, ErrKeyIdMismatch
+	}
+
+	return sig.inner, nil
+}
+
+func getSignatureV6(sign []byte) (Signature, error) {
+	if len(sign) <= sizeVersion+sizeCheckv1+sizeFingerprint+sizeVersion {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if sign[0] != VersionSix {
+		return nil, ErrInvalidSigFormat
+	}
+
+	// check
+	check := sha256.Sum256(sign[sizeVersion+sizeCheckv1:])
+	if !bytes.Equal(check[:sizeCheckv1], sign[sizeVersion:sizeVersion+sizeCheckv1]) {
+		return nil, ErrInvalidSigFormat
+	}
+
+	inner := sign[sizeVersion+sizeCheckv1+sizeFingerprint:]
+	if inner[0] == VersionSix {
+		return nil, ErrInvalidSigFormat // no nesting
+	}
+
+	sig, err := decodeSignature(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := &signatureV6{inner: sig}
+	copy(signature.fingerprint[:], sign[sizeVersion+sizeCheckv1:])
+
+	// the short id must be the fingerprint prefix
+	if !bytes.Equal(sig.KeyId(), signature.fingerprint[:sizeIDv1]) {
+		return nil, ErrInvalidSigFormat
+	}
+
+	return signature, nil
+}
+
+// Sanity check types implement the interfaces
+var (
+	_ Signature = &signatureV6{}
+)
@@ -0,0 +1,133 @@
+package msign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	pub, err := ImportPublicKey(strings.NewReader(testPublicKey))
+	if err != nil {
+		t.Fatalf("ImportPublicKey() failed: %v", err)
+	}
+	priv, err := ImportPrivateKey(strings.NewReader(testPrivateKey))
+	if err != nil {
+		t.Fatalf("ImportPrivateKey() failed: %v", err)
+	}
+
+	fp := pub.Fingerprint()
+	if len(fp) != sizeFingerprint {
+		t.Errorf("Fingerprint() failed by size: %v", len(fp))
+	}
+
+	if !bytes.Equal(fp, priv.Fingerprint()) {
+		t.Errorf("Fingerprint() keys are different: %v != %v", fp, priv.Fingerprint())
+	}
+
+	if fp.KeyId().String() != testKeyID || !strings.HasPrefix(fp.String(), testKeyID) {
+		t.Errorf("Fingerprint() failed by key id mismatch: %v", fp)
+	}
+}
+
+func TestFingerprint_AllVersions(t *testing.T) {
+	for _, version := range testKeyVersions {
+		priv, pub, err := NewPrivateKeyWithVersion(version)
+		if err != nil {
+			t.Fatalf("NewPrivateKeyWithVersion(%d) failed: %v", version, err)
+		}
+
+		if !bytes.Equal(priv.Fingerprint(), pub.Fingerprint()) {
+			t.Errorf("Fingerprint(%d) keys are different", version)
+		}
+
+		if !bytes.Equal(pub.Fingerprint().KeyId(), pub.Id()) {
+			t.Errorf("Fingerprint(%d) failed by key id mismatch", version)
+		}
+	}
+}
+
+func TestFingerprint_Renderings(t *testing.T) {
+	fp := Fingerprint{0x38, 0x6e, 0x41, 0x8a, 0x77}
+
+	if fp.Grouped() != "386E 418A 77" {
+		t.Errorf("Grouped() failed: %v", fp.Grouped())
+	}
+
+	if fp.Base32() != "HBXEDCTX" {
+		t.Errorf("Base32() failed: %v", fp.Base32())
+	}
+
+	if fp.Emoji() != "\U0001F438\U0001F46E\U0001F441\U0001F48A\U0001F477" {
+		t.Errorf("Emoji() failed: %v", fp.Emoji())
+	}
+
+	if Fingerprint(nil).KeyId() != nil {
+		t.Errorf("KeyId() failed: %v", Fingerprint(nil).KeyId())
+	}
+}
+
+func TestSignatureV6(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	sig, err := priv.SignWithOptions(strings.NewReader("Hello World!"), &SignOptions{EmbedFingerprint: true})
+	if err != nil {
+		t.Fatalf("SignWithOptions() failed: %v", err)
+	}
+
+	if !bytes.Equal(sig.Fingerprint(), pub.Fingerprint()) {
+		t.Errorf("Fingerprint() failed: %v", sig.Fingerprint())
+	}
+
+	buf := new(bytes.Buffer)
+	if err = Export(buf, sig); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+
+	sig2, err := ImportSignature(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("ImportSignature() failed: %v", err)
+	}
+
+	if !bytes.Equal(sig2.Fingerprint(), pub.Fingerprint()) || !bytes.Equal(sig2.KeyId(), pub.Id()) {
+		t.Errorf("ImportSignature() failed by fingerprint mismatch: %v", sig2.Fingerprint())
+	}
+
+	v, err := pub.Verify(strings.NewReader("Hello World!"), sig2)
+	if err != nil || !v {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	// a wrong fingerprint around a valid signature must be refused
+	bad := *sig2.(*signatureV6)
+	bad.fingerprint[sizeFingerprint-1] ^= 0x01
+	_, err = pub.Verify(strings.NewReader("Hello World!"), &bad)
+	if err != ErrKeyIdMismatch {
+		t.Errorf("Verify() failed: %v", err)
+	}
+}
+
+func TestSignatureV6_WithDigest(t *testing.T) {
+	priv, pub, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	opts := &SignOptions{Digest: DigestSHA3_256, EmbedFingerprint: true}
+	sig, err := priv.SignWithOptions(strings.NewReader("Hello World!"), opts)
+	if err != nil {
+		t.Fatalf("SignWithOptions() failed: %v", err)
+	}
+
+	if sig.Digest() != DigestSHA3_256 {
+		t.Errorf("Digest() failed: %v", sig.Digest())
+	}
+
+	v, err := pub.VerifyWithOptions(strings.NewReader("Hello World!"), sig, &VerifyOptions{AllowedDigests: []Digest{DigestSHA3_256}})
+	if err != nil || !v {
+		t.Errorf("VerifyWithOptions() failed: %v", err)
+	}
+}
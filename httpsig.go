@@ -0,0 +1,667 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP Message Signatures (RFC 9421) with the ed25519 algorithm over version 1
+// keys. The keyid parameter is the KeyId in hex. Covered components are
+// header fields and the derived components @method, @authority, @scheme,
+// @target-uri, @request-target, @path and @query; content-digest is checked
+// against the body (RFC 9530, sha-256 and sha-512)
+
+const (
+	SignatureInputHeader = "Signature-Input"
+	SignatureHeader      = "Signature"
+
+	HTTPSigAlgorithm = "ed25519"
+
+	defaultSignatureLabel = "sig"
+)
+
+var (
+	ErrInvalidHTTPSignature = errors.New("invalid HTTP message signature")
+	ErrHTTPSigKey           = errors.New("HTTP message signatures require an Ed25519 key")
+)
+
+// DefaultHTTPSigComponents are covered when HTTPSignOptions.Components is empty
+var DefaultHTTPSigComponents = []string{"@method", "@path", "@query", "@authority", "content-digest"}
+
+type HTTPSignOptions struct {
+	Label      string    // signature label, empty uses "sig"
+	Components []string  // covered components, empty uses DefaultHTTPSigComponents
+	Expires    time.Time // expires parameter, zero omits it
+	Nonce      string    // nonce parameter, empty omits it
+	Tag        string    // tag parameter, empty omits it
+}
+
+type HTTPVerifyOptions struct {
+	Label      string        // label of the signature to verify, empty accepts any
+	Components []string      // components the signature must cover
+	MaxAge     time.Duration // accepted age of created, zero uses DefaultRequestMaxAge
+	Tag        string        // required tag parameter, empty accepts any
+}
+
+// SignHTTPRequest adds Signature-Input and Signature fields for a signature of
+// r by key. A covered content-digest is computed from the body when r has none
+func SignHTTPRequest(r *http.Request, key PrivateKey, opts *HTTPSignOptions) error {
+	if key == nil {
+		return ErrNilKey
+	}
+	if opts == nil {
+		opts = &HTTPSignOptions{}
+	}
+
+	label := opts.Label
+	if label == "" {
+		label = defaultSignatureLabel
+	}
+	components := opts.Components
+	if len(components) == 0 {
+		components = DefaultHTTPSigComponents
+	}
+
+	if slices.Contains(components, "content-digest") && r.Header.Get(ContentDigestHeader) == "" {
+		body, err := readBody(r, DefaultMaxBodySize)
+		if err != nil {
+			return err
+		}
+		r.Header.Set(ContentDigestHeader, contentDigest(body))
+	}
+
+	params := []sfParam{
+		{"created", time.Now().Unix()},
+		{"keyid", key.Id().String()},
+		{"alg", HTTPSigAlgorithm},
+	}
+	if !opts.Expires.IsZero() {
+		params = append(params, sfParam{"expires", opts.Expires.Unix()})
+	}
+	if opts.Nonce != "" {
+		params = append(params, sfParam{"nonce", opts.Nonce})
+	}
+	if opts.Tag != "" {
+		params = append(params, sfParam{"tag", opts.Tag})
+	}
+
+	return signHTTPRequest(r, key, label, components, params)
+}
+
+// VerifyHTTPRequest verifies a message signature of r with the keyring and
+// returns the signing key. The signature is selected by label, or every one
+// is tried, and its key by keyid, or every Ed25519 key is tried
+func VerifyHTTPRequest(r *http.Request, keyring Keyring, opts *HTTPVerifyOptions) (PublicKey, error) {
+	return verifyHTTPRequest(r, keyring, opts, time.Now())
+}
+
+// utility functions
+
+func signHTTPRequest(r *http.Request, key PrivateKey, label string, components []string, params []sfParam) error {
+	signer, ok := key.(rawSigner)
+	if !ok || signer.rawAlgorithm() != algEdDSA {
+		return ErrHTTPSigKey
+	}
+	if !sfIsKey(label) {
+		return ErrInvalidHTTPSignature
+	}
+
+	items := make([]sfItem, len(components))
+	for i, c := range components {
+		items[i] = sfItem{value: strings.ToLower(c)}
+	}
+	input := sfInnerList{items: items, params: params}
+
+	base, err := httpSignatureBase(r, input)
+	if err != nil {
+		return err
+	}
+
+	sig, err := signer.signRaw([]byte(base))
+	if err != nil {
+		return err
+	}
+
+	r.Header.Add(SignatureInputHeader, label+"="+input.String())
+	r.Header.Add(SignatureHeader, label+"="+sfSerializeBare(sig))
+	return nil
+}
+
+func verifyHTTPRequest(r *http.Request, keyring Keyring, opts *HTTPVerifyOptions, now time.Time) (PublicKey, error) {
+	if opts == nil {
+		opts = &HTTPVerifyOptions{}
+	}
+
+	inputs, err1 := parseSFDictionary(strings.Join(r.Header.Values(SignatureInputHeader), ", "))
+	sigs, err2 := parseSFDictionary(strings.Join(r.Header.Values(SignatureHeader), ", "))
+	if err1 != nil || err2 != nil || len(inputs) == 0 {
+		return nil, ErrInvalidHTTPSignature
+	}
+
+	err := ErrInvalidHTTPSignature
+	for _, m := range inputs {
+		if opts.Label != "" && m.name != opts.Label {
+			continue
+		}
+
+		var pub PublicKey
+		pub, err = verifyHTTPSignature(r, m, sigs, keyring, opts, now)
+		if err == nil {
+			return pub, nil
+		}
+	}
+
+	return nil, err
+}
+
+// verifyHTTPSignature verifies the signature of one Signature-Input member
+func verifyHTTPSignature(r *http.Request, m sfMember, sigs []sfMember, keyring Keyring, opts *HTTPVerifyOptions, now time.Time) (PublicKey, error) {
+	input, ok := m.value.(sfInnerList)
+	if !ok {
+		return nil, ErrInvalidHTTPSignature
+	}
+
+	var sig []byte
+	for _, s := range sigs {
+		if item, ok := s.value.(sfItem); ok && s.name == m.name {
+			sig, _ = item.value.([]byte)
+		}
+	}
+	if sig == nil {
+		return nil, ErrInvalidHTTPSignature
+	}
+
+	var covered []string
+	for _, item := range input.items {
+		name, ok := item.value.(string)
+		if !ok {
+			return nil, ErrInvalidHTTPSignature
+		}
+		covered = append(covered, name)
+	}
+	for _, c := range opts.Components {
+		if !slices.Contains(covered, strings.ToLower(c)) {
+			return nil, ErrInvalidHTTPSignature
+		}
+	}
+
+	p := sfParams(input.params)
+	alg, hasAlg := p["alg"]
+	keyId, hasKeyId := p["keyid"].(string)
+	created, hasCreated := p["created"].(int64)
+	expires, hasExpires := p["expires"].(int64)
+	if (hasAlg && alg != HTTPSigAlgorithm) || (opts.Tag != "" && p["tag"] != opts.Tag) || !hasCreated {
+		return nil, ErrInvalidHTTPSignature
+	}
+
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultRequestMaxAge
+	}
+	at := time.Unix(created, 0)
+	if at.Before(now.Add(-maxAge)) || at.After(now.Add(maxAge)) || (hasExpires && !now.Before(time.Unix(expires, 0))) {
+		return nil, ErrRequestStale
+	}
+
+	if slices.Contains(covered, "content-digest") {
+		err := verifyContentDigest(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	base, err := httpSignatureBase(r, input)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, pub := range keyring {
+		verifier, ok := pub.(rawVerifier)
+		if !ok || verifier.rawAlgorithm() != algEdDSA || (hasKeyId && pub.Id().String() != keyId) {
+			continue
+		}
+		found = true
+
+		if verifier.verifyRaw([]byte(base), sig) {
+			return pub, nil
+		}
+	}
+
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+
+	return nil, ErrInvalidSignature
+}
+
+// httpSignatureBase builds the signature base of RFC 9421 section 2.5
+func httpSignatureBase(r *http.Request, input sfInnerList) (string, error) {
+	var b strings.Builder
+	seen := make(map[string]bool)
+
+	for _, item := range input.items {
+		name, ok := item.value.(string)
+		if !ok || len(item.params) > 0 || seen[name] || name == "@signature-params" {
+			return "", ErrInvalidHTTPSignature
+		}
+		seen[name] = true
+
+		value, err := httpComponent(r, name)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(sfSerializeBare(name) + ": " + value + "\n")
+	}
+
+	b.WriteString(`"@signature-params": ` + input.String())
+	return b.String(), nil
+}
+
+// httpComponent returns the value of a derived component or header field
+func httpComponent(r *http.Request, name string) (string, error) {
+	authority := r.Host
+	if authority == "" {
+		authority = r.URL.Host
+	}
+	scheme := "http"
+	if r.TLS != nil || r.URL.Scheme == "https" {
+		scheme = "https"
+	}
+
+	switch name {
+	case "@method":
+		return r.Method, nil
+	case "@authority":
+		return strings.ToLower(authority), nil
+	case "@scheme":
+		return scheme, nil
+	case "@target-uri":
+		return scheme + "://" + strings.ToLower(authority) + r.URL.RequestURI(), nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		path := r.URL.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		return path, nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	}
+
+	if strings.HasPrefix(name, "@") || name != strings.ToLower(name) {
+		return "", ErrInvalidHTTPSignature
+	}
+
+	values := r.Header.Values(name)
+	if len(values) == 0 {
+		return "", ErrInvalidHTTPSignature
+	}
+	// Values returns the slice of the header map, trim into a copy
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+
+	return strings.Join(trimmed, ", "), nil
+}
+
+// verifyContentDigest checks every known digest of the Content-Digest field
+func verifyContentDigest(r *http.Request) error {
+	digests, err := parseSFDictionary(strings.Join(r.Header.Values(ContentDigestHeader), ", "))
+	if err != nil {
+		return ErrInvalidHTTPSignature
+	}
+
+	body, err := readBody(r, DefaultMaxBodySize)
+	if err != nil {
+		return err
+	}
+
+	known := 0
+	for _, m := range digests {
+		item, _ := m.value.(sfItem)
+		want, _ := item.value.([]byte)
+
+		var got []byte
+		switch m.name {
+		case "sha-256":
+			d := sha256.Sum256(body)
+			got = d[:]
+		case "sha-512":
+			d := sha512.Sum512(body)
+			got = d[:]
+		default:
+			continue
+		}
+
+		if !bytes.Equal(got, want) {
+			return ErrInvalidHTTPSignature
+		}
+		known++
+	}
+
+	if known == 0 {
+		return ErrInvalidHTTPSignature
+	}
+
+	return nil
+}
+
+// structured field values (RFC 8941), the subset used by message signatures:
+// dictionaries of items and inner lists with parameters, bare items are
+// int64, string, sfToken, []byte or bool
+
+type sfToken string
+
+type sfParam struct {
+	name  string
+	value any
+}
+
+type sfItem struct {
+	value  any
+	params []sfParam
+}
+
+type sfInnerList struct {
+	items  []sfItem
+	params []sfParam
+}
+
+type sfMember struct {
+	name  string
+	value any // sfItem or sfInnerList
+}
+
+type sfParser struct {
+	s string
+	i int
+}
+
+func parseSFDictionary(s string) ([]sfMember, error) {
+	p := &sfParser{s: s}
+	p.skip(" ")
+
+	var members []sfMember
+	for p.i < len(p.s) {
+		name, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+
+		var value any
+		if p.peek() == '=' {
+			p.i++
+			if p.peek() == '(' {
+				value, err = p.innerList()
+			} else {
+				value, err = p.item()
+			}
+		} else {
+			var params []sfParam
+			params, err = p.params()
+			value = sfItem{value: true, params: params}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// a later member of the same name replaces the earlier one
+		members = slices.DeleteFunc(members, func(m sfMember) bool { return m.name == name })
+		members = append(members, sfMember{name: name, value: value})
+
+		p.skip(" \t")
+		if p.i == len(p.s) {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, ErrInvalidHTTPSignature
+		}
+		p.i++
+		p.skip(" \t")
+		if p.i == len(p.s) {
+			return nil, ErrInvalidHTTPSignature
+		}
+	}
+
+	return members, nil
+}
+
+func (p *sfParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+func (p *sfParser) skip(chars string) {
+	for p.i < len(p.s) && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *sfParser) key() (string, error) {
+	start := p.i
+	if c := p.peek(); !(c >= 'a' && c <= 'z' || c == '*') {
+		return "", ErrInvalidHTTPSignature
+	}
+	for p.i < len(p.s) && sfIsKeyChar(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) innerList() (sfInnerList, error) {
+	p.i++ // (
+	var l sfInnerList
+	for {
+		p.skip(" ")
+		if p.peek() == ')' {
+			p.i++
+			params, err := p.params()
+			l.params = params
+			return l, err
+		}
+
+		item, err := p.item()
+		if err != nil {
+			return l, err
+		}
+		l.items = append(l.items, item)
+
+		if c := p.peek(); c != ' ' && c != ')' {
+			return l, ErrInvalidHTTPSignature
+		}
+	}
+}
+
+func (p *sfParser) item() (sfItem, error) {
+	value, err := p.bareItem()
+	if err != nil {
+		return sfItem{}, err
+	}
+
+	params, err := p.params()
+	return sfItem{value: value, params: params}, err
+}
+
+func (p *sfParser) params() ([]sfParam, error) {
+	var params []sfParam
+	for p.peek() == ';' {
+		p.i++
+		p.skip(" ")
+
+		name, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+
+		var value any = true
+		if p.peek() == '=' {
+			p.i++
+			value, err = p.bareItem()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		params = slices.DeleteFunc(params, func(q sfParam) bool { return q.name == name })
+		params = append(params, sfParam{name: name, value: value})
+	}
+	return params, nil
+}
+
+func (p *sfParser) bareItem() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.i
+		p.i++
+		for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+			p.i++
+		}
+		digits := p.s[start:p.i]
+		if len(strings.TrimPrefix(digits, "-")) > 15 || p.peek() == '.' {
+			return nil, ErrInvalidHTTPSignature
+		}
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidHTTPSignature
+		}
+		return n, nil
+
+	case c == '"':
+		p.i++
+		var b strings.Builder
+		for p.i < len(p.s) {
+			c = p.s[p.i]
+			p.i++
+			switch {
+			case c == '\\':
+				if p.i == len(p.s) || (p.s[p.i] != '"' && p.s[p.i] != '\\') {
+					return nil, ErrInvalidHTTPSignature
+				}
+				b.WriteByte(p.s[p.i])
+				p.i++
+			case c == '"':
+				return b.String(), nil
+			case c < 0x20 || c > 0x7e:
+				return nil, ErrInvalidHTTPSignature
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return nil, ErrInvalidHTTPSignature
+
+	case c == '*' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		start := p.i
+		for p.i < len(p.s) && (sfIsTChar(p.s[p.i]) || p.s[p.i] == ':' || p.s[p.i] == '/') {
+			p.i++
+		}
+		return sfToken(p.s[start:p.i]), nil
+
+	case c == ':':
+		end := strings.IndexByte(p.s[p.i+1:], ':')
+		if end < 0 {
+			return nil, ErrInvalidHTTPSignature
+		}
+		data, err := base64.StdEncoding.DecodeString(p.s[p.i+1 : p.i+1+end])
+		if err != nil {
+			return nil, ErrInvalidHTTPSignature
+		}
+		p.i += end + 2
+		return data, nil
+
+	case c == '?':
+		if p.i+1 < len(p.s) && (p.s[p.i+1] == '0' || p.s[p.i+1] == '1') {
+			p.i += 2
+			return p.s[p.i-1] == '1', nil
+		}
+	}
+
+	return nil, ErrInvalidHTTPSignature
+}
+
+func (l sfInnerList) String() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, item := range l.items {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(sfSerializeBare(item.value) + sfSerializeParams(item.params))
+	}
+	b.WriteByte(')')
+	b.WriteString(sfSerializeParams(l.params))
+	return b.String()
+}
+
+func sfSerializeParams(params []sfParam) string {
+	var b strings.Builder
+	for _, p := range params {
+		b.WriteString(";" + p.name)
+		if v, ok := p.value.(bool); !ok || !v {
+			b.WriteString("=" + sfSerializeBare(p.value))
+		}
+	}
+	return b.String()
+}
+
+func sfSerializeBare(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case sfToken:
+		return string(v)
+	case []byte:
+		return ":" + base64.StdEncoding.EncodeToString(v) + ":"
+	case bool:
+		if v {
+			return "?1"
+		}
+		return "?0"
+	}
+	return ""
+}
+
+// sfParams returns parameters by name
+func sfParams(params []sfParam) map[string]any {
+	m := make(map[string]any, len(params))
+	for _, p := range params {
+		m[p.name] = p.value
+	}
+	return m
+}
+
+func sfIsKey(s string) bool {
+	if s == "" || !(s[0] >= 'a' && s[0] <= 'z' || s[0] == '*') {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !sfIsKeyChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sfIsKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("_-.*", c) >= 0
+}
+
+func sfIsTChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,525 @@
+package msign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Format identifies the encoding an item was imported from
+type Format int
+
+const (
+	FormatMsign    Format = iota + 1 // KEY:, PUB:, SIG:, CRT:, TST:, CSG: and ENV: lines
+	FormatPEM                        // PKIX, PKCS #1, PKCS #8 and SEC 1 PEM blocks
+	FormatOpenSSH                    // OpenSSH public key lines and unencrypted private keys
+	FormatMinisign                   // minisign public keys
+	FormatBinary                     // raw binary frames, see ReadFrame
+)
+
+const (
+	prefixPEM          = "-----BEGIN "
+	prefixMinisign     = "untrusted comment:"
+	magicOpenSSH       = "openssh-key-v1\x00"
+	sshEd25519         = "ssh-ed25519"
+	sshECDSAP256       = "ecdsa-sha2-nistp256"
+	sshRSA             = "ssh-rsa"
+	sshCurveP256       = "nistp256"
+	sizeMinisignPublic = 2 + 8 + ed25519.PublicKeySize // algorithm, key number, public key
+	minisignAlgorithm  = "Ed"
+	sizeMinisignKeyNum = 8
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	ErrEncryptedKey      = errors.New("encrypted private key")
+	ErrInvalidCrtFormat  = errors.New("invalid certificate format")
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatMsign:
+		return "msign"
+	case FormatPEM:
+		return "PEM"
+	case FormatOpenSSH:
+		return "OpenSSH"
+	case FormatMinisign:
+		return "minisign"
+	case FormatBinary:
+		return "binary"
+	}
+
+	return "unknown"
+}
+
+// Item is an imported key, signature, certificate, timestamp token,
+// countersignature or signed envelope, exactly one of the fields besides
+// Format is set
+type Item struct {
+	Format           Format
+	PrivateKey       PrivateKey
+	PublicKey        PublicKey
+	Signature        Signature
+	Certificate      *x509.Certificate
+	Timestamp        *TimestampToken
+	Countersignature *Countersignature
+	Envelope         *SignedEnvelope
+}
+
+// Value returns the imported item, suitable for Export
+func (i *Item) Value() any {
+	switch {
+	case i.PrivateKey != nil:
+		return i.PrivateKey
+	case i.PublicKey != nil:
+		return i.PublicKey
+	case i.Signature != nil:
+		return i.Signature
+	case i.Certificate != nil:
+		return i.Certificate
+	case i.Timestamp != nil:
+		return i.Timestamp
+	case i.Countersignature != nil:
+		return i.Countersignature
+	case i.Envelope != nil:
+		return i.Envelope
+	}
+
+	return nil
+}
+
+// ImportAny sniffs the format of r and imports the key or signature it holds.
+// Besides msign lines it reads PEM keys, OpenSSH public and unencrypted
+// private keys and minisign public keys, mapping them to the matching msign key type
+func ImportAny(r io.Reader) (*Item, error) {
+	if r == nil {
+		return nil, ErrNilReader
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(bytes.NewReader(data))
+	line, err := readLine(br)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case isMsignLine(line):
+		return importMsign(line)
+	case strings.HasPrefix(line, prefixPEM):
+		return importPEM(data)
+	case strings.HasPrefix(line, prefixMinisign):
+		line, err = readLine(br)
+		if err != nil {
+			return nil, err
+		}
+		return importMinisign(line)
+	case strings.HasPrefix(line, sshEd25519+" "), strings.HasPrefix(line, sshECDSAP256+" "), strings.HasPrefix(line, sshRSA+" "):
+		return importSSHPublic(line)
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+// utility functions
+
+// isMsignLine reports whether the line starts with one of the msign prefixes
+func isMsignLine(line string) bool {
+	for _, prefix := range []string{PrefixKEY, PrefixPUB, PrefixSIG, PrefixCRT, PrefixTST, PrefixCSG, PrefixENV} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// importMsign decodes a KEY:, PUB:, SIG:, CRT:, TST:, CSG: or ENV: line
+func importMsign(line string) (*Item, error) {
+	var (
+		item = &Item{Format: FormatMsign}
+		data []byte
+		err  error
+	)
+
+	switch {
+	case strings.HasPrefix(line, PrefixKEY):
+		data, err = decodeLine(line, PrefixKEY, ErrInvalidKeyFormat)
+		if err == nil {
+			item.PrivateKey, err = decodePrivateKey(data)
+		}
+	case strings.HasPrefix(line, PrefixPUB):
+		data, err = decodeLine(line, PrefixPUB, ErrInvalidPubFormat)
+		if err == nil {
+			item.PublicKey, err = decodePublicKey(data)
+		}
+	case strings.HasPrefix(line, PrefixCRT):
+		data, err = decodeLine(line, PrefixCRT, ErrInvalidCrtFormat)
+		if err == nil {
+			item.Certificate, err = x509.ParseCertificate(data)
+		}
+	case strings.HasPrefix(line, PrefixSIG):
+		data, err = decodeLine(line, PrefixSIG, ErrInvalidSigFormat)
+		if err == nil {
+			item.Signature, err = decodeSignature(data)
+		}
+	case strings.HasPrefix(line, PrefixTST):
+		data, err = decodeLine(line, PrefixTST, ErrInvalidTimestamp)
+		if err == nil {
+			item.Timestamp, err = ParseTimestamp(data)
+		}
+	case strings.HasPrefix(line, PrefixCSG):
+		data, err = decodeLine(line, PrefixCSG, ErrInvalidCountersignature)
+		if err == nil {
+			item.Countersignature, err = ParseCountersignature(data)
+		}
+	case strings.HasPrefix(line, PrefixENV):
+		data, err = decodeLine(line, PrefixENV, ErrInvalidSignedEnvelope)
+		if err == nil {
+			item.Envelope, err = ParseSignedEnvelope(data)
+		}
+	default:
+		err = ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func importPEM(data []byte) (*Item, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if _, ok := block.Headers["DEK-Info"]; ok {
+		return nil, ErrEncryptedKey
+	}
+
+	var (
+		key any
+		err error
+	)
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "OPENSSH PRIVATE KEY":
+		return importSSHPrivate(block.Bytes)
+	case "ENCRYPTED PRIVATE KEY":
+		return nil, ErrEncryptedKey
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return itemFromCrypto(FormatPEM, key)
+}
+
+func importMinisign(line string) (*Item, error) {
+	pub, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return nil, err
+	}
+
+	// signatures and secret keys rely on BLAKE2b and scrypt, only public keys are read
+	if len(pub) != sizeMinisignPublic || string(pub[:len(minisignAlgorithm)]) != minisignAlgorithm {
+		return nil, ErrUnsupportedFormat
+	}
+
+	return itemFromCrypto(FormatMinisign, ed25519.PublicKey(pub[len(minisignAlgorithm)+sizeMinisignKeyNum:]))
+}
+
+func importSSHPublic(line string) (*Item, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, ErrUnsupportedFormat
+	}
+
+	wire, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	sr := &sshReader{b: wire}
+	key, err := sr.publicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return itemFromCrypto(FormatOpenSSH, key)
+}
+
+func importSSHPrivate(data []byte) (*Item, error) {
+	if !bytes.HasPrefix(data, []byte(magicOpenSSH)) {
+		return nil, ErrUnsupportedFormat
+	}
+
+	sr := &sshReader{b: data[len(magicOpenSSH):]}
+	cipher, kdf := string(sr.string()), string(sr.string())
+	sr.string() // kdf options
+	count := sr.uint32()
+	sr.string() // public key
+	private := sr.string()
+	if sr.err != nil || count != 1 {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if cipher != "none" || kdf != "none" {
+		return nil, ErrEncryptedKey
+	}
+
+	sr = &sshReader{b: private}
+	if sr.uint32() != sr.uint32() {
+		return nil, ErrEncryptedKey // check integers differ when decrypted with a wrong passphrase
+	}
+
+	key, err := sr.privateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return itemFromCrypto(FormatOpenSSH, key)
+}
+
+// itemFromCrypto maps a standard library key to the matching msign key type
+func itemFromCrypto(format Format, key any) (*Item, error) {
+	item := &Item{Format: format}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey, *ecdsa.PrivateKey, *ecdh.PrivateKey, *rsa.PrivateKey:
+		priv, err := privateKeyFromCrypto(k)
+		if err != nil {
+			return nil, err
+		}
+		item.PrivateKey = priv
+	default:
+		pub, err := publicKeyFromCrypto(k)
+		if err != nil {
+			return nil, err
+		}
+		item.PublicKey = pub
+	}
+
+	return item, nil
+}
+
+// privateKeyFromCrypto converts Ed25519, ECDSA or ECDH P-256 and RSA private keys
+func privateKeyFromCrypto(key any) (PrivateKey, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		if len(k) != ed25519.PrivateKeySize {
+			return nil, ErrInvalidKeyFormat
+		}
+		// the stored public half names the key, it must be the one of the seed
+		if !bytes.Equal(ed25519.NewKeyFromSeed(k.Seed()), k) {
+			return nil, ErrInvalidKeyFormat
+		}
+		privateKey := &privateKeyV1{}
+		id := sha256.Sum256(k[ed25519.SeedSize:])
+		copy(privateKey.id[:], id[:sizeIDv1])
+		copy(privateKey.bytes[:], k)
+		return privateKey, nil
+	case *ecdsa.PrivateKey:
+		ek, err := k.ECDH()
+		if err != nil {
+			return nil, ErrUnsupportedFormat
+		}
+		return privateKeyFromCrypto(ek)
+	case *ecdh.PrivateKey:
+		if k.Curve() != ecdh.P256() {
+			return nil, ErrUnsupportedFormat
+		}
+		return newPrivateKeyV3FromECDH(k), nil
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minBitsRSAv4 {
+			return nil, ErrUnsupportedFormat
+		}
+		return &privateKeyV4{id: idV4(&k.PublicKey), key: k}, nil
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+// publicKeyFromCrypto converts Ed25519, ECDSA or ECDH P-256 and RSA public keys
+func publicKeyFromCrypto(key any) (PublicKey, error) {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		if len(k) != ed25519.PublicKeySize {
+			return nil, ErrInvalidPubFormat
+		}
+		publicKey := &publicKeyV1{}
+		id := sha256.Sum256(k)
+		copy(publicKey.id[:], id[:sizeIDv1])
+		copy(publicKey.bytes[:], k)
+		return publicKey, nil
+	case *ecdsa.PublicKey:
+		ek, err := k.ECDH()
+		if err != nil {
+			return nil, ErrUnsupportedFormat
+		}
+		return publicKeyFromCrypto(ek)
+	case *ecdh.PublicKey:
+		if k.Curve() != ecdh.P256() {
+			return nil, ErrUnsupportedFormat
+		}
+		publicKey := &publicKeyV3{}
+		copy(publicKey.point[:], k.Bytes())
+		id := sha256.Sum256(publicKey.point[:])
+		copy(publicKey.id[:], id[:sizeIDv1])
+		return publicKey, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minBitsRSAv4 {
+			return nil, ErrUnsupportedFormat
+		}
+		return &publicKeyV4{id: idV4(k), key: k}, nil
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+// sshReader reads the RFC 4251 wire encoding, the first error sticks
+type sshReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil || len(r.b) < 4 {
+		r.err = ErrUnsupportedFormat
+		return 0
+	}
+
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sshReader) string() []byte {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.b)) < n {
+		r.err = ErrUnsupportedFormat
+		return nil
+	}
+
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *sshReader) mpint() *big.Int {
+	return new(big.Int).SetBytes(r.string())
+}
+
+// publicKey reads a key type name followed by its public key fields
+func (r *sshReader) publicKey() (any, error) {
+	var key any
+
+	switch string(r.string()) {
+	case sshEd25519:
+		key = ed25519.PublicKey(r.string())
+	case sshECDSAP256:
+		if string(r.string()) != sshCurveP256 {
+			return nil, ErrUnsupportedFormat
+		}
+		pub, err := ecdh.P256().NewPublicKey(r.string())
+		if err != nil {
+			return nil, ErrUnsupportedFormat
+		}
+		key = pub
+	case sshRSA:
+		e, n := r.mpint(), r.mpint()
+		if !e.IsInt64() {
+			return nil, ErrUnsupportedFormat
+		}
+		key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return key, nil
+}
+
+// privateKey reads a key type name followed by its private key fields
+func (r *sshReader) privateKey() (any, error) {
+	var key any
+
+	switch string(r.string()) {
+	case sshEd25519:
+		r.string() // public key, repeated in the private key
+		key = ed25519.PrivateKey(r.string())
+	case sshECDSAP256:
+		if string(r.string()) != sshCurveP256 {
+			return nil, ErrUnsupportedFormat
+		}
+		r.string() // public point
+		d := r.mpint()
+		if r.err != nil || d.BitLen() > 8*sizeScalarv3 {
+			return nil, ErrUnsupportedFormat
+		}
+		priv, err := ecdh.P256().NewPrivateKey(d.FillBytes(make([]byte, sizeScalarv3)))
+		if err != nil {
+			return nil, ErrUnsupportedFormat
+		}
+		key = priv
+	case sshRSA:
+		n, e, d := r.mpint(), r.mpint(), r.mpint()
+		r.mpint() // iqmp, recomputed
+		p, q := r.mpint(), r.mpint()
+		if r.err != nil || !e.IsInt64() {
+			return nil, ErrUnsupportedFormat
+		}
+		priv := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err := priv.Validate(); err != nil {
+			return nil, ErrUnsupportedFormat
+		}
+		priv.Precompute()
+		key = priv
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return key, nil
+}
@@ -4,23 +4,54 @@ import "io"
 
 type exporter interface {
 	export(io.Writer) error
+	marshal() []byte // binary form carried by export, version byte first
 }
 
 type KeyId []byte
 type PrivateKey interface {
 	exporter
 	Id() KeyId
+	Fingerprint() Fingerprint
 	Public() PublicKey
 	Sign(io.Reader) (Signature, error)
+	SignWithOptions(io.Reader, *SignOptions) (Signature, error)
+	version() byte // format version byte, as in marshal without copying the key
 }
 
 type PublicKey interface {
 	exporter
 	Id() KeyId
+	Fingerprint() Fingerprint
 	Verify(io.Reader, Signature) (bool, error)
+	VerifyWithOptions(io.Reader, Signature, *VerifyOptions) (bool, error)
 }
 
 type Signature interface {
 	exporter
 	KeyId() KeyId
+	Fingerprint() Fingerprint // nil unless the signature embeds it
+	Digest() Digest
+}
+
+// signatureHolder is implemented by pointers to types embedding a Signature,
+// e.g. *SignatureValue, verifying keys check the held signature. A nil
+// holder holds no signature
+type signatureHolder interface {
+	heldSignature() Signature
+}
+
+// signatureHolderValue is implemented by the holders passed by value
+type signatureHolderValue interface {
+	holder() signatureHolder
+}
+
+// SignOptions tunes how a message is signed, nil means the key defaults
+type SignOptions struct {
+	Digest           Digest // digest algorithm, zero selects the key default
+	EmbedFingerprint bool   // wrap into a version 6 signature carrying the full key fingerprint
+}
+
+// VerifyOptions restricts which signatures are accepted, nil accepts every known digest
+type VerifyOptions struct {
+	AllowedDigests []Digest // digest allow-list, empty allows every known digest
 }
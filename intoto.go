@@ -0,0 +1,149 @@
+package msign
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// in-toto Statement v1 payloads for DSSE envelopes, e.g. SLSA provenance
+
+const (
+	InTotoPayloadType   = "application/vnd.in-toto+json"
+	InTotoStatementType = "https://in-toto.io/Statement/v1"
+	SLSAProvenanceType  = "https://slsa.dev/provenance/v1"
+	inTotoDigestSHA256  = "sha256"
+)
+
+var ErrInvalidStatement = errors.New("invalid in-toto statement")
+
+// Statement is an in-toto Statement v1
+type Statement struct {
+	Type          string          `json:"_type"`
+	Subject       []Subject       `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate,omitempty"`
+}
+
+// Subject is an artifact of a statement, digests are hex encoded by algorithm name
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SLSAProvenance is the predicate of SLSA provenance v1, reduced to the fields
+// needed to describe a build
+type SLSAProvenance struct {
+	BuildDefinition SLSABuildDefinition `json:"buildDefinition"`
+	RunDetails      SLSARunDetails      `json:"runDetails"`
+}
+
+type SLSABuildDefinition struct {
+	BuildType            string         `json:"buildType"`
+	ExternalParameters   map[string]any `json:"externalParameters"`
+	InternalParameters   map[string]any `json:"internalParameters,omitempty"`
+	ResolvedDependencies []Subject      `json:"resolvedDependencies,omitempty"`
+}
+
+type SLSARunDetails struct {
+	Builder  SLSABuilder   `json:"builder"`
+	Metadata *SLSAMetadata `json:"metadata,omitempty"`
+}
+
+type SLSABuilder struct {
+	Id string `json:"id"`
+}
+
+type SLSAMetadata struct {
+	InvocationId string     `json:"invocationId,omitempty"`
+	StartedOn    *time.Time `json:"startedOn,omitempty"`
+	FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+}
+
+// NewSubject hashes an artifact into a subject with its SHA-256 digest
+func NewSubject(name string, r io.Reader) (Subject, error) {
+	if r == nil {
+		return Subject{}, ErrNilReader
+	}
+
+	h := sha256.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return Subject{}, err
+	}
+
+	return Subject{Name: name, Digest: map[string]string{inTotoDigestSHA256: hex.EncodeToString(h.Sum(nil))}}, nil
+}
+
+// NewStatement returns a statement about the subjects, predicate is marshaled to JSON
+func NewStatement(predicateType string, predicate any, subjects ...Subject) (*Statement, error) {
+	if len(subjects) == 0 || predicateType == "" {
+		return nil, ErrInvalidStatement
+	}
+
+	raw, err := json.Marshal(predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Statement{
+		Type:          InTotoStatementType,
+		Subject:       subjects,
+		PredicateType: predicateType,
+		Predicate:     raw,
+	}, nil
+}
+
+// Sign returns a DSSE envelope of the statement signed by every key
+func (s *Statement) Sign(keys ...PrivateKey) (*DSSEEnvelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return SignDSSE(InTotoPayloadType, payload, keys...)
+}
+
+// Covers reports whether the SHA-256 digest of the artifact matches a subject
+func (s *Statement) Covers(artifact io.Reader) (bool, error) {
+	subject, err := NewSubject("", artifact)
+	if err != nil {
+		return false, err
+	}
+
+	for _, sub := range s.Subject {
+		if sub.Digest[inTotoDigestSHA256] == subject.Digest[inTotoDigestSHA256] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// VerifyStatement verifies the envelope with the keyring and threshold, see
+// DSSEEnvelope.Verify, and decodes its in-toto statement
+func VerifyStatement(e *DSSEEnvelope, keyring Keyring, threshold int) (*Statement, []PublicKey, error) {
+	if e == nil {
+		return nil, nil, ErrInvalidEnvelope
+	}
+
+	signers, err := e.Verify(keyring, threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if e.PayloadType != InTotoPayloadType {
+		return nil, nil, ErrInvalidStatement
+	}
+
+	var s Statement
+	err = json.Unmarshal(e.Payload, &s)
+	if err != nil || s.Type != InTotoStatementType || len(s.Subject) == 0 || s.PredicateType == "" {
+		return nil, nil, ErrInvalidStatement
+	}
+
+	return &s, signers, nil
+}
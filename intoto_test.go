@@ -0,0 +1,129 @@
+package msign
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSubject(t *testing.T) {
+	s, err := NewSubject("app.tar.gz", strings.NewReader("abc"))
+	if err != nil {
+		t.Fatalf("NewSubject() failed: %v", err)
+	}
+
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if s.Name != "app.tar.gz" || s.Digest["sha256"] != want {
+		t.Errorf("NewSubject() failed: %+v", s)
+	}
+
+	_, err = NewSubject("x", nil)
+	if err != ErrNilReader {
+		t.Errorf("NewSubject() with nil reader failed: %v", err)
+	}
+}
+
+func TestStatement(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	subject, err := NewSubject("app.tar.gz", strings.NewReader("artifact"))
+	if err != nil {
+		t.Fatalf("NewSubject() failed: %v", err)
+	}
+
+	started := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	provenance := SLSAProvenance{
+		BuildDefinition: SLSABuildDefinition{
+			BuildType:          "https://example.com/build/v1",
+			ExternalParameters: map[string]any{"ref": "refs/tags/v1.2.0"},
+		},
+		RunDetails: SLSARunDetails{
+			Builder:  SLSABuilder{Id: "https://example.com/builder"},
+			Metadata: &SLSAMetadata{InvocationId: "42", StartedOn: &started},
+		},
+	}
+
+	st, err := NewStatement(SLSAProvenanceType, provenance, subject)
+	if err != nil {
+		t.Fatalf("NewStatement() failed: %v", err)
+	}
+
+	e, err := st.Sign(priv)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if e.PayloadType != InTotoPayloadType {
+		t.Errorf("Sign() failed by payload type: %s", e.PayloadType)
+	}
+
+	got, signers, err := VerifyStatement(e, Keyring{pub}, 1)
+	if err != nil || len(signers) != 1 || signers[0] != pub {
+		t.Fatalf("VerifyStatement() failed: %v", err)
+	}
+	if got.Type != InTotoStatementType || got.PredicateType != SLSAProvenanceType || got.Subject[0].Name != "app.tar.gz" {
+		t.Errorf("VerifyStatement() failed by statement: %+v", got)
+	}
+
+	var p SLSAProvenance
+	err = json.Unmarshal(got.Predicate, &p)
+	if err != nil || p.RunDetails.Builder.Id != "https://example.com/builder" || !p.RunDetails.Metadata.StartedOn.Equal(started) {
+		t.Errorf("VerifyStatement() failed by predicate: %v", err)
+	}
+
+	ok, err := got.Covers(strings.NewReader("artifact"))
+	if err != nil || !ok {
+		t.Errorf("Covers() failed: %v", err)
+	}
+	ok, err = got.Covers(strings.NewReader("tampered"))
+	if err != nil || ok {
+		t.Errorf("Covers() with other artifact failed: %v", err)
+	}
+}
+
+func TestStatement_Bad(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	subject := Subject{Name: "x", Digest: map[string]string{"sha256": "00"}}
+
+	_, err = NewStatement(SLSAProvenanceType, nil)
+	if err != ErrInvalidStatement {
+		t.Errorf("NewStatement() without subject failed: %v", err)
+	}
+	_, err = NewStatement("", nil, subject)
+	if err != ErrInvalidStatement {
+		t.Errorf("NewStatement() without predicate type failed: %v", err)
+	}
+
+	inputs := []struct {
+		payloadType string
+		payload     string
+	}{
+		{InTotoPayloadType, `{"_type":"https://in-toto.io/Statement/v0.1","subject":[{"name":"x","digest":{}}],"predicateType":"p"}`},
+		{InTotoPayloadType, `{"_type":"https://in-toto.io/Statement/v1","subject":[],"predicateType":"p"}`},
+		{InTotoPayloadType, `not json`},
+		{"application/json", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"x","digest":{}}],"predicateType":"p"}`},
+	}
+
+	for _, input := range inputs {
+		e, err := SignDSSE(input.payloadType, []byte(input.payload), priv)
+		if err != nil {
+			t.Fatalf("SignDSSE() failed: %v", err)
+		}
+
+		_, _, err = VerifyStatement(e, Keyring{pub}, 1)
+		if err != ErrInvalidStatement {
+			t.Errorf("VerifyStatement(%s) failed: %v", input.payload, err)
+		}
+	}
+
+	_, _, err = VerifyStatement(nil, Keyring{pub}, 1)
+	if err != ErrInvalidEnvelope {
+		t.Errorf("VerifyStatement() with nil envelope failed: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package msign
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// JSON Web Keys (RFC 7517) of public keys: Ed25519 as OKP (RFC 8037),
+// ECDSA P-256 as EC and RSA as RSA, the kid is the msign KeyId
+
+const (
+	jwkOKP     = "OKP"
+	jwkEC      = "EC"
+	jwkRSA     = "RSA"
+	jwkEd25519 = "Ed25519"
+	jwkP256    = "P-256"
+	jwkUseSig  = "sig"
+)
+
+type jwk struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	KeyId     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	D         string `json:"d,omitempty"` // only to refuse private keys
+}
+
+type jwkSet struct {
+	Keys []*jwk `json:"keys"`
+}
+
+// ExportJWK returns the JWK of a public key, version 5 keys have no JWK form
+func ExportJWK(pub PublicKey) ([]byte, error) {
+	k, err := toJWK(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(k)
+}
+
+// ImportJWK decodes the JWK of a public key, JWKs holding a private key are refused
+func ImportJWK(data []byte) (PublicKey, error) {
+	var k jwk
+	err := json.Unmarshal(data, &k)
+	if err != nil {
+		return nil, ErrInvalidPubFormat
+	}
+
+	return fromJWK(&k)
+}
+
+// ExportJWKSet returns the JWK Set of the keyring, e.g. to publish as jwks.json
+func ExportJWKSet(keyring Keyring) ([]byte, error) {
+	set := jwkSet{Keys: make([]*jwk, 0, len(keyring))}
+	for _, pub := range keyring {
+		k, err := toJWK(pub)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, k)
+	}
+
+	return json.Marshal(set)
+}
+
+// ImportJWKSet decodes a JWK Set into a keyring, failing on any unsupported key
+func ImportJWKSet(data []byte) (Keyring, error) {
+	var set jwkSet
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, ErrInvalidPubFormat
+	}
+
+	keyring := make(Keyring, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := fromJWK(k)
+		if err != nil {
+			return nil, err
+		}
+		keyring = append(keyring, pub)
+	}
+
+	return keyring, nil
+}
+
+// utility functions
+
+func toJWK(pub PublicKey) (*jwk, error) {
+	k := &jwk{Use: jwkUseSig}
+
+	switch p := pub.(type) {
+	case *publicKeyV1:
+		k.KeyType, k.Curve, k.Algorithm = jwkOKP, jwkEd25519, JWSAlgorithm
+		k.X = base64.RawURLEncoding.EncodeToString(p.bytes[:])
+	case *publicKeyV3:
+		k.KeyType, k.Curve = jwkEC, jwkP256
+		k.X = base64.RawURLEncoding.EncodeToString(p.point[1 : 1+sizeScalarv3])
+		k.Y = base64.RawURLEncoding.EncodeToString(p.point[1+sizeScalarv3:])
+	case *publicKeyV4:
+		k.KeyType = jwkRSA
+		k.N = base64.RawURLEncoding.EncodeToString(p.key.N.Bytes())
+		k.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes())
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	k.KeyId = pub.Id().String()
+	return k, nil
+}
+
+func fromJWK(k *jwk) (PublicKey, error) {
+	if k == nil || k.D != "" {
+		return nil, ErrInvalidPubFormat
+	}
+
+	switch {
+	case k.KeyType == jwkOKP && k.Curve == jwkEd25519:
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidPubFormat
+		}
+		return publicKeyFromCrypto(ed25519.PublicKey(x))
+	case k.KeyType == jwkEC && k.Curve == jwkP256:
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != sizeScalarv3 || len(y) != sizeScalarv3 {
+			return nil, ErrInvalidPubFormat
+		}
+		key, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, ErrInvalidPubFormat
+		}
+		return publicKeyFromCrypto(key)
+	case k.KeyType == jwkRSA:
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, ErrInvalidPubFormat
+		}
+		return publicKeyFromCrypto(&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())})
+	}
+
+	return nil, ErrUnsupportedFormat
+}
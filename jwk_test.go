@@ -0,0 +1,80 @@
+package msign
+
+import (
+	"bytes"
+	"testing"
+)
+
+// RFC 8037 appendix A.2
+const testJWK = `{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`
+
+func TestImportJWK_RFC8037(t *testing.T) {
+	_, want := testJWSKey(t)
+
+	pub, err := ImportJWK([]byte(testJWK))
+	if err != nil {
+		t.Fatalf("ImportJWK() failed: %v", err)
+	}
+
+	if !bytes.Equal(pub.Fingerprint(), want.Fingerprint()) {
+		t.Errorf("ImportJWK() failed by key mismatch")
+	}
+}
+
+func TestJWK(t *testing.T) {
+	var keyring Keyring
+	for _, version := range []byte{VersionOne, VersionThree, VersionFour} {
+		_, pub, err := NewPrivateKeyWithVersion(version)
+		if err != nil {
+			t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+		}
+		keyring = append(keyring, pub)
+
+		data, err := ExportJWK(pub)
+		if err != nil {
+			t.Fatalf("ExportJWK() version %d failed: %v", version, err)
+		}
+
+		if !bytes.Contains(data, []byte(`"kid":"`+pub.Id().String()+`"`)) {
+			t.Errorf("ExportJWK() version %d failed by kid: %s", version, data)
+		}
+
+		got, err := ImportJWK(data)
+		if err != nil || !bytes.Equal(got.Fingerprint(), pub.Fingerprint()) {
+			t.Errorf("ImportJWK() version %d failed: %v", version, err)
+		}
+	}
+
+	data, err := ExportJWKSet(keyring)
+	if err != nil {
+		t.Fatalf("ExportJWKSet() failed: %v", err)
+	}
+
+	got, err := ImportJWKSet(data)
+	if err != nil || len(got) != len(keyring) {
+		t.Fatalf("ImportJWKSet() failed: %v", err)
+	}
+	for i := range keyring {
+		if !bytes.Equal(got[i].Fingerprint(), keyring[i].Fingerprint()) {
+			t.Errorf("ImportJWKSet() failed by key %d mismatch", i)
+		}
+	}
+}
+
+func TestJWK_Bad(t *testing.T) {
+	inputs := map[string]error{
+		`{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo","d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A"}`: ErrInvalidPubFormat,
+		`{"kty":"OKP","crv":"Ed25519","x":"11qY"}`:                                       ErrInvalidPubFormat,
+		`{"kty":"OKP","crv":"X25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`: ErrUnsupportedFormat,
+		`{"kty":"EC","crv":"P-256","x":"AAAA","y":"AAAA"}`:                               ErrInvalidPubFormat,
+		`{"kty":"oct","k":"c2VjcmV0"}`:                                                   ErrUnsupportedFormat,
+		`[]`:                                                                             ErrInvalidPubFormat,
+	}
+
+	for input, want := range inputs {
+		if _, err := ImportJWK([]byte(input)); err != want {
+			t.Errorf("ImportJWK(%s) failed: %v", input, err)
+		}
+	}
+
+}
@@ -0,0 +1,227 @@
+package msign
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// JSON Web Signatures (RFC 7515) with EdDSA (RFC 8037) over version 1 keys.
+// Only the protected header is trusted: its alg must be EdDSA and the key it
+// names through kid must be an Ed25519 key, whatever else the token claims
+
+const JWSAlgorithm = algEdDSA // the only accepted JWS alg
+
+// algorithms of raw signatures, named as in JOSE
+const (
+	algEdDSA = "EdDSA" // Ed25519 over the message
+)
+
+var (
+	ErrInvalidJWS   = errors.New("invalid JWS")
+	ErrJWSAlgorithm = errors.New("unsupported JWS algorithm")
+)
+
+// rawSigner is implemented by private keys able to sign JOSE and COSE messages
+type rawSigner interface {
+	signRaw(message []byte) ([]byte, error)
+	rawAlgorithm() string
+}
+
+// rawVerifier is implemented by public keys able to verify JOSE and COSE messages
+type rawVerifier interface {
+	verifyRaw(message, sig []byte) bool
+	rawAlgorithm() string
+}
+
+type jwsHeader struct {
+	Algorithm string   `json:"alg"`
+	KeyId     string   `json:"kid,omitempty"`
+	Critical  []string `json:"crit,omitempty"`
+}
+
+// jwsSignature is a signature of the JSON serialization, unprotected
+// header members are ignored
+type jwsSignature struct {
+	Protected string `json:"protected"`
+	Signature string `json:"signature"`
+}
+
+type jwsJSON struct {
+	Payload    string          `json:"payload"`
+	Protected  string          `json:"protected,omitempty"` // flattened serialization
+	Signature  string          `json:"signature,omitempty"` // flattened serialization
+	Signatures []*jwsSignature `json:"signatures,omitempty"`
+}
+
+// SignJWS returns the compact serialization of payload signed with an Ed25519 key
+func SignJWS(key PrivateKey, payload []byte) (string, error) {
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	sig, err := signJWS(key, encoded)
+	if err != nil {
+		return "", err
+	}
+
+	return sig.Protected + "." + encoded + "." + sig.Signature, nil
+}
+
+// VerifyJWS verifies a compact serialization with the keyring and returns its
+// payload and the key that signed it
+func VerifyJWS(token string, keyring Keyring) ([]byte, PublicKey, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, ErrInvalidJWS
+	}
+
+	pub, err := verifyJWS(&jwsSignature{Protected: parts[0], Signature: parts[2]}, parts[1], keyring)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+
+	return payload, pub, nil
+}
+
+// SignJWSJSON returns the general JSON serialization of payload with one
+// signature per key
+func SignJWSJSON(payload []byte, keys ...PrivateKey) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, ErrNilKey
+	}
+
+	j := jwsJSON{Payload: base64.RawURLEncoding.EncodeToString(payload)}
+	for _, key := range keys {
+		sig, err := signJWS(key, j.Payload)
+		if err != nil {
+			return nil, err
+		}
+		j.Signatures = append(j.Signatures, sig)
+	}
+
+	return json.Marshal(j)
+}
+
+// VerifyJWSJSON verifies a general or flattened JSON serialization and returns
+// its payload and the key of the first signature verifying with the keyring
+func VerifyJWSJSON(data []byte, keyring Keyring) ([]byte, PublicKey, error) {
+	var j jwsJSON
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+
+	sigs := j.Signatures
+	if j.Protected != "" || j.Signature != "" {
+		if len(sigs) != 0 {
+			return nil, nil, ErrInvalidJWS
+		}
+		sigs = []*jwsSignature{{Protected: j.Protected, Signature: j.Signature}}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(j.Payload)
+	if err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+
+	err = ErrInvalidJWS
+	for _, sig := range sigs {
+		var pub PublicKey
+		pub, err = verifyJWS(sig, j.Payload, keyring)
+		if err == nil {
+			return payload, pub, nil
+		}
+	}
+
+	return nil, nil, err
+}
+
+// utility functions
+
+func signJWS(key PrivateKey, payload string) (*jwsSignature, error) {
+	signer, ok := key.(rawSigner)
+	if !ok || signer.rawAlgorithm() != JWSAlgorithm {
+		return nil, ErrJWSAlgorithm
+	}
+
+	header, err := json.Marshal(jwsHeader{Algorithm: JWSAlgorithm, KeyId: key.Id().String()})
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &jwsSignature{Protected: base64.RawURLEncoding.EncodeToString(header)}
+	raw, err := signer.signRaw([]byte(sig.Protected + "." + payload))
+	if err != nil {
+		return nil, err
+	}
+	sig.Signature = base64.RawURLEncoding.EncodeToString(raw)
+
+	return sig, nil
+}
+
+// verifyJWS checks the protected header and the signature, without kid every
+// Ed25519 key of the keyring is tried
+func verifyJWS(sig *jwsSignature, payload string, keyring Keyring) (PublicKey, error) {
+	if sig == nil {
+		return nil, ErrInvalidJWS
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(sig.Protected)
+	if err != nil {
+		return nil, ErrInvalidJWS
+	}
+
+	var header jwsHeader
+	err = json.Unmarshal(data, &header)
+	if err != nil {
+		return nil, ErrInvalidJWS
+	}
+
+	// alg none, HMAC or any other alg is refused before a key is looked at
+	if header.Algorithm != JWSAlgorithm {
+		return nil, ErrJWSAlgorithm
+	}
+
+	// no critical extension is understood
+	if header.Critical != nil {
+		return nil, ErrInvalidJWS
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return nil, ErrInvalidJWS
+	}
+
+	message := []byte(sig.Protected + "." + payload)
+	found := false
+	for _, pub := range keyring {
+		if header.KeyId != "" && pub.Id().String() != header.KeyId {
+			continue
+		}
+		found = true
+
+		// a kid naming a key of another type is algorithm confusion
+		verifier, ok := pub.(rawVerifier)
+		if !ok || verifier.rawAlgorithm() != header.Algorithm {
+			if header.KeyId != "" {
+				return nil, ErrJWSAlgorithm
+			}
+			continue
+		}
+
+		if verifier.verifyRaw(message, raw) {
+			return pub, nil
+		}
+	}
+
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+
+	return nil, ErrInvalidSignature
+}
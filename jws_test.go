@@ -0,0 +1,190 @@
+package msign
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// RFC 8037 appendix A.4
+const (
+	testJWSSeed      = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+	testJWSInput     = "eyJhbGciOiJFZERTQSJ9.RXhhbXBsZSBvZiBFZDI1NTE5IHNpZ25pbmc"
+	testJWSSignature = "hgyY0il_MGCjP0JzlnLWG1PPOt7-09PGcvMg3AIbQR6dWbhijcNR4ki4iylGjg5BhVsPt9g7sVvpAr_MuM0KAg"
+)
+
+func testJWSKey(t *testing.T) (PrivateKey, PublicKey) {
+	t.Helper()
+
+	seed, _ := hex.DecodeString(testJWSSeed)
+	priv, err := privateKeyFromCrypto(ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		t.Fatalf("privateKeyFromCrypto() failed: %v", err)
+	}
+
+	return priv, priv.Public()
+}
+
+// testJWS builds a compact token with an arbitrary header, signed with key when not nil
+func testJWS(header string, payload string, key PrivateKey) string {
+	input := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+	if key == nil {
+		return input + "."
+	}
+
+	raw, _ := key.(rawSigner).signRaw([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func TestJWS_RFC8037(t *testing.T) {
+	priv, pub := testJWSKey(t)
+
+	raw, err := priv.(rawSigner).signRaw([]byte(testJWSInput))
+	if err != nil || base64.RawURLEncoding.EncodeToString(raw) != testJWSSignature {
+		t.Errorf("signRaw() failed by signature mismatch: %v", err)
+	}
+
+	// the RFC token has no kid, every Ed25519 key is tried
+	payload, key, err := VerifyJWS(testJWSInput+"."+testJWSSignature, Keyring{pub})
+	if err != nil || string(payload) != "Example of Ed25519 signing" || key != pub {
+		t.Errorf("VerifyJWS() failed: %q %v", payload, err)
+	}
+}
+
+func TestJWS(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	claims := `{"sub":"build-42","exp":1700000000}`
+	token, err := SignJWS(priv, []byte(claims))
+	if err != nil {
+		t.Fatalf("SignJWS() failed: %v", err)
+	}
+
+	header, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if string(header) != `{"alg":"EdDSA","kid":"`+priv.Id().String()+`"}` {
+		t.Errorf("SignJWS() failed by header: %s", header)
+	}
+
+	payload, key, err := VerifyJWS(token, Keyring{other, pub})
+	if err != nil || string(payload) != claims || key != pub {
+		t.Errorf("VerifyJWS() failed: %v", err)
+	}
+
+	_, _, err = VerifyJWS(token, Keyring{other})
+	if err != ErrKeyNotFound {
+		t.Errorf("VerifyJWS() with other key failed: %v", err)
+	}
+}
+
+func TestJWSJSON(t *testing.T) {
+	priv1, pub1, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	priv2, pub2, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	data, err := SignJWSJSON([]byte("release 1.2.3"), priv1, priv2)
+	if err != nil {
+		t.Fatalf("SignJWSJSON() failed: %v", err)
+	}
+
+	for _, pub := range []PublicKey{pub1, pub2} {
+		payload, key, err := VerifyJWSJSON(data, Keyring{pub})
+		if err != nil || string(payload) != "release 1.2.3" || key != pub {
+			t.Errorf("VerifyJWSJSON() failed: %v", err)
+		}
+	}
+
+	// flattened serialization of the first signature
+	var general jwsJSON
+	json.Unmarshal(data, &general)
+	flattened, _ := json.Marshal(jwsJSON{
+		Payload:   general.Payload,
+		Protected: general.Signatures[0].Protected,
+		Signature: general.Signatures[0].Signature,
+	})
+
+	_, key, err := VerifyJWSJSON(flattened, Keyring{pub2, pub1})
+	if err != nil || key != pub1 {
+		t.Errorf("VerifyJWSJSON() flattened failed: %v", err)
+	}
+
+	_, _, err = VerifyJWSJSON(flattened, Keyring{pub2})
+	if err != ErrKeyNotFound {
+		t.Errorf("VerifyJWSJSON() flattened with other key failed: %v", err)
+	}
+}
+
+func TestJWS_Bad(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	ecPriv, ecPub, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+	keyring := Keyring{pub, ecPub}
+	kid := `"kid":"` + pub.Id().String() + `"`
+
+	tokens := map[string]error{
+		// alg none, with and without a signature
+		testJWS(`{"alg":"none",`+kid+`}`, "{}", nil):  ErrJWSAlgorithm,
+		testJWS(`{"alg":"none",`+kid+`}`, "{}", priv): ErrJWSAlgorithm,
+		testJWS(`{"alg":"NONE"}`, "{}", nil):          ErrJWSAlgorithm,
+		// the public key used as an HMAC secret
+		testJWS(`{"alg":"HS256",`+kid+`}`, "{}", nil): ErrJWSAlgorithm,
+		// EdDSA claimed for an ECDSA key
+		testJWS(`{"alg":"EdDSA","kid":"`+ecPub.Id().String()+`"}`, "{}", priv): ErrJWSAlgorithm,
+		// unknown critical extension
+		testJWS(`{"alg":"EdDSA","crit":["b64"],"b64":false,`+kid+`}`, "{}", priv): ErrInvalidJWS,
+		// valid header, unsigned
+		testJWS(`{"alg":"EdDSA",`+kid+`}`, "{}", nil): ErrInvalidSignature,
+		"a.b":              ErrInvalidJWS,
+		"a.b.c.d":          ErrInvalidJWS,
+		"!!.e30.":          ErrInvalidJWS,
+		"e30.e30.":         ErrJWSAlgorithm,
+		testJWSInput + ".": ErrInvalidSignature,
+	}
+
+	for token, want := range tokens {
+		if _, _, err := VerifyJWS(token, keyring); err != want {
+			t.Errorf("VerifyJWS(%q) failed: %v", token, err)
+		}
+	}
+
+	token, _ := SignJWS(priv, []byte(`{"admin":false}`))
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"admin":true}`))
+	if _, _, err = VerifyJWS(strings.Join(parts, "."), keyring); err != ErrInvalidSignature {
+		t.Errorf("VerifyJWS() with modified payload failed: %v", err)
+	}
+
+	_, err = SignJWS(ecPriv, []byte("{}"))
+	if err != ErrJWSAlgorithm {
+		t.Errorf("SignJWS() with ECDSA key failed: %v", err)
+	}
+
+	_, err = SignJWSJSON([]byte("{}"))
+	if err != ErrNilKey {
+		t.Errorf("SignJWSJSON() without keys failed: %v", err)
+	}
+
+	_, _, err = VerifyJWSJSON([]byte(`{"payload":"e30","signatures":[]}`), keyring)
+	if err != ErrInvalidJWS {
+		t.Errorf("VerifyJWSJSON() without signatures failed: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package msign
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var ErrKeyNotFound = errors.New("no matching key in keyring")
+
+// Keyring is a set of trusted public keys
+type Keyring []PublicKey
+
+// ReadKeyring reads a bundle of public keys, see ReadBundle
+func ReadKeyring(r io.Reader) (Keyring, error) {
+	items, err := ReadBundle(r)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := make(Keyring, 0, len(items))
+	for _, item := range items {
+		if item.PublicKey == nil {
+			return nil, ErrInvalidPubFormat
+		}
+		keyring = append(keyring, item.PublicKey)
+	}
+
+	return keyring, nil
+}
+
+// Find returns the key that made sig, by key id or by full fingerprint when
+// the signature carries one
+func (k Keyring) Find(sig Signature) (PublicKey, error) {
+	if sig == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	fingerprint := sig.Fingerprint()
+	for _, pub := range k {
+		if fingerprint != nil {
+			if bytes.Equal(pub.Fingerprint(), fingerprint) {
+				return pub, nil
+			}
+			continue
+		}
+
+		if bytes.Equal(pub.Id(), sig.KeyId()) {
+			return pub, nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// Verify verifies sig with the matching key of the keyring and returns that key
+func (k Keyring) Verify(message io.Reader, sig Signature) (PublicKey, error) {
+	pub, err := k.Find(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := pub.Verify(message, sig)
+	if err != nil {
+		return nil, err
+	}
+	if !v {
+		return nil, ErrInvalidSignature
+	}
+
+	return pub, nil
+}
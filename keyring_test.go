@@ -0,0 +1,57 @@
+package msign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	priv1, pub1, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	priv2, pub2, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	bw := NewBundleWriter(buf)
+	bw.Comment("trusted keys")
+	bw.Write(pub1)
+	bw.Write(pub2)
+
+	keyring, err := ReadKeyring(buf)
+	if err != nil || len(keyring) != 2 {
+		t.Fatalf("ReadKeyring() failed: %v", err)
+	}
+
+	sig1, _ := priv1.Sign(strings.NewReader("Hello World!"))
+	sig2, _ := priv2.SignWithOptions(strings.NewReader("Hello World!"), &SignOptions{EmbedFingerprint: true})
+
+	for i, sig := range []Signature{sig1, sig2} {
+		pub, err := keyring.Verify(strings.NewReader("Hello World!"), sig)
+		if err != nil {
+			t.Fatalf("Verify() %d: failed: %v", i, err)
+		}
+		if !bytes.Equal(pub.Id(), sig.KeyId()) {
+			t.Errorf("Verify() %d: failed by key mismatch", i)
+		}
+	}
+
+	_, err = keyring.Verify(strings.NewReader("Hello World?"), sig1)
+	if err != ErrInvalidSignature {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	_, err = Keyring{pub2}.Find(sig1)
+	if err != ErrKeyNotFound {
+		t.Errorf("Find() failed: %v", err)
+	}
+
+	_, err = ReadKeyring(strings.NewReader(testPublicKey + testSignature))
+	if err != ErrInvalidPubFormat {
+		t.Errorf("ReadKeyring() failed: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package msign
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// PublicKeyValue holds a PublicKey for encoding/json, encoding.TextUnmarshaler,
+// encoding.BinaryUnmarshaler and database/sql, a nil key encodes as null.
+// Public keys and signatures marshal to the same text without a holder, but
+// decoding needs one since PublicKey and Signature are interfaces
+type PublicKeyValue struct {
+	PublicKey
+}
+
+// SignatureValue holds a Signature for encoding/json, encoding.TextUnmarshaler,
+// encoding.BinaryUnmarshaler and database/sql, a nil signature encodes as null
+type SignatureValue struct {
+	Signature
+}
+
+// PrivateKeyValue opts a PrivateKey into the same encodings, private keys don't
+// marshal on their own so they can't leak through a struct by accident
+type PrivateKeyValue struct {
+	PrivateKey
+}
+
+// codec decodes the text, JSON, binary and SQL forms of one kind of item
+type codec[T any] struct {
+	prefix    string
+	errFormat error
+	decode    func([]byte) (T, error)
+}
+
+var (
+	publicKeyCodec  = codec[PublicKey]{PrefixPUB, ErrInvalidPubFormat, decodePublicKey}
+	signatureCodec  = codec[Signature]{PrefixSIG, ErrInvalidSigFormat, decodeSignature}
+	privateKeyCodec = codec[PrivateKey]{PrefixKEY, ErrInvalidKeyFormat, decodePrivateKey}
+)
+
+func (v PublicKeyValue) MarshalText() ([]byte, error) {
+	return marshalText(publicKeyCodec.prefix, v.PublicKey)
+}
+
+func (v *PublicKeyValue) UnmarshalText(text []byte) (err error) {
+	v.PublicKey, err = publicKeyCodec.text(text)
+	return err
+}
+
+func (v PublicKeyValue) MarshalJSON() ([]byte, error) {
+	return marshalJSON(publicKeyCodec.prefix, v.PublicKey)
+}
+
+func (v *PublicKeyValue) UnmarshalJSON(data []byte) (err error) {
+	v.PublicKey, err = publicKeyCodec.json(data)
+	return err
+}
+
+func (v PublicKeyValue) MarshalBinary() ([]byte, error) {
+	return marshalBinary(v.PublicKey)
+}
+
+func (v *PublicKeyValue) UnmarshalBinary(data []byte) (err error) {
+	v.PublicKey, err = publicKeyCodec.binary(data)
+	return err
+}
+
+func (v PublicKeyValue) Value() (driver.Value, error) {
+	return driverValue(publicKeyCodec.prefix, v.PublicKey)
+}
+
+func (v *PublicKeyValue) Scan(src any) (err error) {
+	v.PublicKey, err = publicKeyCodec.scan(src)
+	return err
+}
+
+func (v SignatureValue) MarshalText() ([]byte, error) {
+	return marshalText(signatureCodec.prefix, v.Signature)
+}
+
+func (v *SignatureValue) UnmarshalText(text []byte) (err error) {
+	v.Signature, err = signatureCodec.text(text)
+	return err
+}
+
+func (v SignatureValue) MarshalJSON() ([]byte, error) {
+	return marshalJSON(signatureCodec.prefix, v.Signature)
+}
+
+func (v *SignatureValue) UnmarshalJSON(data []byte) (err error) {
+	v.Signature, err = signatureCodec.json(data)
+	return err
+}
+
+func (v SignatureValue) MarshalBinary() ([]byte, error) {
+	return marshalBinary(v.Signature)
+}
+
+func (v *SignatureValue) UnmarshalBinary(data []byte) (err error) {
+	v.Signature, err = signatureCodec.binary(data)
+	return err
+}
+
+func (v SignatureValue) Value() (driver.Value, error) {
+	return driverValue(signatureCodec.prefix, v.Signature)
+}
+
+func (v *SignatureValue) Scan(src any) (err error) {
+	v.Signature, err = signatureCodec.scan(src)
+	return err
+}
+
+func (v *SignatureValue) heldSignature() Signature {
+	if v == nil {
+		return nil
+	}
+	return v.Signature
+}
+
+func (v SignatureValue) holder() signatureHolder {
+	return &v
+}
+
+func (v PrivateKeyValue) MarshalText() ([]byte, error) {
+	return marshalText(privateKeyCodec.prefix, v.PrivateKey)
+}
+
+func (v *PrivateKeyValue) UnmarshalText(text []byte) (err error) {
+	v.PrivateKey, err = privateKeyCodec.text(text)
+	return err
+}
+
+func (v PrivateKeyValue) MarshalJSON() ([]byte, error) {
+	return marshalJSON(privateKeyCodec.prefix, v.PrivateKey)
+}
+
+func (v *PrivateKeyValue) UnmarshalJSON(data []byte) (err error) {
+	v.PrivateKey, err = privateKeyCodec.json(data)
+	return err
+}
+
+func (v PrivateKeyValue) MarshalBinary() ([]byte, error) {
+	return marshalBinary(v.PrivateKey)
+}
+
+func (v *PrivateKeyValue) UnmarshalBinary(data []byte) (err error) {
+	v.PrivateKey, err = privateKeyCodec.binary(data)
+	return err
+}
+
+func (v PrivateKeyValue) Value() (driver.Value, error) {
+	return driverValue(privateKeyCodec.prefix, v.PrivateKey)
+}
+
+func (v *PrivateKeyValue) Scan(src any) (err error) {
+	v.PrivateKey, err = privateKeyCodec.scan(src)
+	return err
+}
+
+// utility functions
+
+// textOf returns the export line of an item without its trailing newline
+func textOf(prefix string, e exporter) []byte {
+	raw := e.marshal()
+	text := make([]byte, len(prefix)+base64.RawURLEncoding.EncodedLen(len(raw)))
+	copy(text, prefix)
+	base64.RawURLEncoding.Encode(text[len(prefix):], raw)
+	return text
+}
+
+func marshalText(prefix string, e exporter) ([]byte, error) {
+	if e == nil {
+		return []byte{}, nil
+	}
+	return textOf(prefix, e), nil
+}
+
+func marshalJSON(prefix string, e exporter) ([]byte, error) {
+	if e == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(string(textOf(prefix, e)))
+}
+
+func marshalBinary(e exporter) ([]byte, error) {
+	if e == nil {
+		return []byte{}, nil
+	}
+	return e.marshal(), nil
+}
+
+func driverValue(prefix string, e exporter) (driver.Value, error) {
+	if e == nil {
+		return nil, nil
+	}
+	return string(textOf(prefix, e)), nil
+}
+
+// text decodes the export line, an empty text decodes to nil
+func (c codec[T]) text(text []byte) (T, error) {
+	var zero T
+
+	line := strings.TrimSpace(string(text))
+	if line == "" {
+		return zero, nil
+	}
+
+	raw, err := decodeLine(line, c.prefix, c.errFormat)
+	if err != nil {
+		return zero, err
+	}
+
+	return c.decode(raw)
+}
+
+// json decodes a JSON string holding the export line, null decodes to nil
+func (c codec[T]) json(data []byte) (T, error) {
+	var (
+		zero T
+		text *string
+	)
+
+	err := json.Unmarshal(data, &text)
+	if err != nil || text == nil {
+		return zero, err
+	}
+
+	return c.text([]byte(*text))
+}
+
+// binary decodes the raw form, empty data decodes to nil
+func (c codec[T]) binary(data []byte) (T, error) {
+	var zero T
+
+	if len(data) == 0 {
+		return zero, nil
+	}
+
+	return c.decode(data)
+}
+
+// scan decodes a database value, text columns hold the export line while
+// binary columns may hold either the export line or the raw form
+func (c codec[T]) scan(src any) (T, error) {
+	var zero T
+
+	switch v := src.(type) {
+	case nil:
+		return zero, nil
+	case string:
+		return c.text([]byte(v))
+	case []byte:
+		if strings.HasPrefix(string(v), c.prefix) {
+			return c.text(v)
+		}
+		return c.binary(v)
+	}
+
+	return zero, ErrUnknownType
+}
@@ -0,0 +1,150 @@
+package msign
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPublicKeyValue(t *testing.T) {
+	var v PublicKeyValue
+	if err := v.UnmarshalText([]byte(testPublicKey)); err != nil {
+		t.Fatalf("UnmarshalText() failed: %v", err)
+	}
+	if v.Id().String() != testKeyID {
+		t.Errorf("UnmarshalText() failed by key id mismatch: %v", v.Id())
+	}
+
+	text, err := v.MarshalText()
+	if err != nil || string(text)+"\n" != testPublicKey {
+		t.Errorf("MarshalText() failed: %q %v", text, err)
+	}
+
+	bin, err := v.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %v", err)
+	}
+	var b PublicKeyValue
+	if err = b.UnmarshalBinary(bin); err != nil || !bytes.Equal(b.Fingerprint(), v.Fingerprint()) {
+		t.Errorf("UnmarshalBinary() failed: %v", err)
+	}
+
+	value, err := v.Value()
+	if err != nil || value != string(text) {
+		t.Errorf("Value() failed: %v %v", value, err)
+	}
+	for _, src := range []any{value, text, bin} {
+		var s PublicKeyValue
+		if err = s.Scan(src); err != nil || !bytes.Equal(s.Fingerprint(), v.Fingerprint()) {
+			t.Errorf("Scan(%T) failed: %v", src, err)
+		}
+	}
+}
+
+func TestSignatureValue_JSON(t *testing.T) {
+	priv, pub, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+	sig, err := priv.SignWithOptions(strings.NewReader("Hello World!"), &SignOptions{EmbedFingerprint: true})
+	if err != nil {
+		t.Fatalf("SignWithOptions() failed: %v", err)
+	}
+
+	type release struct {
+		Key       PublicKeyValue `json:"key"`
+		Other     SignatureValue `json:"other"`
+		Signature SignatureValue `json:"signature"`
+	}
+
+	data, err := json.Marshal(release{Key: PublicKeyValue{pub}, Signature: SignatureValue{sig}})
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+
+	// interface fields marshal to the same string as the holders
+	plain, err := json.Marshal(map[string]any{"key": pub, "signature": sig, "other": nil})
+	if err != nil || !bytes.Equal(data, plain) {
+		t.Errorf("json.Marshal() failed by mismatch: %s %s", data, plain)
+	}
+
+	var r release
+	if err = json.Unmarshal(data, &r); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	if r.Other.Signature != nil {
+		t.Errorf("json.Unmarshal() failed by null signature")
+	}
+
+	v, err := r.Key.Verify(strings.NewReader("Hello World!"), r.Signature)
+	if err != nil || !v {
+		t.Errorf("Verify() failed: %v", err)
+	}
+	v, err = r.Key.Verify(strings.NewReader("Hello World!"), &r.Signature)
+	if err != nil || !v {
+		t.Errorf("Verify() of held pointer failed: %v", err)
+	}
+	v, err = r.Key.Verify(strings.NewReader("Hello World!"), (*SignatureValue)(nil))
+	if err != ErrInvalidSignature || v {
+		t.Errorf("Verify() of nil holder failed: %v", err)
+	}
+	v, err = r.Key.Verify(strings.NewReader("Hello World!"), r.Other)
+	if err != ErrInvalidSignature || v {
+		t.Errorf("Verify() of empty holder failed: %v", err)
+	}
+}
+
+func TestPrivateKeyValue(t *testing.T) {
+	priv, _, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	// private keys only marshal through the opt-in holder
+	data, err := json.Marshal(priv)
+	if err != nil || strings.Contains(string(data), PrefixKEY) {
+		t.Errorf("json.Marshal() leaked private key: %s", data)
+	}
+
+	data, err = json.Marshal(PrivateKeyValue{priv})
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+
+	var v PrivateKeyValue
+	if err = json.Unmarshal(data, &v); err != nil || !bytes.Equal(v.Fingerprint(), priv.Fingerprint()) {
+		t.Errorf("json.Unmarshal() failed: %v", err)
+	}
+}
+
+func TestValue_Bad(t *testing.T) {
+	var p PublicKeyValue
+	if err := p.UnmarshalText([]byte(testSignature)); err != ErrInvalidPubFormat {
+		t.Errorf("UnmarshalText() failed: %v", err)
+	}
+
+	var s SignatureValue
+	if err := s.UnmarshalText([]byte(testBadSignature_5)); err != ErrInvalidSigFormat {
+		t.Errorf("UnmarshalText() failed: %v", err)
+	}
+	if err := s.UnmarshalJSON([]byte("42")); err == nil {
+		t.Errorf("UnmarshalJSON() failed: %v", err)
+	}
+	if err := s.Scan(42); err != ErrUnknownType {
+		t.Errorf("Scan() failed: %v", err)
+	}
+	if err := s.UnmarshalBinary([]byte{0xff, 1, 2}); err == nil {
+		t.Errorf("UnmarshalBinary() failed: %v", err)
+	}
+
+	// nil holders map to SQL NULL and JSON null
+	value, err := s.Value()
+	if err != nil || value != nil {
+		t.Errorf("Value() failed: %v %v", value, err)
+	}
+	data, err := s.MarshalJSON()
+	if err != nil || string(data) != "null" {
+		t.Errorf("MarshalJSON() failed: %s %v", data, err)
+	}
+}
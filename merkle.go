@@ -0,0 +1,217 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Merkle tree hashing of RFC 6962 and signed checkpoints committing to a log.
+// A checkpoint is the origin, size and root hash of the log, one per line,
+// followed by a blank line and the signature of those three lines prefixed
+// by labelCheckpoint:
+//
+//	example.com/log
+//	42
+//	<root hash, standard base64>
+//
+//	SIG:<signature as exported>
+
+// labelCheckpoint separates checkpoints from anything else the log key signs,
+// so a plain signature can't pass as a signed tree head
+const labelCheckpoint = "msign checkpoint v1\x00"
+
+var (
+	ErrInvalidProof      = errors.New("invalid Merkle proof")
+	ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+)
+
+// Checkpoint is the signed head of a log
+type Checkpoint struct {
+	Origin   string // log identity, a single line
+	Size     uint64
+	RootHash []byte
+}
+
+// Sign returns the checkpoint text signed by key
+func (c *Checkpoint) Sign(key PrivateKey) (string, error) {
+	if key == nil {
+		return "", ErrNilKey
+	}
+	if c.Origin == "" || strings.ContainsAny(c.Origin, "\r\n") || len(c.RootHash) != sha256.Size {
+		return "", ErrInvalidCheckpoint
+	}
+
+	body := c.body()
+	sig, err := key.Sign(strings.NewReader(labelCheckpoint + body))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(body + "\n")
+	err = sig.export(&b)
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// VerifyCheckpoint parses a checkpoint and verifies it with the matching key of
+// keyring, returning the checkpoint and the log key
+func VerifyCheckpoint(text string, keyring Keyring) (*Checkpoint, PublicKey, error) {
+	body, sigLine, ok := strings.Cut(text, "\n\n")
+	if !ok {
+		return nil, nil, ErrInvalidCheckpoint
+	}
+	body += "\n"
+
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if len(lines) != 3 || lines[0] == "" {
+		return nil, nil, ErrInvalidCheckpoint
+	}
+
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil || strconv.FormatUint(size, 10) != lines[1] {
+		return nil, nil, ErrInvalidCheckpoint
+	}
+	root, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || len(root) != sha256.Size {
+		return nil, nil, ErrInvalidCheckpoint
+	}
+
+	raw, err := decodeLine(strings.TrimSpace(sigLine), PrefixSIG, ErrInvalidSigFormat)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := decodeSignature(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pub, err := keyring.Verify(strings.NewReader(labelCheckpoint+body), sig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Checkpoint{Origin: lines[0], Size: size, RootHash: root}, pub, nil
+}
+
+// MerkleLeafHash is the RFC 6962 hash of a log entry
+func MerkleLeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// VerifyInclusion checks the audit path proof of the leaf hash at index in the
+// tree of size leaves with the root hash, RFC 9162 section 2.1.3.2
+func VerifyInclusion(leafHash []byte, index, size uint64, proof [][]byte, root []byte) error {
+	if index >= size {
+		return ErrInvalidProof
+	}
+
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+
+		if fn&1 == 1 || fn == sn {
+			r = merkleNodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// VerifyConsistency checks that the tree of first leaves with firstRoot is a
+// prefix of the tree of second leaves with secondRoot, RFC 9162 section 2.1.4.2
+func VerifyConsistency(first, second uint64, proof [][]byte, firstRoot, secondRoot []byte) error {
+	switch {
+	case first > second:
+		return ErrInvalidProof
+	case first == second:
+		if len(proof) != 0 || !bytes.Equal(firstRoot, secondRoot) {
+			return ErrInvalidProof
+		}
+		return nil
+	case first == 0:
+		// the empty tree is a prefix of every tree
+		if len(proof) != 0 {
+			return ErrInvalidProof
+		}
+		return nil
+	}
+
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+	if len(proof) == 0 {
+		return ErrInvalidProof
+	}
+
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+
+		if fn&1 == 1 || fn == sn {
+			fr = merkleNodeHash(c, fr)
+			sr = merkleNodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = merkleNodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, firstRoot) || !bytes.Equal(sr, secondRoot) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// utility functions
+
+func (c *Checkpoint) body() string {
+	return c.Origin + "\n" + strconv.FormatUint(c.Size, 10) + "\n" + base64.StdEncoding.EncodeToString(c.RootHash) + "\n"
+}
+
+func merkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
@@ -0,0 +1,306 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// leaves and roots of the certificate transparency reference test data
+var (
+	testMerkleLeaves = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+	testMerkleRoots  = []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+)
+
+func testLeaves(t *testing.T, n int) [][]byte {
+	t.Helper()
+
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i], _ = hex.DecodeString(testMerkleLeaves[i])
+	}
+	return leaves
+}
+
+// testMerkleRoot is MTH of RFC 6962 section 2.1
+func testMerkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return MerkleLeafHash(leaves[0])
+	}
+
+	k := testSplit(len(leaves))
+	return merkleNodeHash(testMerkleRoot(leaves[:k]), testMerkleRoot(leaves[k:]))
+}
+
+// testMerklePath is PATH of RFC 6962 section 2.1.1
+func testMerklePath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+
+	k := testSplit(len(leaves))
+	if m < k {
+		return append(testMerklePath(m, leaves[:k]), testMerkleRoot(leaves[k:]))
+	}
+	return append(testMerklePath(m-k, leaves[k:]), testMerkleRoot(leaves[:k]))
+}
+
+// testMerkleSubproof is SUBPROOF of RFC 6962 section 2.1.2
+func testMerkleSubproof(m int, leaves [][]byte, b bool) [][]byte {
+	n := len(leaves)
+	if m == n {
+		if b {
+			return nil
+		}
+		return [][]byte{testMerkleRoot(leaves)}
+	}
+
+	k := testSplit(n)
+	if m <= k {
+		return append(testMerkleSubproof(m, leaves[:k], b), testMerkleRoot(leaves[k:]))
+	}
+	return append(testMerkleSubproof(m-k, leaves[k:], false), testMerkleRoot(leaves[:k]))
+}
+
+// testSplit returns the largest power of two smaller than n
+func testSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// testMerkleTree returns the cached tree of the leaf hashes of leaves
+func testMerkleTree(leaves [][]byte) *merkleTree {
+	tree := &merkleTree{}
+	for _, leaf := range leaves {
+		tree.append(MerkleLeafHash(leaf))
+	}
+	return tree
+}
+
+// testEqualHashes reports whether two lists of hashes are equal
+func testEqualHashes(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMerkleRoot(t *testing.T) {
+	for n := 1; n <= len(testMerkleLeaves); n++ {
+		got := hex.EncodeToString(testMerkleRoot(testLeaves(t, n)))
+		if got != testMerkleRoots[n-1] {
+			t.Errorf("testMerkleRoot() of %d leaves failed: %s", n, got)
+		}
+	}
+}
+
+func TestMerkleTree(t *testing.T) {
+	leaves := testLeaves(t, len(testMerkleLeaves))
+	tree := testMerkleTree(leaves)
+
+	if tree.size() != len(leaves) {
+		t.Errorf("size() failed: %d", tree.size())
+	}
+
+	for n := 1; n <= len(leaves); n++ {
+		if got := hex.EncodeToString(tree.root(0, n)); got != testMerkleRoots[n-1] {
+			t.Errorf("root() of %d leaves failed: %s", n, got)
+		}
+	}
+
+	for start := 0; start <= len(leaves); start++ {
+		for end := start; end <= len(leaves); end++ {
+			if !bytes.Equal(tree.root(start, end), testMerkleRoot(leaves[start:end])) {
+				t.Errorf("root() of leaves %d to %d failed", start, end)
+			}
+		}
+	}
+
+	for n := 1; n <= len(leaves); n++ {
+		for m := range n {
+			if !testEqualHashes(tree.path(m, 0, n), testMerklePath(m, leaves[:n])) {
+				t.Errorf("path() of leaf %d in %d failed", m, n)
+			}
+		}
+		for m := 1; m <= n; m++ {
+			if !testEqualHashes(tree.subproof(m, 0, n, true), testMerkleSubproof(m, leaves[:n], true)) {
+				t.Errorf("subproof() of %d in %d failed", m, n)
+			}
+		}
+	}
+
+	// roots handed out don't alias the cache
+	root := tree.root(0, 4)
+	root[0] ^= 0xff
+	if hex.EncodeToString(tree.root(0, 4)) != testMerkleRoots[3] {
+		t.Errorf("root() returned the cached hash")
+	}
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	for n := 1; n <= len(testMerkleLeaves); n++ {
+		leaves := testLeaves(t, n)
+		root := testMerkleRoot(leaves)
+
+		for m := range leaves {
+			proof := testMerklePath(m, leaves)
+			leaf := MerkleLeafHash(leaves[m])
+
+			if err := VerifyInclusion(leaf, uint64(m), uint64(n), proof, root); err != nil {
+				t.Errorf("VerifyInclusion() of leaf %d in %d failed: %v", m, n, err)
+			}
+
+			if err := VerifyInclusion(leaf, uint64(m), uint64(n), append(proof, root), root); err != ErrInvalidProof {
+				t.Errorf("VerifyInclusion() of leaf %d in %d with long proof failed: %v", m, n, err)
+			}
+
+			if n > 1 {
+				if err := VerifyInclusion(leaf, uint64(m), uint64(n), proof[:len(proof)-1], root); err != ErrInvalidProof {
+					t.Errorf("VerifyInclusion() of leaf %d in %d with short proof failed: %v", m, n, err)
+				}
+				other := MerkleLeafHash(leaves[(m+1)%n])
+				if err := VerifyInclusion(other, uint64(m), uint64(n), proof, root); err != ErrInvalidProof {
+					t.Errorf("VerifyInclusion() of leaf %d in %d with other leaf failed: %v", m, n, err)
+				}
+			}
+		}
+
+		if err := VerifyInclusion(root, uint64(n), uint64(n), nil, root); err != ErrInvalidProof {
+			t.Errorf("VerifyInclusion() beyond size %d failed: %v", n, err)
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	root, _ := hex.DecodeString(testMerkleRoots[7])
+	cp := &Checkpoint{Origin: "example.com/log", Size: 8, RootHash: root}
+
+	text, err := cp.Sign(priv)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if !strings.HasPrefix(text, "example.com/log\n8\n"+base64.StdEncoding.EncodeToString(root)+"\n\nSIG:") {
+		t.Errorf("Sign() failed by text: %q", text)
+	}
+
+	got, key, err := VerifyCheckpoint(text, Keyring{other, pub})
+	if err != nil || key != pub || got.Origin != cp.Origin || got.Size != 8 || hex.EncodeToString(got.RootHash) != testMerkleRoots[7] {
+		t.Errorf("VerifyCheckpoint() failed: %v", err)
+	}
+
+	inputs := map[string]error{
+		strings.Replace(text, "\n8\n", "\n9\n", 1):                    ErrInvalidSignature,
+		strings.Replace(text, "\n8\n", "\n08\n", 1):                   ErrInvalidCheckpoint,
+		strings.Replace(text, "\n\n", "\n", 1):                        ErrInvalidCheckpoint,
+		strings.Replace(text, "SIG:", "PUB:", 1):                      ErrInvalidSigFormat,
+		"example.com/log\n8\n\n" + text[strings.Index(text, "SIG:"):]: ErrInvalidCheckpoint,
+	}
+
+	for input, want := range inputs {
+		if _, _, err := VerifyCheckpoint(input, Keyring{pub}); err != want {
+			t.Errorf("VerifyCheckpoint(%q) failed: %v", input, err)
+		}
+	}
+
+	_, _, err = VerifyCheckpoint(text, Keyring{other})
+	if err != ErrKeyNotFound {
+		t.Errorf("VerifyCheckpoint() with other key failed: %v", err)
+	}
+
+	// a plain signature of the checkpoint lines is no signed tree head
+	sig, _ := priv.Sign(strings.NewReader(cp.body()))
+	var plain strings.Builder
+	plain.WriteString(cp.body() + "\n")
+	sig.export(&plain)
+	if _, _, err = VerifyCheckpoint(plain.String(), Keyring{pub}); err != ErrInvalidSignature {
+		t.Errorf("VerifyCheckpoint() of plain signature failed: %v", err)
+	}
+
+	for _, bad := range []*Checkpoint{{Origin: "", RootHash: root}, {Origin: "a\nb", RootHash: root}, {Origin: "log", RootHash: root[:4]}} {
+		if _, err := bad.Sign(priv); err != ErrInvalidCheckpoint {
+			t.Errorf("Sign() of %+v failed: %v", bad, err)
+		}
+	}
+}
+
+func TestVerifyConsistency(t *testing.T) {
+	leaves := testLeaves(t, len(testMerkleLeaves))
+
+	for second := 1; second <= len(leaves); second++ {
+		secondRoot := testMerkleRoot(leaves[:second])
+
+		for first := 0; first <= second; first++ {
+			firstRoot := testMerkleRoot(leaves[:first])
+
+			var proof [][]byte
+			if first > 0 {
+				proof = testMerkleSubproof(first, leaves[:second], true)
+			}
+
+			if err := VerifyConsistency(uint64(first), uint64(second), proof, firstRoot, secondRoot); err != nil {
+				t.Errorf("VerifyConsistency() of %d and %d failed: %v", first, second, err)
+			}
+
+			if first == 0 || first == second {
+				continue
+			}
+
+			other := testMerkleRoot(leaves[1 : first+1])
+			if err := VerifyConsistency(uint64(first), uint64(second), proof, other, secondRoot); err != ErrInvalidProof {
+				t.Errorf("VerifyConsistency() of %d and %d with other first root failed: %v", first, second, err)
+			}
+			if err := VerifyConsistency(uint64(first), uint64(second), proof, firstRoot, firstRoot); err != ErrInvalidProof {
+				t.Errorf("VerifyConsistency() of %d and %d with other second root failed: %v", first, second, err)
+			}
+			if err := VerifyConsistency(uint64(first), uint64(second), append(proof, secondRoot), firstRoot, secondRoot); err != ErrInvalidProof {
+				t.Errorf("VerifyConsistency() of %d and %d with long proof failed: %v", first, second, err)
+			}
+			if len(proof) > 0 {
+				if err := VerifyConsistency(uint64(first), uint64(second), proof[:len(proof)-1], firstRoot, secondRoot); err != ErrInvalidProof {
+					t.Errorf("VerifyConsistency() of %d and %d with short proof failed: %v", first, second, err)
+				}
+			}
+		}
+	}
+
+	root := testMerkleRoot(leaves)
+	if err := VerifyConsistency(3, 2, nil, root, root); err != ErrInvalidProof {
+		t.Errorf("VerifyConsistency() of shrinking tree failed: %v", err)
+	}
+	if err := VerifyConsistency(0, 2, [][]byte{root}, root, root); err != ErrInvalidProof {
+		t.Errorf("VerifyConsistency() from empty tree with proof failed: %v", err)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/base64"
 	"io"
 )
 
@@ -27,13 +25,12 @@ func (p *privateKeyV1) Sign(message io.Reader) (Signature, error) {
 		return nil, ErrNilReader
 	}
 
-	sha512 := sha512.New()
-	_, err := io.Copy(sha512, message)
+	sum, err := DigestSHA512.sum(message)
 	if err != nil {
 		return nil, err
 	}
 
-	sigbytes := ed25519.Sign(ed25519.PrivateKey(p.bytes[:]), sha512.Sum(nil))
+	sigbytes := ed25519.Sign(ed25519.PrivateKey(p.bytes[:]), sum)
 	sig := &signatureV1{}
 	copy(sig.id[:], p.id[:])
 	copy(sig.bytes[:], sigbytes)
@@ -41,6 +38,39 @@ func (p *privateKeyV1) Sign(message io.Reader) (Signature, error) {
 	return sig, nil
 }
 
+// SignWithOptions signs the message with the selected digest into a version 2 signature,
+// without a digest it falls back to Sign
+func (p *privateKeyV1) SignWithOptions(message io.Reader, opts *SignOptions) (Signature, error) {
+	digest := opts.digest()
+	if digest == 0 {
+		sig, err := p.Sign(message)
+		if err != nil {
+			return nil, err
+		}
+		return opts.embed(sig, p.Fingerprint()), nil
+	}
+
+	if message == nil {
+		return nil, ErrNilReader
+	}
+
+	sum, err := digest.sum(message)
+	if err != nil {
+		return nil, err
+	}
+
+	sigbytes := ed25519.Sign(ed25519.PrivateKey(p.bytes[:]), signedDigestV2(digest, sum))
+	sig := &signatureV2{digest: digest}
+	copy(sig.id[:], p.id[:])
+	copy(sig.bytes[:], sigbytes)
+
+	return opts.embed(sig, p.Fingerprint()), nil
+}
+
+func (p *privateKeyV1) Fingerprint() Fingerprint {
+	return fingerprintOf(p.bytes[ed25519.PrivateKeySize-ed25519.PublicKeySize:])
+}
+
 func (p *privateKeyV1) Id() KeyId {
 	id := make(KeyId, sizeIDv1)
 	copy(id, p.id[:])
@@ -54,14 +84,11 @@ func (p *privateKeyV1) Public() PublicKey {
 	return pub
 }
 
-func (p *privateKeyV1) export(w io.Writer) error {
-	_, err := w.Write([]byte(PrefixKEY))
-	if err != nil {
-		return err
-	}
-
-	be := base64.NewEncoder(base64.RawURLEncoding, w)
+func (p *privateKeyV1) version() byte {
+	return VersionOne
+}
 
+func (p *privateKeyV1) marshal() []byte {
 	var priv [sizeVersion + sizeCheckv1 + sizeIDv1 + ed25519.PrivateKeySize]byte
 	priv[0] = VersionOne                                      // version
 	copy(priv[sizeVersion+sizeCheckv1:], p.id[:])             // copy id
@@ -70,18 +97,20 @@ func (p *privateKeyV1) export(w io.Writer) error {
 	check := sha256.Sum256(priv[sizeVersion+sizeCheckv1:])
 	copy(priv[sizeVersion:], check[:sizeCheckv1]) // copy check
 
-	_, err = be.Write(priv[:])
-	if err != nil {
-		return err
-	}
+	return priv[:]
+}
 
-	err = be.Close()
-	if err != nil {
-		return err
-	}
+func (p *privateKeyV1) export(w io.Writer) error {
+	return exportBytes(w, PrefixKEY, p.marshal())
+}
+
+// signRaw signs the message itself rather than its digest, as JOSE and COSE expect
+func (p *privateKeyV1) signRaw(message []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(p.bytes[:]), message), nil
+}
 
-	_, err = w.Write([]byte("\n"))
-	return err
+func (p *privateKeyV1) rawAlgorithm() string {
+	return algEdDSA
 }
 
 type publicKeyV1 struct {
@@ -90,26 +119,59 @@ type publicKeyV1 struct {
 }
 
 func (p *publicKeyV1) Verify(message io.Reader, sign Signature) (bool, error) {
+	return p.VerifyWithOptions(message, sign, nil)
+}
+
+// VerifyWithOptions verifies version 1 and version 2 signatures,
+// refusing digests outside of the allow-list in opts
+func (p *publicKeyV1) VerifyWithOptions(message io.Reader, sign Signature, opts *VerifyOptions) (bool, error) {
 	if message == nil {
 		return false, ErrNilReader
 	}
 
-	sig, ok := sign.(*signatureV1)
-	if !ok {
-		return false, ErrInvalidSignature
+	sign, err := innerSignature(sign, p.Fingerprint())
+	if err != nil {
+		return false, err
 	}
 
-	if !bytes.Equal(p.id[:], sig.id[:]) {
-		return false, ErrKeyIdMismatch
+	switch sig := sign.(type) {
+	case *signatureV1:
+		if !bytes.Equal(p.id[:], sig.id[:]) {
+			return false, ErrKeyIdMismatch
+		}
+
+		if !opts.allowed(DigestSHA512) {
+			return false, ErrDigestNotAllowed
+		}
+
+		sum, err := DigestSHA512.sum(message)
+		if err != nil {
+			return false, err
+		}
+
+		return ed25519.Verify(ed25519.PublicKey(p.bytes[:]), sum, sig.bytes[:]), nil
+	case *signatureV2:
+		if !bytes.Equal(p.id[:], sig.id[:]) {
+			return false, ErrKeyIdMismatch
+		}
+
+		if !opts.allowed(sig.digest) {
+			return false, ErrDigestNotAllowed
+		}
+
+		sum, err := sig.digest.sum(message)
+		if err != nil {
+			return false, err
+		}
+
+		return ed25519.Verify(ed25519.PublicKey(p.bytes[:]), signedDigestV2(sig.digest, sum), sig.bytes[:]), nil
 	}
 
-	sha512 := sha512.New()
-	_, err := io.Copy(sha512, message)
-	if err != nil {
-		return false, err
-	}
+	return false, ErrInvalidSignature
+}
 
-	return ed25519.Verify(ed25519.PublicKey(p.bytes[:]), sha512.Sum(nil), sig.bytes[:]), nil
+func (p *publicKeyV1) Fingerprint() Fingerprint {
+	return fingerprintOf(p.bytes[:])
 }
 
 func (p *publicKeyV1) Id() KeyId {
@@ -118,31 +180,30 @@ func (p *publicKeyV1) Id() KeyId {
 	return id
 }
 
-func (p *publicKeyV1) export(w io.Writer) error {
-	_, err := w.Write([]byte(PrefixPUB))
-	if err != nil {
-		return err
-	}
-
-	be := base64.NewEncoder(base64.RawURLEncoding, w)
-
+func (p *publicKeyV1) marshal() []byte {
 	var pub [sizeVersion + sizeIDv1 + ed25519.PublicKeySize]byte
 	pub[0] = VersionOne                // version
 	copy(pub[1:], p.id[:])             // copy id
 	copy(pub[1+sizeIDv1:], p.bytes[:]) // copy public key
 
-	_, err = be.Write(pub[:])
-	if err != nil {
-		return err
-	}
+	return pub[:]
+}
 
-	err = be.Close()
-	if err != nil {
-		return err
-	}
+func (p *publicKeyV1) export(w io.Writer) error {
+	return exportBytes(w, PrefixPUB, p.marshal())
+}
+
+func (p *publicKeyV1) MarshalText() ([]byte, error) {
+	return textOf(PrefixPUB, p), nil
+}
+
+// verifyRaw verifies a signature made by signRaw
+func (p *publicKeyV1) verifyRaw(message, sig []byte) bool {
+	return ed25519.Verify(ed25519.PublicKey(p.bytes[:]), message, sig)
+}
 
-	_, err = w.Write([]byte("\n"))
-	return err
+func (p *publicKeyV1) rawAlgorithm() string {
+	return algEdDSA
 }
 
 type signatureV1 struct {
@@ -150,20 +211,23 @@ type signatureV1 struct {
 	bytes [ed25519.SignatureSize]byte
 }
 
+// Fingerprint returns nil, version 1 signatures only carry the short key id
+func (s *signatureV1) Fingerprint() Fingerprint {
+	return nil
+}
+
 func (s *signatureV1) KeyId() KeyId {
 	id := make(KeyId, sizeIDv1)
 	copy(id, s.id[:])
 	return id
 }
 
-func (s *signatureV1) export(w io.Writer) error {
-	_, err := w.Write([]byte(PrefixSIG))
-	if err != nil {
-		return err
-	}
-
-	be := base64.NewEncoder(base64.RawURLEncoding, w)
+// Digest returns DigestSHA512, the only digest of version 1 signatures
+func (s *signatureV1) Digest() Digest {
+	return DigestSHA512
+}
 
+func (s *signatureV1) marshal() []byte {
 	var sigmsg [sizeVersion + sizeCheckv1 + sizeIDv1 + ed25519.SignatureSize]byte
 	sigmsg[0] = VersionOne // version
 
@@ -173,18 +237,15 @@ func (s *signatureV1) export(w io.Writer) error {
 	check := sha256.Sum256(sigmsg[sizeVersion+sizeCheckv1:])
 	copy(sigmsg[sizeVersion:], check[:sizeCheckv1]) // copy check
 
-	_, err = be.Write(sigmsg[:])
-	if err != nil {
-		return err
-	}
+	return sigmsg[:]
+}
 
-	err = be.Close()
-	if err != nil {
-		return err
-	}
+func (s *signatureV1) export(w io.Writer) error {
+	return exportBytes(w, PrefixSIG, s.marshal())
+}
 
-	_, err = w.Write([]byte("\n"))
-	return err
+func (s *signatureV1) MarshalText() ([]byte, error) {
+	return textOf(PrefixSIG, s), nil
 }
 
 // utility functions
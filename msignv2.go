@@ -0,0 +1,99 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"io"
+)
+
+// msign version 2 implementation, version 1 keys with a signature recording its digest algorithm
+
+const (
+	sizeDigestv2 = 1 // digest algorithm identifier size in bytes
+)
+
+type signatureV2 struct {
+	id     [sizeIDv1]byte
+	digest Digest
+	bytes  [ed25519.SignatureSize]byte
+}
+
+// Fingerprint returns nil, version 2 signatures only carry the short key id
+func (s *signatureV2) Fingerprint() Fingerprint {
+	return nil
+}
+
+func (s *signatureV2) KeyId() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, s.id[:])
+	return id
+}
+
+func (s *signatureV2) Digest() Digest {
+	return s.digest
+}
+
+func (s *signatureV2) marshal() []byte {
+	var sigmsg [sizeVersion + sizeCheckv1 + sizeIDv1 + sizeDigestv2 + ed25519.SignatureSize]byte
+	sigmsg[0] = VersionTwo // version
+
+	copy(sigmsg[sizeVersion+sizeCheckv1:], s.id[:])                          // copy id
+	sigmsg[sizeVersion+sizeCheckv1+sizeIDv1] = byte(s.digest)                // copy digest
+	copy(sigmsg[sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2:], s.bytes[:]) // copy signature
+
+	check := sha256.Sum256(sigmsg[sizeVersion+sizeCheckv1:])
+	copy(sigmsg[sizeVersion:], check[:sizeCheckv1]) // copy check
+
+	return sigmsg[:]
+}
+
+func (s *signatureV2) export(w io.Writer) error {
+	return exportBytes(w, PrefixSIG, s.marshal())
+}
+
+func (s *signatureV2) MarshalText() ([]byte, error) {
+	return textOf(PrefixSIG, s), nil
+}
+
+// utility functions
+
+// signedDigestV2 binds the digest algorithm to the signed bytes,
+// so a signature can't be replayed under a different digest
+func signedDigestV2(digest Digest, sum []byte) []byte {
+	msg := make([]byte, 0, sizeDigestv2+len(sum))
+	msg = append(msg, byte(digest))
+	return append(msg, sum...)
+}
+
+func getSignatureV2(sign []byte) (Signature, error) {
+	if len(sign) < sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2+ed25519.SignatureSize {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if sign[0] != VersionTwo {
+		return nil, ErrInvalidSigFormat
+	}
+
+	signature := &signatureV2{}
+	copy(signature.id[:], sign[sizeVersion+sizeCheckv1:sizeVersion+sizeCheckv1+sizeIDv1])
+	signature.digest = Digest(sign[sizeVersion+sizeCheckv1+sizeIDv1])
+	copy(signature.bytes[:], sign[sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2:])
+
+	// check
+	check := sha256.Sum256(sign[sizeVersion+sizeCheckv1:])
+	if !bytes.Equal(check[:sizeCheckv1], sign[sizeVersion:sizeVersion+sizeCheckv1]) {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if !signature.digest.Available() {
+		return nil, ErrUnknownDigest
+	}
+
+	return signature, nil
+}
+
+// Sanity check types implement the interfaces
+var (
+	_ Signature = &signatureV2{}
+)
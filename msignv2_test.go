@@ -0,0 +1,155 @@
+package msign
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var testDigests = []Digest{DigestSHA512, DigestSHA256, DigestSHA3_256, DigestSHA3_512}
+
+func TestSignWithOptions(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	msg := []byte("Hello World!")
+	for _, d := range testDigests {
+		sig, err := priv.SignWithOptions(bytes.NewReader(msg), &SignOptions{Digest: d})
+		if err != nil {
+			t.Errorf("SignWithOptions(%v) failed: %v", d, err)
+			continue
+		}
+
+		if sig.Digest() != d {
+			t.Errorf("SignWithOptions(%v) failed by digest mismatch: %v", d, sig.Digest())
+		}
+
+		v, err := pub.Verify(bytes.NewReader(msg), sig)
+		if err != nil || !v {
+			t.Errorf("Verify(%v) failed: %v", d, err)
+		}
+
+		v, err = pub.VerifyWithOptions(bytes.NewReader(msg), sig, &VerifyOptions{AllowedDigests: []Digest{d}})
+		if err != nil || !v {
+			t.Errorf("VerifyWithOptions(%v) failed: %v", d, err)
+		}
+
+		v, err = pub.Verify(strings.NewReader("hello World!"), sig)
+		if err != nil || v {
+			t.Errorf("Verify(%v) accepted a modified message: %v", d, err)
+		}
+	}
+}
+
+func TestSignWithOptions_Default(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	sig, err := priv.SignWithOptions(strings.NewReader("Hello World!"), nil)
+	if err != nil {
+		t.Fatalf("SignWithOptions() failed: %v", err)
+	}
+
+	if _, ok := sig.(*signatureV1); !ok {
+		t.Errorf("SignWithOptions() without digest should produce a version 1 signature: %T", sig)
+	}
+
+	v, err := pub.VerifyWithOptions(strings.NewReader("Hello World!"), sig, &VerifyOptions{AllowedDigests: []Digest{DigestSHA256}})
+	if err != ErrDigestNotAllowed || v {
+		t.Errorf("VerifyWithOptions() failed: %v", err)
+	}
+
+	_, err = priv.SignWithOptions(strings.NewReader("Hello World!"), &SignOptions{Digest: 0xff})
+	if err != ErrUnknownDigest {
+		t.Errorf("SignWithOptions() failed: %v", err)
+	}
+
+	_, err = priv.SignWithOptions(nil, &SignOptions{Digest: DigestSHA256})
+	if err != ErrNilReader {
+		t.Errorf("SignWithOptions() failed: %v", err)
+	}
+}
+
+func TestVerifyWithOptions_AllowList(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	sig, err := priv.SignWithOptions(strings.NewReader("Hello World!"), &SignOptions{Digest: DigestSHA256})
+	if err != nil {
+		t.Fatalf("SignWithOptions() failed: %v", err)
+	}
+
+	opts := &VerifyOptions{AllowedDigests: []Digest{DigestSHA3_256, DigestSHA3_512}}
+	v, err := pub.VerifyWithOptions(strings.NewReader("Hello World!"), sig, opts)
+	if err != ErrDigestNotAllowed || v {
+		t.Errorf("VerifyWithOptions() failed: %v", err)
+	}
+
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, err = other.Verify(strings.NewReader("Hello World!"), sig)
+	if err != ErrKeyIdMismatch {
+		t.Errorf("Verify() failed: %v", err)
+	}
+}
+
+func TestExportImport_SignatureV2(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	sig, err := priv.SignWithOptions(strings.NewReader("Hello World!"), &SignOptions{Digest: DigestSHA3_256})
+	if err != nil {
+		t.Fatalf("SignWithOptions() failed: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	err = Export(buf, sig)
+	if err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+
+	sig2, err := ImportSignature(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("ImportSignature() failed: %v", err)
+	}
+
+	if sig2.Digest() != DigestSHA3_256 {
+		t.Errorf("ImportSignature() failed by digest mismatch: %v", sig2.Digest())
+	}
+
+	v, err := pub.Verify(strings.NewReader("Hello World!"), sig2)
+	if err != nil || !v {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	// change the digest identifier, the check block must catch it
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(buf.String(), PrefixSIG)))
+	if err != nil {
+		t.Fatalf("DecodeString() failed: %v", err)
+	}
+	raw[sizeVersion+sizeCheckv1+sizeIDv1] = byte(DigestSHA512)
+	_, err = ImportSignature(strings.NewReader(PrefixSIG + base64.RawURLEncoding.EncodeToString(raw) + "\n"))
+	if err != ErrInvalidSigFormat {
+		t.Errorf("ImportSignature() failed: %v", err)
+	}
+}
+
+func TestDigest_String(t *testing.T) {
+	if DigestSHA3_256.String() != "SHA3-256" {
+		t.Errorf("String() failed: %v", DigestSHA3_256.String())
+	}
+	if Digest(0).String() != "unknown" || Digest(0).Available() {
+		t.Errorf("String() failed: %v", Digest(0).String())
+	}
+}
@@ -0,0 +1,336 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"math/big"
+)
+
+// msign version 3 implementation, ECDSA P-256 keys
+
+const (
+	sizeScalarv3    = 32 // private scalar size in bytes
+	sizePointv3     = 65 // uncompressed public point size in bytes
+	sizeSignaturev3 = 64 // r || s size in bytes
+	defaultDigestv3 = DigestSHA256
+)
+
+type privateKeyV3 struct {
+	id     [sizeIDv1]byte
+	scalar [sizeScalarv3]byte
+	point  [sizePointv3]byte
+}
+
+func (p *privateKeyV3) Sign(message io.Reader) (Signature, error) {
+	return p.SignWithOptions(message, nil)
+}
+
+func (p *privateKeyV3) SignWithOptions(message io.Reader, opts *SignOptions) (Signature, error) {
+	if message == nil {
+		return nil, ErrNilReader
+	}
+
+	digest := opts.digest()
+	if digest == 0 {
+		digest = defaultDigestv3
+	}
+
+	sum, err := digest.sum(message)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, p.ecdsa(), sum)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &signatureV3{digest: digest}
+	copy(sig.id[:], p.id[:])
+	r.FillBytes(sig.bytes[:sizeSignaturev3/2])
+	s.FillBytes(sig.bytes[sizeSignaturev3/2:])
+
+	return opts.embed(sig, p.Fingerprint()), nil
+}
+
+func (p *privateKeyV3) Fingerprint() Fingerprint {
+	return fingerprintOf(p.point[:])
+}
+
+func (p *privateKeyV3) Id() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, p.id[:])
+	return id
+}
+
+func (p *privateKeyV3) Public() PublicKey {
+	pub := &publicKeyV3{}
+	copy(pub.id[:], p.id[:])
+	copy(pub.point[:], p.point[:])
+	return pub
+}
+
+func (p *privateKeyV3) ecdsa() *ecdsa.PrivateKey {
+	return &ecdsa.PrivateKey{
+		PublicKey: *ecdsaPublicKeyV3(p.point[:]),
+		D:         new(big.Int).SetBytes(p.scalar[:]),
+	}
+}
+
+func (p *privateKeyV3) version() byte {
+	return VersionThree
+}
+
+func (p *privateKeyV3) marshal() []byte {
+	var priv [sizeVersion + sizeCheckv1 + sizeIDv1 + sizeScalarv3]byte
+	priv[0] = VersionThree                                     // version
+	copy(priv[sizeVersion+sizeCheckv1:], p.id[:])              // copy id
+	copy(priv[sizeVersion+sizeCheckv1+sizeIDv1:], p.scalar[:]) // copy private scalar
+
+	check := sha256.Sum256(priv[sizeVersion+sizeCheckv1:])
+	copy(priv[sizeVersion:], check[:sizeCheckv1]) // copy check
+
+	return priv[:]
+}
+
+func (p *privateKeyV3) export(w io.Writer) error {
+	return exportBytes(w, PrefixKEY, p.marshal())
+}
+
+type publicKeyV3 struct {
+	id    [sizeIDv1]byte
+	point [sizePointv3]byte
+}
+
+func (p *publicKeyV3) Verify(message io.Reader, sign Signature) (bool, error) {
+	return p.VerifyWithOptions(message, sign, nil)
+}
+
+func (p *publicKeyV3) VerifyWithOptions(message io.Reader, sign Signature, opts *VerifyOptions) (bool, error) {
+	if message == nil {
+		return false, ErrNilReader
+	}
+
+	sign, err := innerSignature(sign, p.Fingerprint())
+	if err != nil {
+		return false, err
+	}
+
+	sig, ok := sign.(*signatureV3)
+	if !ok {
+		return false, ErrInvalidSignature
+	}
+
+	if !bytes.Equal(p.id[:], sig.id[:]) {
+		return false, ErrKeyIdMismatch
+	}
+
+	if !opts.allowed(sig.digest) {
+		return false, ErrDigestNotAllowed
+	}
+
+	sum, err := sig.digest.sum(message)
+	if err != nil {
+		return false, err
+	}
+
+	r := new(big.Int).SetBytes(sig.bytes[:sizeSignaturev3/2])
+	s := new(big.Int).SetBytes(sig.bytes[sizeSignaturev3/2:])
+	return ecdsa.Verify(ecdsaPublicKeyV3(p.point[:]), sum, r, s), nil
+}
+
+func (p *publicKeyV3) Fingerprint() Fingerprint {
+	return fingerprintOf(p.point[:])
+}
+
+func (p *publicKeyV3) Id() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, p.id[:])
+	return id
+}
+
+func (p *publicKeyV3) marshal() []byte {
+	var pub [sizeVersion + sizeIDv1 + sizePointv3]byte
+	pub[0] = VersionThree                        // version
+	copy(pub[sizeVersion:], p.id[:])             // copy id
+	copy(pub[sizeVersion+sizeIDv1:], p.point[:]) // copy public point
+
+	return pub[:]
+}
+
+func (p *publicKeyV3) export(w io.Writer) error {
+	return exportBytes(w, PrefixPUB, p.marshal())
+}
+
+func (p *publicKeyV3) MarshalText() ([]byte, error) {
+	return textOf(PrefixPUB, p), nil
+}
+
+type signatureV3 struct {
+	id     [sizeIDv1]byte
+	digest Digest
+	bytes  [sizeSignaturev3]byte
+}
+
+// Fingerprint returns nil, version 3 signatures only carry the short key id
+func (s *signatureV3) Fingerprint() Fingerprint {
+	return nil
+}
+
+func (s *signatureV3) KeyId() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, s.id[:])
+	return id
+}
+
+func (s *signatureV3) Digest() Digest {
+	return s.digest
+}
+
+func (s *signatureV3) marshal() []byte {
+	var sigmsg [sizeVersion + sizeCheckv1 + sizeIDv1 + sizeDigestv2 + sizeSignaturev3]byte
+	sigmsg[0] = VersionThree // version
+
+	copy(sigmsg[sizeVersion+sizeCheckv1:], s.id[:])                          // copy id
+	sigmsg[sizeVersion+sizeCheckv1+sizeIDv1] = byte(s.digest)                // copy digest
+	copy(sigmsg[sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2:], s.bytes[:]) // copy signature
+
+	check := sha256.Sum256(sigmsg[sizeVersion+sizeCheckv1:])
+	copy(sigmsg[sizeVersion:], check[:sizeCheckv1]) // copy check
+
+	return sigmsg[:]
+}
+
+func (s *signatureV3) export(w io.Writer) error {
+	return exportBytes(w, PrefixSIG, s.marshal())
+}
+
+func (s *signatureV3) MarshalText() ([]byte, error) {
+	return textOf(PrefixSIG, s), nil
+}
+
+// utility functions
+
+// ecdsaPublicKeyV3 converts an already validated uncompressed point
+func ecdsaPublicKeyV3(point []byte) *ecdsa.PublicKey {
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(point[1 : 1+sizeScalarv3]),
+		Y:     new(big.Int).SetBytes(point[1+sizeScalarv3:]),
+	}
+}
+
+func getPublicKeyV3(pub []byte) (PublicKey, error) {
+	if len(pub) != sizeVersion+sizeIDv1+sizePointv3 {
+		return nil, ErrInvalidPubFormat
+	}
+
+	if pub[0] != VersionThree {
+		return nil, ErrInvalidPubFormat
+	}
+
+	publicKey := &publicKeyV3{}
+	copy(publicKey.id[:], pub[sizeVersion:sizeVersion+sizeIDv1])
+	copy(publicKey.point[:], pub[sizeVersion+sizeIDv1:])
+
+	// check
+	check := sha256.Sum256(publicKey.point[:])
+	if !bytes.Equal(check[:sizeIDv1], publicKey.id[:]) {
+		return nil, ErrInvalidPubFormat
+	}
+
+	_, err := ecdh.P256().NewPublicKey(publicKey.point[:])
+	if err != nil {
+		return nil, ErrInvalidPubFormat
+	}
+
+	return publicKey, nil
+}
+
+func getPrivateKeyV3(priv []byte) (PrivateKey, error) {
+	if len(priv) != sizeVersion+sizeCheckv1+sizeIDv1+sizeScalarv3 {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	if priv[0] != VersionThree {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	// check
+	check := sha256.Sum256(priv[sizeVersion+sizeCheckv1:])
+	if !bytes.Equal(check[:sizeCheckv1], priv[sizeVersion:sizeVersion+sizeCheckv1]) {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	key, err := ecdh.P256().NewPrivateKey(priv[sizeVersion+sizeCheckv1+sizeIDv1:])
+	if err != nil {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	privateKey := newPrivateKeyV3FromECDH(key)
+	if !bytes.Equal(privateKey.id[:], priv[sizeVersion+sizeCheckv1:sizeVersion+sizeCheckv1+sizeIDv1]) {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	return privateKey, nil
+}
+
+func getSignatureV3(sign []byte) (Signature, error) {
+	if len(sign) != sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2+sizeSignaturev3 {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if sign[0] != VersionThree {
+		return nil, ErrInvalidSigFormat
+	}
+
+	signature := &signatureV3{}
+	copy(signature.id[:], sign[sizeVersion+sizeCheckv1:sizeVersion+sizeCheckv1+sizeIDv1])
+	signature.digest = Digest(sign[sizeVersion+sizeCheckv1+sizeIDv1])
+	copy(signature.bytes[:], sign[sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2:])
+
+	// check
+	check := sha256.Sum256(sign[sizeVersion+sizeCheckv1:])
+	if !bytes.Equal(check[:sizeCheckv1], sign[sizeVersion:sizeVersion+sizeCheckv1]) {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if !signature.digest.Available() {
+		return nil, ErrUnknownDigest
+	}
+
+	return signature, nil
+}
+
+func newPrivateKeyV3FromECDH(key *ecdh.PrivateKey) *privateKeyV3 {
+	privateKey := &privateKeyV3{}
+	copy(privateKey.scalar[:], key.Bytes())
+	copy(privateKey.point[:], key.PublicKey().Bytes())
+
+	id := sha256.Sum256(privateKey.point[:])
+	copy(privateKey.id[:], id[:sizeIDv1])
+
+	return privateKey
+}
+
+func newPrivateKeyV3() (PrivateKey, PublicKey, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey := newPrivateKeyV3FromECDH(key)
+	return privateKey, privateKey.Public(), nil
+}
+
+// Sanity check types implement the interfaces
+var (
+	_ PublicKey  = &publicKeyV3{}
+	_ PrivateKey = &privateKeyV3{}
+	_ Signature  = &signatureV3{}
+)
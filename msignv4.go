@@ -0,0 +1,312 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"io"
+)
+
+// msign version 4 implementation, RSA-PSS keys
+
+const (
+	bitsRSAv4       = 3072 // generated modulus size in bits
+	minBitsRSAv4    = 2048 // smallest accepted modulus size in bits
+	defaultDigestv4 = DigestSHA256
+)
+
+type privateKeyV4 struct {
+	id  [sizeIDv1]byte
+	key *rsa.PrivateKey
+}
+
+func (p *privateKeyV4) Sign(message io.Reader) (Signature, error) {
+	return p.SignWithOptions(message, nil)
+}
+
+func (p *privateKeyV4) SignWithOptions(message io.Reader, opts *SignOptions) (Signature, error) {
+	if message == nil {
+		return nil, ErrNilReader
+	}
+
+	digest := opts.digest()
+	if digest == 0 {
+		digest = defaultDigestv4
+	}
+
+	sum, err := digest.sum(message)
+	if err != nil {
+		return nil, err
+	}
+
+	sigbytes, err := rsa.SignPSS(rand.Reader, p.key, digest.hash(), sum, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &signatureV4{digest: digest, bytes: sigbytes}
+	copy(sig.id[:], p.id[:])
+
+	return opts.embed(sig, p.Fingerprint()), nil
+}
+
+func (p *privateKeyV4) Fingerprint() Fingerprint {
+	return fingerprintOf(x509.MarshalPKCS1PublicKey(&p.key.PublicKey))
+}
+
+func (p *privateKeyV4) Id() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, p.id[:])
+	return id
+}
+
+func (p *privateKeyV4) Public() PublicKey {
+	pub := &publicKeyV4{key: &p.key.PublicKey}
+	copy(pub.id[:], p.id[:])
+	return pub
+}
+
+func (p *privateKeyV4) version() byte {
+	return VersionFour
+}
+
+func (p *privateKeyV4) marshal() []byte {
+	der := x509.MarshalPKCS1PrivateKey(p.key)
+
+	priv := make([]byte, sizeVersion+sizeCheckv1+sizeIDv1+len(der))
+	priv[0] = VersionFour                              // version
+	copy(priv[sizeVersion+sizeCheckv1:], p.id[:])      // copy id
+	copy(priv[sizeVersion+sizeCheckv1+sizeIDv1:], der) // copy private key
+
+	check := sha256.Sum256(priv[sizeVersion+sizeCheckv1:])
+	copy(priv[sizeVersion:], check[:sizeCheckv1]) // copy check
+
+	return priv
+}
+
+func (p *privateKeyV4) export(w io.Writer) error {
+	return exportBytes(w, PrefixKEY, p.marshal())
+}
+
+type publicKeyV4 struct {
+	id  [sizeIDv1]byte
+	key *rsa.PublicKey
+}
+
+func (p *publicKeyV4) Verify(message io.Reader, sign Signature) (bool, error) {
+	return p.VerifyWithOptions(message, sign, nil)
+}
+
+func (p *publicKeyV4) VerifyWithOptions(message io.Reader, sign Signature, opts *VerifyOptions) (bool, error) {
+	if message == nil {
+		return false, ErrNilReader
+	}
+
+	sign, err := innerSignature(sign, p.Fingerprint())
+	if err != nil {
+		return false, err
+	}
+
+	sig, ok := sign.(*signatureV4)
+	if !ok {
+		return false, ErrInvalidSignature
+	}
+
+	if !bytes.Equal(p.id[:], sig.id[:]) {
+		return false, ErrKeyIdMismatch
+	}
+
+	if !opts.allowed(sig.digest) {
+		return false, ErrDigestNotAllowed
+	}
+
+	sum, err := sig.digest.sum(message)
+	if err != nil {
+		return false, err
+	}
+
+	err = rsa.VerifyPSS(p.key, sig.digest.hash(), sum, sig.bytes, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	return err == nil, nil
+}
+
+func (p *publicKeyV4) Fingerprint() Fingerprint {
+	return fingerprintOf(x509.MarshalPKCS1PublicKey(p.key))
+}
+
+func (p *publicKeyV4) Id() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, p.id[:])
+	return id
+}
+
+func (p *publicKeyV4) marshal() []byte {
+	der := x509.MarshalPKCS1PublicKey(p.key)
+
+	pub := make([]byte, sizeVersion+sizeIDv1+len(der))
+	pub[0] = VersionFour                  // version
+	copy(pub[sizeVersion:], p.id[:])      // copy id
+	copy(pub[sizeVersion+sizeIDv1:], der) // copy public key
+
+	return pub
+}
+
+func (p *publicKeyV4) export(w io.Writer) error {
+	return exportBytes(w, PrefixPUB, p.marshal())
+}
+
+func (p *publicKeyV4) MarshalText() ([]byte, error) {
+	return textOf(PrefixPUB, p), nil
+}
+
+type signatureV4 struct {
+	id     [sizeIDv1]byte
+	digest Digest
+	bytes  []byte
+}
+
+// Fingerprint returns nil, version 4 signatures only carry the short key id
+func (s *signatureV4) Fingerprint() Fingerprint {
+	return nil
+}
+
+func (s *signatureV4) KeyId() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, s.id[:])
+	return id
+}
+
+func (s *signatureV4) Digest() Digest {
+	return s.digest
+}
+
+func (s *signatureV4) marshal() []byte {
+	sigmsg := make([]byte, sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2+len(s.bytes))
+	sigmsg[0] = VersionFour // version
+
+	copy(sigmsg[sizeVersion+sizeCheckv1:], s.id[:])                       // copy id
+	sigmsg[sizeVersion+sizeCheckv1+sizeIDv1] = byte(s.digest)             // copy digest
+	copy(sigmsg[sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2:], s.bytes) // copy signature
+
+	check := sha256.Sum256(sigmsg[sizeVersion+sizeCheckv1:])
+	copy(sigmsg[sizeVersion:], check[:sizeCheckv1]) // copy check
+
+	return sigmsg
+}
+
+func (s *signatureV4) export(w io.Writer) error {
+	return exportBytes(w, PrefixSIG, s.marshal())
+}
+
+func (s *signatureV4) MarshalText() ([]byte, error) {
+	return textOf(PrefixSIG, s), nil
+}
+
+// utility functions
+
+// idV4 derives the key id from the PKCS #1 encoding of the public key
+func idV4(pub *rsa.PublicKey) [sizeIDv1]byte {
+	var id [sizeIDv1]byte
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(pub))
+	copy(id[:], sum[:sizeIDv1])
+	return id
+}
+
+func getPublicKeyV4(pub []byte) (PublicKey, error) {
+	if len(pub) <= sizeVersion+sizeIDv1 {
+		return nil, ErrInvalidPubFormat
+	}
+
+	if pub[0] != VersionFour {
+		return nil, ErrInvalidPubFormat
+	}
+
+	key, err := x509.ParsePKCS1PublicKey(pub[sizeVersion+sizeIDv1:])
+	if err != nil || key.N.BitLen() < minBitsRSAv4 {
+		return nil, ErrInvalidPubFormat
+	}
+
+	publicKey := &publicKeyV4{key: key}
+	copy(publicKey.id[:], pub[sizeVersion:sizeVersion+sizeIDv1])
+
+	// check
+	if publicKey.id != idV4(key) {
+		return nil, ErrInvalidPubFormat
+	}
+
+	return publicKey, nil
+}
+
+func getPrivateKeyV4(priv []byte) (PrivateKey, error) {
+	if len(priv) <= sizeVersion+sizeCheckv1+sizeIDv1 {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	if priv[0] != VersionFour {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	// check
+	check := sha256.Sum256(priv[sizeVersion+sizeCheckv1:])
+	if !bytes.Equal(check[:sizeCheckv1], priv[sizeVersion:sizeVersion+sizeCheckv1]) {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(priv[sizeVersion+sizeCheckv1+sizeIDv1:])
+	if err != nil || key.N.BitLen() < minBitsRSAv4 {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	privateKey := &privateKeyV4{key: key}
+	copy(privateKey.id[:], priv[sizeVersion+sizeCheckv1:sizeVersion+sizeCheckv1+sizeIDv1])
+	if privateKey.id != idV4(&key.PublicKey) {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	return privateKey, nil
+}
+
+func getSignatureV4(sign []byte) (Signature, error) {
+	if len(sign) <= sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2 {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if sign[0] != VersionFour {
+		return nil, ErrInvalidSigFormat
+	}
+
+	signature := &signatureV4{}
+	copy(signature.id[:], sign[sizeVersion+sizeCheckv1:sizeVersion+sizeCheckv1+sizeIDv1])
+	signature.digest = Digest(sign[sizeVersion+sizeCheckv1+sizeIDv1])
+	signature.bytes = bytes.Clone(sign[sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2:])
+
+	// check
+	check := sha256.Sum256(sign[sizeVersion+sizeCheckv1:])
+	if !bytes.Equal(check[:sizeCheckv1], sign[sizeVersion:sizeVersion+sizeCheckv1]) {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if !signature.digest.Available() {
+		return nil, ErrUnknownDigest
+	}
+
+	return signature, nil
+}
+
+func newPrivateKeyV4() (PrivateKey, PublicKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, bitsRSAv4)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey := &privateKeyV4{id: idV4(&key.PublicKey), key: key}
+	return privateKey, privateKey.Public(), nil
+}
+
+// Sanity check types implement the interfaces
+var (
+	_ PublicKey  = &publicKeyV4{}
+	_ PrivateKey = &privateKeyV4{}
+	_ Signature  = &signatureV4{}
+)
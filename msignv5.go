@@ -0,0 +1,372 @@
+//go:build go1.27
+
+package msign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/mldsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+)
+
+// msign version 5 implementation, hybrid Ed25519 + ML-DSA-65 keys,
+// a signature is valid only when both component signatures are. ML-DSA
+// comes from crypto/mldsa, so older toolchains build without version 5
+
+const (
+	sizeSeedv5      = 32 // private seed size of each component in bytes
+	sizePublicv5    = ed25519.PublicKeySize + mldsa.MLDSA65PublicKeySize
+	sizeSignaturev5 = ed25519.SignatureSize + mldsa.MLDSA65SignatureSize
+	defaultDigestv5 = DigestSHA512
+)
+
+func init() {
+	keyTypes[VersionFive] = keyType{
+		name:       "Ed25519+ML-DSA-65",
+		digest:     defaultDigestv5,
+		generate:   newPrivateKeyV5,
+		privateKey: getPrivateKeyV5,
+		publicKey:  getPublicKeyV5,
+		signature:  getSignatureV5,
+	}
+}
+
+// labelv5 separates hybrid signed messages from every other msign version,
+// so neither component signature can be stripped off and reused alone
+const labelv5 = "msign hybrid v5 Ed25519+ML-DSA-65"
+
+type privateKeyV5 struct {
+	id     [sizeIDv1]byte
+	ed     ed25519.PrivateKey
+	mldsa  *mldsa.PrivateKey
+	public *publicKeyV5
+}
+
+func (p *privateKeyV5) Sign(message io.Reader) (Signature, error) {
+	return p.SignWithOptions(message, nil)
+}
+
+func (p *privateKeyV5) SignWithOptions(message io.Reader, opts *SignOptions) (Signature, error) {
+	if message == nil {
+		return nil, ErrNilReader
+	}
+
+	digest := opts.digest()
+	if digest == 0 {
+		digest = defaultDigestv5
+	}
+
+	sum, err := digest.sum(message)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := signedDigestV5(digest, sum)
+	mlsig, err := p.mldsa.Sign(rand.Reader, msg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &signatureV5{digest: digest}
+	copy(sig.id[:], p.id[:])
+	copy(sig.bytes[:], ed25519.Sign(p.ed, msg))
+	copy(sig.bytes[ed25519.SignatureSize:], mlsig)
+
+	return opts.embed(sig, p.Fingerprint()), nil
+}
+
+func (p *privateKeyV5) Fingerprint() Fingerprint {
+	return p.public.Fingerprint()
+}
+
+func (p *privateKeyV5) Id() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, p.id[:])
+	return id
+}
+
+func (p *privateKeyV5) Public() PublicKey {
+	pub := &publicKeyV5{}
+	*pub = *p.public
+	return pub
+}
+
+func (p *privateKeyV5) version() byte {
+	return VersionFive
+}
+
+func (p *privateKeyV5) marshal() []byte {
+	var priv [sizeVersion + sizeCheckv1 + sizeIDv1 + 2*sizeSeedv5]byte
+	priv[0] = VersionFive                                                     // version
+	copy(priv[sizeVersion+sizeCheckv1:], p.id[:])                             // copy id
+	copy(priv[sizeVersion+sizeCheckv1+sizeIDv1:], p.ed.Seed())                // copy Ed25519 seed
+	copy(priv[sizeVersion+sizeCheckv1+sizeIDv1+sizeSeedv5:], p.mldsa.Bytes()) // copy ML-DSA seed
+
+	check := sha256.Sum256(priv[sizeVersion+sizeCheckv1:])
+	copy(priv[sizeVersion:], check[:sizeCheckv1]) // copy check
+
+	return priv[:]
+}
+
+func (p *privateKeyV5) export(w io.Writer) error {
+	return exportBytes(w, PrefixKEY, p.marshal())
+}
+
+type publicKeyV5 struct {
+	id    [sizeIDv1]byte
+	bytes [sizePublicv5]byte // Ed25519 public key || ML-DSA-65 public key
+}
+
+func (p *publicKeyV5) Verify(message io.Reader, sign Signature) (bool, error) {
+	return p.VerifyWithOptions(message, sign, nil)
+}
+
+func (p *publicKeyV5) VerifyWithOptions(message io.Reader, sign Signature, opts *VerifyOptions) (bool, error) {
+	if message == nil {
+		return false, ErrNilReader
+	}
+
+	sign, err := innerSignature(sign, p.Fingerprint())
+	if err != nil {
+		return false, err
+	}
+
+	sig, ok := sign.(*signatureV5)
+	if !ok {
+		return false, ErrInvalidSignature
+	}
+
+	if !bytes.Equal(p.id[:], sig.id[:]) {
+		return false, ErrKeyIdMismatch
+	}
+
+	if !opts.allowed(sig.digest) {
+		return false, ErrDigestNotAllowed
+	}
+
+	sum, err := sig.digest.sum(message)
+	if err != nil {
+		return false, err
+	}
+
+	mlpub, err := mldsa.NewPublicKey(mldsa.MLDSA65(), p.bytes[ed25519.PublicKeySize:])
+	if err != nil {
+		return false, err
+	}
+
+	msg := signedDigestV5(sig.digest, sum)
+	if !ed25519.Verify(ed25519.PublicKey(p.bytes[:ed25519.PublicKeySize]), msg, sig.bytes[:ed25519.SignatureSize]) {
+		return false, nil
+	}
+
+	return mldsa.Verify(mlpub, msg, sig.bytes[ed25519.SignatureSize:], nil) == nil, nil
+}
+
+func (p *publicKeyV5) Fingerprint() Fingerprint {
+	return fingerprintOf(p.bytes[:])
+}
+
+func (p *publicKeyV5) Id() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, p.id[:])
+	return id
+}
+
+func (p *publicKeyV5) marshal() []byte {
+	var pub [sizeVersion + sizeIDv1 + sizePublicv5]byte
+	pub[0] = VersionFive                         // version
+	copy(pub[sizeVersion:], p.id[:])             // copy id
+	copy(pub[sizeVersion+sizeIDv1:], p.bytes[:]) // copy public keys
+
+	return pub[:]
+}
+
+func (p *publicKeyV5) export(w io.Writer) error {
+	return exportBytes(w, PrefixPUB, p.marshal())
+}
+
+func (p *publicKeyV5) MarshalText() ([]byte, error) {
+	return textOf(PrefixPUB, p), nil
+}
+
+type signatureV5 struct {
+	id     [sizeIDv1]byte
+	digest Digest
+	bytes  [sizeSignaturev5]byte // Ed25519 signature || ML-DSA-65 signature
+}
+
+// Fingerprint returns nil, version 5 signatures only carry the short key id
+func (s *signatureV5) Fingerprint() Fingerprint {
+	return nil
+}
+
+func (s *signatureV5) KeyId() KeyId {
+	id := make(KeyId, sizeIDv1)
+	copy(id, s.id[:])
+	return id
+}
+
+func (s *signatureV5) Digest() Digest {
+	return s.digest
+}
+
+func (s *signatureV5) marshal() []byte {
+	var sigmsg [sizeVersion + sizeCheckv1 + sizeIDv1 + sizeDigestv2 + sizeSignaturev5]byte
+	sigmsg[0] = VersionFive // version
+
+	copy(sigmsg[sizeVersion+sizeCheckv1:], s.id[:])                          // copy id
+	sigmsg[sizeVersion+sizeCheckv1+sizeIDv1] = byte(s.digest)                // copy digest
+	copy(sigmsg[sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2:], s.bytes[:]) // copy signatures
+
+	check := sha256.Sum256(sigmsg[sizeVersion+sizeCheckv1:])
+	copy(sigmsg[sizeVersion:], check[:sizeCheckv1]) // copy check
+
+	return sigmsg[:]
+}
+
+func (s *signatureV5) export(w io.Writer) error {
+	return exportBytes(w, PrefixSIG, s.marshal())
+}
+
+func (s *signatureV5) MarshalText() ([]byte, error) {
+	return textOf(PrefixSIG, s), nil
+}
+
+// utility functions
+
+// signedDigestV5 is the message both component keys sign
+func signedDigestV5(digest Digest, sum []byte) []byte {
+	msg := make([]byte, 0, len(labelv5)+sizeDigestv2+len(sum))
+	msg = append(msg, labelv5...)
+	msg = append(msg, byte(digest))
+	return append(msg, sum...)
+}
+
+// newPrivateKeyV5FromSeeds expands both component keys from their seeds
+func newPrivateKeyV5FromSeeds(edSeed, mlSeed []byte) (*privateKeyV5, error) {
+	ml, err := mldsa.NewPrivateKey(mldsa.MLDSA65(), mlSeed)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey := &privateKeyV5{
+		ed:     ed25519.NewKeyFromSeed(edSeed),
+		mldsa:  ml,
+		public: &publicKeyV5{},
+	}
+
+	copy(privateKey.public.bytes[:], privateKey.ed[ed25519.SeedSize:])
+	copy(privateKey.public.bytes[ed25519.PublicKeySize:], ml.PublicKey().Bytes())
+
+	id := sha256.Sum256(privateKey.public.bytes[:])
+	copy(privateKey.id[:], id[:sizeIDv1])
+	copy(privateKey.public.id[:], id[:sizeIDv1])
+
+	return privateKey, nil
+}
+
+func getPublicKeyV5(pub []byte) (PublicKey, error) {
+	if len(pub) != sizeVersion+sizeIDv1+sizePublicv5 {
+		return nil, ErrInvalidPubFormat
+	}
+
+	if pub[0] != VersionFive {
+		return nil, ErrInvalidPubFormat
+	}
+
+	publicKey := &publicKeyV5{}
+	copy(publicKey.id[:], pub[sizeVersion:sizeVersion+sizeIDv1])
+	copy(publicKey.bytes[:], pub[sizeVersion+sizeIDv1:])
+
+	// check
+	check := sha256.Sum256(publicKey.bytes[:])
+	if !bytes.Equal(check[:sizeIDv1], publicKey.id[:]) {
+		return nil, ErrInvalidPubFormat
+	}
+
+	_, err := mldsa.NewPublicKey(mldsa.MLDSA65(), publicKey.bytes[ed25519.PublicKeySize:])
+	if err != nil {
+		return nil, ErrInvalidPubFormat
+	}
+
+	return publicKey, nil
+}
+
+func getPrivateKeyV5(priv []byte) (PrivateKey, error) {
+	if len(priv) != sizeVersion+sizeCheckv1+sizeIDv1+2*sizeSeedv5 {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	if priv[0] != VersionFive {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	// check
+	check := sha256.Sum256(priv[sizeVersion+sizeCheckv1:])
+	if !bytes.Equal(check[:sizeCheckv1], priv[sizeVersion:sizeVersion+sizeCheckv1]) {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	seeds := priv[sizeVersion+sizeCheckv1+sizeIDv1:]
+	privateKey, err := newPrivateKeyV5FromSeeds(seeds[:sizeSeedv5], seeds[sizeSeedv5:])
+	if err != nil {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	if !bytes.Equal(privateKey.id[:], priv[sizeVersion+sizeCheckv1:sizeVersion+sizeCheckv1+sizeIDv1]) {
+		return nil, ErrInvalidKeyFormat
+	}
+
+	return privateKey, nil
+}
+
+func getSignatureV5(sign []byte) (Signature, error) {
+	if len(sign) != sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2+sizeSignaturev5 {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if sign[0] != VersionFive {
+		return nil, ErrInvalidSigFormat
+	}
+
+	signature := &signatureV5{}
+	copy(signature.id[:], sign[sizeVersion+sizeCheckv1:sizeVersion+sizeCheckv1+sizeIDv1])
+	signature.digest = Digest(sign[sizeVersion+sizeCheckv1+sizeIDv1])
+	copy(signature.bytes[:], sign[sizeVersion+sizeCheckv1+sizeIDv1+sizeDigestv2:])
+
+	// check
+	check := sha256.Sum256(sign[sizeVersion+sizeCheckv1:])
+	if !bytes.Equal(check[:sizeCheckv1], sign[sizeVersion:sizeVersion+sizeCheckv1]) {
+		return nil, ErrInvalidSigFormat
+	}
+
+	if !signature.digest.Available() {
+		return nil, ErrUnknownDigest
+	}
+
+	return signature, nil
+}
+
+func newPrivateKeyV5() (PrivateKey, PublicKey, error) {
+	var seeds [2 * sizeSeedv5]byte
+	_, err := rand.Read(seeds[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := newPrivateKeyV5FromSeeds(seeds[:sizeSeedv5], seeds[sizeSeedv5:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return privateKey, privateKey.Public(), nil
+}
+
+// Sanity check types implement the interfaces
+var (
+	_ PublicKey  = &publicKeyV5{}
+	_ PrivateKey = &privateKeyV5{}
+	_ Signature  = &signatureV5{}
+)
@@ -0,0 +1,228 @@
+//go:build go1.27
+
+package msign
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/mldsa"
+	"crypto/sha256"
+	"crypto/sha3"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func init() {
+	testKeyVersions = append(testKeyVersions, VersionFive)
+}
+
+func TestRoundTrip_Hybrid(t *testing.T) {
+	roundTrip(t, VersionFive)
+}
+
+func TestVerify_HybridRequiresBoth(t *testing.T) {
+	priv, pub, err := NewPrivateKeyWithVersion(VersionFive)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	sig, err := priv.Sign(strings.NewReader("Hello World!"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	// break the Ed25519 half
+	bad := *sig.(*signatureV5)
+	bad.bytes[0] ^= 0x01
+	v, err := pub.Verify(strings.NewReader("Hello World!"), &bad)
+	if err != nil || v {
+		t.Errorf("Verify() accepted a broken Ed25519 signature: %v", err)
+	}
+
+	// break the ML-DSA half
+	bad = *sig.(*signatureV5)
+	bad.bytes[ed25519.SignatureSize+10] ^= 0x01
+	v, err = pub.Verify(strings.NewReader("Hello World!"), &bad)
+	if err != nil || v {
+		t.Errorf("Verify() accepted a broken ML-DSA signature: %v", err)
+	}
+
+	v, err = pub.Verify(strings.NewReader("Hello World!"), sig)
+	if err != nil || !v {
+		t.Errorf("Verify() failed: %v", err)
+	}
+}
+
+// RFC 8032, section 7.1, TEST 1
+func TestHybrid_Ed25519Vector(t *testing.T) {
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	want := "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+
+	key, err := newPrivateKeyV5FromSeeds(seed, make([]byte, sizeSeedv5))
+	if err != nil {
+		t.Fatalf("newPrivateKeyV5FromSeeds() failed: %v", err)
+	}
+
+	if got := hex.EncodeToString(key.public.bytes[:ed25519.PublicKeySize]); got != want {
+		t.Errorf("Ed25519 public key mismatch: %v", got)
+	}
+}
+
+// TestHybrid_MLDSAVector derives 100 keys from a SHAKE128 seed stream and hashes
+// their public keys and deterministic signatures of the empty message. This is
+// not a NIST vector but the accumulated self-consistency check of Go's
+// crypto/mldsa tests, it pins the whole derivation and signing path
+func TestHybrid_MLDSAVector(t *testing.T) {
+	s := sha3.NewSHAKE128()
+	o := sha3.NewSHAKE128()
+	seed := make([]byte, sizeSeedv5)
+
+	for range 100 {
+		s.Read(seed)
+		key, err := newPrivateKeyV5FromSeeds(make([]byte, sizeSeedv5), seed)
+		if err != nil {
+			t.Fatalf("newPrivateKeyV5FromSeeds() failed: %v", err)
+		}
+		pk := key.public.bytes[ed25519.PublicKeySize:]
+		o.Write(pk)
+
+		sig, err := key.mldsa.SignDeterministic(nil, nil)
+		if err != nil {
+			t.Fatalf("SignDeterministic() failed: %v", err)
+		}
+		o.Write(sig)
+
+		pub, err := mldsa.NewPublicKey(mldsa.MLDSA65(), pk)
+		if err != nil {
+			t.Fatalf("NewPublicKey() failed: %v", err)
+		}
+		if err = mldsa.Verify(pub, nil, sig, nil); err != nil {
+			t.Fatalf("Verify() failed: %v", err)
+		}
+	}
+
+	sum := make([]byte, 32)
+	o.Read(sum)
+	if got := hex.EncodeToString(sum); got != "8358a1843220194417cadbc2651295cd8fc65125b5a5c1a239a16dc8b57ca199" {
+		t.Errorf("ML-DSA-65 known answer mismatch: %v", got)
+	}
+}
+
+// NIST ACVP ML-DSA keyGen (FIPS 204), the ML-DSA-65 half of the hybrid key
+// derived from each seed must be the expected public key
+func TestHybrid_MLDSAKeyGenVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/ML-DSA-65-keyGen.json")
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+
+	var vectors struct {
+		ParameterSet string `json:"parameterSet"`
+		Tests        []struct {
+			TcId int    `json:"tcId"`
+			Seed string `json:"seed"`
+			Pk   string `json:"pk"`
+		} `json:"tests"`
+	}
+	if err = json.Unmarshal(data, &vectors); err != nil || vectors.ParameterSet != "ML-DSA-65" || len(vectors.Tests) == 0 {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+
+	for _, v := range vectors.Tests {
+		seed, _ := hex.DecodeString(v.Seed)
+		key, err := newPrivateKeyV5FromSeeds(make([]byte, sizeSeedv5), seed)
+		if err != nil {
+			t.Fatalf("newPrivateKeyV5FromSeeds() of tcId %d failed: %v", v.TcId, err)
+		}
+
+		if got := hex.EncodeToString(key.public.bytes[ed25519.PublicKeySize:]); !strings.EqualFold(got, v.Pk) {
+			t.Errorf("ML-DSA-65 public key of tcId %d mismatch", v.TcId)
+		}
+	}
+}
+
+// NIST ACVP ML-DSA rejection case vectors for ML-DSA-65, draft-celi-acvp-ml-dsa
+// tables 1 and 2. They give the input of ML-DSA.Sign_internal and SHA-256 of the
+// signature, crypto/mldsa has no internal interface so μ is computed here
+// and signed as external μ
+func TestHybrid_MLDSASignVectors(t *testing.T) {
+	inputs := []struct {
+		name, seed, msg, sum string
+	}{
+		{"Path/1", "464756A985E5DF03739D95DD309C1ED9C5B04254CC294E7E7EB9B9365EE15117", "491101BBA044DE6E44A63796C33CDA051BB05A60725B87AF4BA9DB940C03AC09", "8E08EA0C8DB941685B9905A73B0B57BAD3500B1F73490480B24375B41230CC04"},
+		{"Path/2", "235A48DB4CA7916B884F424A8586EFD517E87C64AECEC0FCE9A3CC212BA1522E", "F8CE85CB2EC474FFBF5A3FFAE029CE6F4526B8D597655067F97F438B81071E9B", "AE9531A01738615B6D33C77B3FF618A86E101FDC4C8504681F0EDFA64511AD63"},
+		{"Path/3", "E13131B705A760305FEFFEBFE99082E2691A444BBEFCC3EDF67D909886200207", "CD365512C7E61BBAA130800B37F3BB46AAF1BEEF3742EA8A9010A6DD4576ED0B", "3C55E604DECA7B89A99305D7A391C35F66A17C1923F467675EC951C0948D21C9"},
+		{"Path/4", "0A4793E040A4BC0D0F37643D12C1EA1F10648724609936C76E0EC83E37209E92", "6D9C7A795E48D80A892CBF4D4558429787277E3806EB5D0BCE1640EEBBBF9AEC", "3B141110B9F56540B2D49AACDE6399974A4EAC40621E367E68D4504F294DB21B"},
+		{"Path/5", "F865B889E5022D54BABC81CA67E7EB39F1AC42F92CF5295C3DA5C9667DB1B924", "047AFAADBE020ED2D766DA85317DEDE80BE550545F0B21E3F555A990F8004258", "56308A3578360C41356BA9C97D3240E01767FA76BBBA9FD0CC6CFA9ADD088DB9"},
+		{"Count/64", "26B605C78AC762FA1634C6F91DD117C4FBFF7F3A7E7781F0CC83B6281F04AD7F", "C9B07E7DDC0274468F312F5C692A54AC73D1E34D8638E20A2CD3C788F27D4355", "12A4637E3A833A5A2A46F6A991399E544B62A230B7AA82F7366840FF6A88DE61"},
+		{"Count/73", "9191CF381BEE17475C011986EFB6AFB1EFA6997442FD33427353F1DA1AA39FC0", "E616E36E81AA1EC39262109421AE0DDDA5E3B5A8F4A252BCA27AE882538DF618", "3D758ACE312433D780403B3D4273171FB93D008B395352142C6DC5173E517310"},
+		{"Count/66", "516912C7B90A3DBE009B7478DBCAF0F5C5C9ED9699A20D0CA56CC516E5A444CD", "9247CA75F9456226A0C783DABCC33FF5B4B489575ADED543E74B29B45F9C8EF2", "E5CE267800EDF33588451050F9B4A5BF97030D045132A7E3ED9210E74028D23B"},
+		{"Count/65", "D4B841F882D50AB9E590066BAFABA0F0D04D32641C0B978E54CCAA69A6E8D2C4", "175231657B0F3C7065947999467C342064F29BFAEB553E97561407D5560E3AEB", "8830EA254AF2854BF67C2B907E2321C94FD6EFB2FDAA77669FC3A5C4426C57C9"},
+	}
+
+	for _, input := range inputs {
+		seed, _ := hex.DecodeString(input.seed)
+		msg, _ := hex.DecodeString(input.msg)
+
+		key, err := newPrivateKeyV5FromSeeds(make([]byte, sizeSeedv5), seed)
+		if err != nil {
+			t.Fatalf("newPrivateKeyV5FromSeeds() of %s failed: %v", input.name, err)
+		}
+
+		// μ = H(H(pk, 64) || M', 64)
+		tr := make([]byte, 64)
+		h := sha3.NewSHAKE256()
+		h.Write(key.public.bytes[ed25519.PublicKeySize:])
+		h.Read(tr)
+		mu := make([]byte, 64)
+		h = sha3.NewSHAKE256()
+		h.Write(tr)
+		h.Write(msg)
+		h.Read(mu)
+
+		sig, err := key.mldsa.SignDeterministic(mu, crypto.MLDSAMu)
+		if err != nil {
+			t.Fatalf("SignDeterministic() of %s failed: %v", input.name, err)
+		}
+		if sum := sha256.Sum256(sig); !strings.EqualFold(hex.EncodeToString(sum[:]), input.sum) {
+			t.Errorf("ML-DSA-65 signature of %s mismatch", input.name)
+		}
+	}
+}
+
+func TestHybrid_NoJWK(t *testing.T) {
+	_, pub, err := NewPrivateKeyWithVersion(VersionFive)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+	if _, err = ExportJWK(pub); err != ErrUnsupportedFormat {
+		t.Errorf("ExportJWK() with version 5 key failed: %v", err)
+	}
+}
+
+func TestHybrid_NoStripping(t *testing.T) {
+	priv, _, err := NewPrivateKeyWithVersion(VersionFive)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+	hybrid := priv.(*privateKeyV5)
+
+	sig, err := priv.Sign(strings.NewReader("Hello World!"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	// the Ed25519 half alone must not pass as a version 1 signature of the same key
+	v1pub := &publicKeyV1{id: hybrid.id}
+	copy(v1pub.bytes[:], hybrid.public.bytes[:ed25519.PublicKeySize])
+	v1sig := &signatureV1{id: hybrid.id}
+	copy(v1sig.bytes[:], sig.(*signatureV5).bytes[:ed25519.SignatureSize])
+
+	v, err := v1pub.Verify(strings.NewReader("Hello World!"), v1sig)
+	if err != nil || v {
+		t.Errorf("Verify() accepted a stripped signature: %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package msign
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Nonce caches let verifiers reject a signed message seen before within its
+// validity window. Entries expire after a TTL chosen by the verifier, which
+// must cover the whole window the message would otherwise be accepted in
+
+const (
+	DefaultNonceCacheSize = 1 << 16
+
+	nonceRecordTag = 'N'
+	maxNonceSize   = 1 << 10
+)
+
+var (
+	ErrInvalidNonce   = errors.New("invalid nonce")
+	ErrNonceCacheFull = errors.New("nonce cache full")
+)
+
+// NonceCache records nonces for a time
+type NonceCache interface {
+	// Add records nonce until now+ttl and reports whether it was not
+	// recorded yet or had expired
+	Add(nonce string, now time.Time, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceCache is a NonceCache holding at most a fixed number of nonces.
+// Nonces are only dropped once expired, while full of unexpired ones new
+// nonces are refused with ErrNonceCacheFull. Size it above the number of
+// nonces expected within a validity window
+type MemoryNonceCache struct {
+	mu       sync.Mutex
+	size     int
+	entries  map[string]*nonceEntry
+	expiries nonceHeap
+}
+
+// FileNonceCache is a NonceCache persisting nonces in an append-only file of
+// frames tagged 'N', see WriteFrame, so they survive restarts. The file is
+// compacted on open and whenever it doubled since
+type FileNonceCache struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	entries map[string]time.Time
+	records int // records in the file
+	limit   int // records triggering compaction
+}
+
+type nonceEntry struct {
+	nonce  string
+	expiry time.Time
+}
+
+// nonceHeap orders nonce entries by expiry, the earliest first
+type nonceHeap []*nonceEntry
+
+// NewMemoryNonceCache returns an empty cache of size nonces, zero uses DefaultNonceCacheSize
+func NewMemoryNonceCache(size int) *MemoryNonceCache {
+	if size <= 0 {
+		size = DefaultNonceCacheSize
+	}
+
+	return &MemoryNonceCache{size: size, entries: make(map[string]*nonceEntry)}
+}
+
+func (c *MemoryNonceCache) Add(nonce string, now time.Time, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.expiries) > 0 && now.After(c.expiries[0].expiry) {
+		entry := heap.Pop(&c.expiries).(*nonceEntry)
+		delete(c.entries, entry.nonce)
+	}
+
+	if _, ok := c.entries[nonce]; ok {
+		return false, nil
+	}
+	if len(c.entries) >= c.size {
+		// dropping an unexpired nonce would let its message be replayed
+		return false, ErrNonceCacheFull
+	}
+
+	entry := &nonceEntry{nonce: nonce, expiry: now.Add(ttl)}
+	heap.Push(&c.expiries, entry)
+	c.entries[nonce] = entry
+
+	return true, nil
+}
+
+// Len returns the number of cached nonces, expired ones included until dropped
+func (c *MemoryNonceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// OpenFileNonceCache opens or creates the nonce file at path, dropping
+// expired nonces
+func OpenFileNonceCache(path string) (*FileNonceCache, error) {
+	c := &FileNonceCache{path: path, entries: make(map[string]time.Time)}
+
+	f, err := os.Open(path)
+	if err == nil {
+		err = c.load(f)
+		f.Close()
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	err = c.compact(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *FileNonceCache) Add(nonce string, now time.Time, ttl time.Duration) (bool, error) {
+	if len(nonce) > maxNonceSize {
+		return false, ErrInvalidNonce
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return false, os.ErrClosed
+	}
+
+	expiry, ok := c.entries[nonce]
+	if ok && !now.After(expiry) {
+		return false, nil
+	}
+	expiry = now.Add(ttl)
+
+	// compact before recording, so a failure leaves the nonce unrecorded
+	if c.records >= c.limit {
+		err := c.compact(now)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	record := make([]byte, sizeFrameHeader+8+len(nonce))
+	record[0] = nonceRecordTag
+	binary.BigEndian.PutUint32(record[1:], uint32(8+len(nonce)))
+	binary.BigEndian.PutUint64(record[sizeFrameHeader:], uint64(expiry.UnixNano()))
+	copy(record[sizeFrameHeader+8:], nonce)
+
+	offset, err := c.f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = c.f.Write(record)
+	if err == nil {
+		err = c.f.Sync()
+	}
+	if err != nil {
+		// drop a partial record, records appended after it would not load
+		c.f.Truncate(offset)
+		return false, err
+	}
+
+	c.entries[nonce] = expiry
+	c.records++
+
+	return true, nil
+}
+
+// Len returns the number of cached nonces, expired ones included until compacted
+func (c *FileNonceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+func (c *FileNonceCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return os.ErrClosed
+	}
+
+	err := c.f.Close()
+	c.f = nil
+	return err
+}
+
+// utility functions
+
+func (h nonceHeap) Len() int           { return len(h) }
+func (h nonceHeap) Less(i, j int) bool { return h[i].expiry.Before(h[j].expiry) }
+func (h nonceHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *nonceHeap) Push(x any) {
+	*h = append(*h, x.(*nonceEntry))
+}
+
+func (h *nonceHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// load reads the records of f, a record torn by a crash during Add is dropped
+func (c *FileNonceCache) load(f *os.File) error {
+	br := bufio.NewReader(f)
+	for {
+		var header [sizeFrameHeader]byte
+		_, err := io.ReadFull(br, header[:])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		n := binary.BigEndian.Uint32(header[1:])
+		if header[0] != nonceRecordTag || n < 8 || n > 8+maxNonceSize {
+			return ErrInvalidFrame
+		}
+
+		record := make([]byte, n)
+		_, err = io.ReadFull(br, record)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		c.entries[string(record[8:])] = time.Unix(0, int64(binary.BigEndian.Uint64(record)))
+	}
+}
+
+// compact drops nonces expired at now and atomically replaces the file by
+// one holding the others
+func (c *FileNonceCache) compact(now time.Time) error {
+	var b []byte
+	for nonce, expiry := range c.entries {
+		if now.After(expiry) {
+			delete(c.entries, nonce)
+			continue
+		}
+
+		b = append(b, nonceRecordTag)
+		b = binary.BigEndian.AppendUint32(b, uint32(8+len(nonce)))
+		b = binary.BigEndian.AppendUint64(b, uint64(expiry.UnixNano()))
+		b = append(b, nonce...)
+	}
+
+	err := writeFileAtomic(c.path, b, 0o644)
+	if err != nil {
+		return err
+	}
+
+	// the file was replaced, appending to the old one would lose nonces
+	if c.f != nil {
+		c.f.Close()
+		c.f = nil
+	}
+
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	c.f = f
+	c.records = len(c.entries)
+	c.limit = max(2*c.records, 1024)
+	return nil
+}
+
+// writeFileAtomic replaces the file at path by one holding data, readers see
+// either the old or the new file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
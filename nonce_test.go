@@ -0,0 +1,138 @@
+package msign
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryNonceCache(t *testing.T) {
+	c := NewMemoryNonceCache(3)
+	now := time.Now()
+
+	add := func(nonce string, at time.Time, ttl time.Duration) bool {
+		fresh, err := c.Add(nonce, at, ttl)
+		if err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+		return fresh
+	}
+
+	if !add("a", now, time.Minute) || add("a", now, time.Minute) || add("a", now.Add(time.Minute), time.Minute) {
+		t.Errorf("Add() of duplicate failed")
+	}
+	if !add("a", now.Add(2*time.Minute), time.Minute) {
+		t.Errorf("Add() of expired nonce failed")
+	}
+
+	// expired nonces are dropped by expiry, not by insertion order
+	later := now.Add(2 * time.Minute)
+	if !add("b", later, time.Hour) || !add("c", later, time.Second) || c.Len() != 3 {
+		t.Errorf("Add() up to size failed: %d", c.Len())
+	}
+	if !add("d", later.Add(2*time.Minute), time.Minute) || c.Len() != 2 {
+		t.Errorf("Add() failed to drop expired nonces: %d", c.Len())
+	}
+	if add("b", later.Add(2*time.Minute), time.Hour) {
+		t.Errorf("Add() dropped an unexpired nonce")
+	}
+}
+
+func TestMemoryNonceCache_Full(t *testing.T) {
+	c := NewMemoryNonceCache(3)
+	now := time.Now()
+
+	for _, nonce := range []string{"a", "b", "c"} {
+		if fresh, err := c.Add(nonce, now, time.Minute); err != nil || !fresh {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+
+	// a full cache refuses new nonces instead of forgetting unexpired ones
+	if fresh, err := c.Add("d", now, time.Minute); err != ErrNonceCacheFull || fresh {
+		t.Errorf("Add() beyond size failed: %v", err)
+	}
+	if fresh, err := c.Add("a", now.Add(time.Second), time.Minute); err != nil || fresh {
+		t.Errorf("Add() replay of the first nonce failed: %v", err)
+	}
+
+	if fresh, err := c.Add("d", now.Add(2*time.Minute), time.Minute); err != nil || !fresh {
+		t.Errorf("Add() after expiry failed: %v", err)
+	}
+}
+
+func TestFileNonceCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces")
+	c, err := OpenFileNonceCache(path)
+	if err != nil {
+		t.Fatalf("OpenFileNonceCache() failed: %v", err)
+	}
+
+	now := time.Now()
+	for _, nonce := range []string{"a", "b"} {
+		if fresh, err := c.Add(nonce, now, time.Hour); err != nil || !fresh {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	if fresh, err := c.Add("short", now, time.Millisecond); err != nil || !fresh {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if fresh, _ := c.Add("a", now, time.Hour); fresh {
+		t.Errorf("Add() of duplicate failed")
+	}
+	if err = c.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if _, err = c.Add("c", now, time.Hour); err != os.ErrClosed {
+		t.Errorf("Add() after Close() failed: %v", err)
+	}
+
+	// nonces survive a restart, expired ones and a torn record are dropped
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{nonceRecordTag, 0, 0, 0, 20, 1, 2})
+	f.Close()
+
+	time.Sleep(2 * time.Millisecond)
+	c, err = OpenFileNonceCache(path)
+	if err != nil {
+		t.Fatalf("OpenFileNonceCache() failed: %v", err)
+	}
+	defer c.Close()
+
+	if c.Len() != 2 {
+		t.Errorf("OpenFileNonceCache() failed by nonces: %d", c.Len())
+	}
+	if fresh, _ := c.Add("b", now, time.Hour); fresh {
+		t.Errorf("Add() of duplicate after restart failed")
+	}
+	if fresh, _ := c.Add("short", now.Add(time.Second), time.Hour); !fresh {
+		t.Errorf("Add() of expired nonce after restart failed")
+	}
+
+	// expired records are compacted away
+	for i := range 3000 {
+		c.Add(strconv.Itoa(i), now.Add(time.Duration(i)*time.Millisecond), time.Millisecond)
+	}
+	if info, _ := os.Stat(path); c.Len() > 1100 || info.Size() > 1100*(sizeFrameHeader+12) {
+		t.Errorf("Add() failed to compact: %d", c.Len())
+	}
+
+	if _, err = c.Add(strings.Repeat("x", maxNonceSize+1), now, time.Hour); err != ErrInvalidNonce {
+		t.Errorf("Add() of large nonce failed: %v", err)
+	}
+}
+
+func TestFileNonceCache_Bad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces")
+	os.WriteFile(path, []byte("not a nonce file"), 0o644)
+
+	if _, err := OpenFileNonceCache(path); err != ErrInvalidFrame {
+		t.Errorf("OpenFileNonceCache() of other file failed: %v", err)
+	}
+	if _, err := OpenFileNonceCache(filepath.Join(path, "missing", "nonces")); err == nil {
+		t.Errorf("OpenFileNonceCache() in missing directory failed")
+	}
+}
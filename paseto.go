@@ -0,0 +1,204 @@
+package msign
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// PASETO v4.public tokens over version 1 keys. The footer carries the signing
+// KeyId as {"kid":"..."} and the message holds JSON claims, of which exp and
+// nbf are validated
+
+const pasetoHeader = "v4.public."
+
+var (
+	ErrInvalidPASETO    = errors.New("invalid PASETO token")
+	ErrPASETOKey        = errors.New("PASETO v4 requires an Ed25519 key")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+)
+
+type pasetoFooter struct {
+	KeyId string `json:"kid"`
+}
+
+type pasetoClaims struct {
+	Expiration string `json:"exp,omitempty"`
+	NotBefore  string `json:"nbf,omitempty"`
+}
+
+// SignPASETO returns a v4.public token of the JSON claims, implicit is an
+// assertion authenticated by the token without being part of it and may be nil
+func SignPASETO(key PrivateKey, claims []byte, implicit []byte) (string, error) {
+	if key == nil {
+		return "", ErrNilKey
+	}
+
+	footer, err := json.Marshal(pasetoFooter{KeyId: key.Id().String()})
+	if err != nil {
+		return "", err
+	}
+
+	return signPASETO(key, claims, footer, implicit)
+}
+
+// VerifyPASETO verifies a v4.public token with the keyring, the key is selected
+// by the kid of the footer or, without one, every Ed25519 key is tried. The
+// claims are returned once exp and nbf were checked against the current time
+func VerifyPASETO(token string, keyring Keyring, implicit []byte) ([]byte, PublicKey, error) {
+	t, err := parsePASETO(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var kid string
+	if len(t.footer) > 0 && t.footer[0] == '{' {
+		var f pasetoFooter
+		if json.Unmarshal(t.footer, &f) != nil {
+			return nil, nil, ErrInvalidPASETO
+		}
+		kid = f.KeyId
+	}
+
+	found := false
+	for _, pub := range keyring {
+		if kid != "" && pub.Id().String() != kid {
+			continue
+		}
+		found = true
+
+		err = t.verify(pub, implicit)
+		if err != nil {
+			if kid != "" {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		err = validateClaims(t.message, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return t.message, pub, nil
+	}
+
+	if !found {
+		return nil, nil, ErrKeyNotFound
+	}
+
+	return nil, nil, ErrInvalidSignature
+}
+
+// utility functions
+
+type pasetoToken struct {
+	message []byte
+	sig     []byte
+	footer  []byte // unauthenticated until verified
+}
+
+func signPASETO(key PrivateKey, message, footer, implicit []byte) (string, error) {
+	signer, ok := key.(rawSigner)
+	if !ok || signer.rawAlgorithm() != algEdDSA {
+		return "", ErrPASETOKey
+	}
+
+	sig, err := signer.signRaw(pasetoPAE([]byte(pasetoHeader), message, footer, implicit))
+	if err != nil {
+		return "", err
+	}
+
+	token := pasetoHeader + base64.RawURLEncoding.EncodeToString(append(append([]byte{}, message...), sig...))
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+
+	return token, nil
+}
+
+func parsePASETO(token string) (*pasetoToken, error) {
+	body, ok := strings.CutPrefix(token, pasetoHeader)
+	if !ok {
+		return nil, ErrInvalidPASETO
+	}
+
+	payload, encodedFooter, _ := strings.Cut(body, ".")
+	if strings.Contains(encodedFooter, ".") {
+		return nil, ErrInvalidPASETO
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(raw) < ed25519.SignatureSize {
+		return nil, ErrInvalidPASETO
+	}
+	footer, err := base64.RawURLEncoding.DecodeString(encodedFooter)
+	if err != nil {
+		return nil, ErrInvalidPASETO
+	}
+
+	return &pasetoToken{
+		message: raw[:len(raw)-ed25519.SignatureSize],
+		sig:     raw[len(raw)-ed25519.SignatureSize:],
+		footer:  footer,
+	}, nil
+}
+
+func (t *pasetoToken) verify(pub PublicKey, implicit []byte) error {
+	verifier, ok := pub.(rawVerifier)
+	if !ok || verifier.rawAlgorithm() != algEdDSA {
+		return ErrPASETOKey
+	}
+
+	if !verifier.verifyRaw(pasetoPAE([]byte(pasetoHeader), t.message, t.footer, implicit), t.sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// pasetoPAE is the pre-authentication encoding of PASETO: the piece count and
+// each piece prefixed by its length, as little endian 64 bit integers
+func pasetoPAE(pieces ...[]byte) []byte {
+	out := binary.LittleEndian.AppendUint64(nil, uint64(len(pieces)))
+	for _, piece := range pieces {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(piece))&^(1<<63))
+		out = append(out, piece...)
+	}
+	return out
+}
+
+// validateClaims checks the exp and nbf claims, both are optional
+func validateClaims(claims []byte, now time.Time) error {
+	var c pasetoClaims
+	if json.Unmarshal(claims, &c) != nil {
+		return ErrInvalidPASETO
+	}
+
+	if c.Expiration != "" {
+		exp, err := time.Parse(time.RFC3339, c.Expiration)
+		if err != nil {
+			return ErrInvalidPASETO
+		}
+		if !now.Before(exp) {
+			return ErrTokenExpired
+		}
+	}
+
+	if c.NotBefore != "" {
+		nbf, err := time.Parse(time.RFC3339, c.NotBefore)
+		if err != nil {
+			return ErrInvalidPASETO
+		}
+		if now.Before(nbf) {
+			return ErrTokenNotYetValid
+		}
+	}
+
+	return nil
+}
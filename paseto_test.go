@@ -0,0 +1,163 @@
+package msign
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// official PASETO test vectors 4-S-1 to 4-S-3
+const (
+	testPASETOSecret  = "b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+	testPASETOPayload = `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`
+	testPASETOFooter  = `{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`
+)
+
+var testPASETO = []struct {
+	name     string
+	footer   string
+	implicit string
+	token    string
+}{
+	{"4-S-1", "", "", "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA"},
+	{"4-S-2", testPASETOFooter, "", "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9v3Jt8mx_TdM2ceTGoqwrh4yDFn0XsHvvV_D0DtwQxVrJEBMl0F2caAdgnpKlt4p7xBnx1HcO-SPo8FPp214HDw.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9"},
+	{"4-S-3", testPASETOFooter, `{"test-vector":"4-S-3"}`, "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9NPWciuD3d0o5eXJXG5pJy-DiVEoyPYWs1YSTwWHNJq6DZD3je5gf-0M4JR9ipdUSJbIovzmBECeaWmaqcaP0DQ.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9"},
+}
+
+func TestPASETO_Vectors(t *testing.T) {
+	secret, _ := hex.DecodeString(testPASETOSecret)
+	priv, err := privateKeyFromCrypto(ed25519.PrivateKey(secret))
+	if err != nil {
+		t.Fatalf("privateKeyFromCrypto() failed: %v", err)
+	}
+	pub := priv.Public()
+
+	for _, v := range testPASETO {
+		token, err := signPASETO(priv, []byte(testPASETOPayload), []byte(v.footer), []byte(v.implicit))
+		if err != nil || token != v.token {
+			t.Errorf("signPASETO() %s failed: %s %v", v.name, token, err)
+		}
+
+		parsed, err := parsePASETO(v.token)
+		if err != nil || string(parsed.message) != testPASETOPayload || string(parsed.footer) != v.footer {
+			t.Fatalf("parsePASETO() %s failed: %v", v.name, err)
+		}
+
+		if err = parsed.verify(pub, []byte(v.implicit)); err != nil {
+			t.Errorf("verify() %s failed: %v", v.name, err)
+		}
+
+		if err = parsed.verify(pub, []byte("other")); err != ErrInvalidSignature {
+			t.Errorf("verify() %s with other implicit assertion failed: %v", v.name, err)
+		}
+	}
+
+	// the vectors expired in 2022
+	_, _, err = VerifyPASETO(testPASETO[0].token, Keyring{pub}, nil)
+	if err != ErrTokenExpired {
+		t.Errorf("VerifyPASETO() failed: %v", err)
+	}
+}
+
+func TestPASETO(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	exp := time.Now().Add(time.Hour).Format(time.RFC3339)
+	claims := `{"sub":"build-42","exp":"` + exp + `"}`
+
+	token, err := SignPASETO(priv, []byte(claims), []byte("tenant-1"))
+	if err != nil {
+		t.Fatalf("SignPASETO() failed: %v", err)
+	}
+
+	parsed, err := parsePASETO(token)
+	if err != nil || string(parsed.footer) != `{"kid":"`+priv.Id().String()+`"}` {
+		t.Errorf("SignPASETO() failed by footer: %v", err)
+	}
+
+	got, key, err := VerifyPASETO(token, Keyring{other, pub}, []byte("tenant-1"))
+	if err != nil || string(got) != claims || key != pub {
+		t.Errorf("VerifyPASETO() failed: %v", err)
+	}
+
+	_, _, err = VerifyPASETO(token, Keyring{pub}, []byte("tenant-2"))
+	if err != ErrInvalidSignature {
+		t.Errorf("VerifyPASETO() with other implicit assertion failed: %v", err)
+	}
+
+	_, _, err = VerifyPASETO(token, Keyring{other}, []byte("tenant-1"))
+	if err != ErrKeyNotFound {
+		t.Errorf("VerifyPASETO() with other key failed: %v", err)
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	inputs := map[string]error{
+		`{}`:                                  nil,
+		`{"exp":"2026-01-01T13:00:00+00:00"}`: nil,
+		`{"exp":"2026-01-01T12:00:00Z"}`:      ErrTokenExpired,
+		`{"exp":"2026-01-01T13:00:00+02:00"}`: ErrTokenExpired,
+		`{"nbf":"2026-01-01T11:00:00Z"}`:      nil,
+		`{"nbf":"2026-01-01T12:30:00Z"}`:      ErrTokenNotYetValid,
+		`{"exp":"tomorrow"}`:                  ErrInvalidPASETO,
+		`{"exp":1767272400}`:                  ErrInvalidPASETO,
+		`not json`:                            ErrInvalidPASETO,
+	}
+
+	for claims, want := range inputs {
+		if err := validateClaims([]byte(claims), now); err != want {
+			t.Errorf("validateClaims(%s) failed: %v", claims, err)
+		}
+	}
+}
+
+func TestPASETO_Bad(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	ecPriv, ecPub, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	token, err := SignPASETO(priv, []byte(`{}`), nil)
+	if err != nil {
+		t.Fatalf("SignPASETO() failed: %v", err)
+	}
+
+	inputs := map[string]error{
+		strings.Replace(token, "v4.public.", "v4.local.", 1):  ErrInvalidPASETO,
+		strings.Replace(token, "v4.public.", "v3.public.", 1): ErrInvalidPASETO,
+		token + ".e30":     ErrInvalidPASETO,
+		"v4.public.e30":    ErrInvalidPASETO,
+		"v4.public.!!.e30": ErrInvalidPASETO,
+	}
+
+	for input, want := range inputs {
+		if _, _, err := VerifyPASETO(input, Keyring{pub}, nil); err != want {
+			t.Errorf("VerifyPASETO(%q) failed: %v", input, err)
+		}
+	}
+
+	_, err = SignPASETO(ecPriv, []byte(`{}`), nil)
+	if err != ErrPASETOKey {
+		t.Errorf("SignPASETO() with ECDSA key failed: %v", err)
+	}
+
+	parsed, _ := parsePASETO(token)
+	if err = parsed.verify(ecPub, nil); err != ErrPASETOKey {
+		t.Errorf("verify() with ECDSA key failed: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package msign
+
+// keyType describes how a format version is generated and decoded,
+// nil functions mean the version does not define that kind of item
+type keyType struct {
+	name       string
+	digest     Digest // digest used by SignWithOptions without a requested one
+	generate   func() (PrivateKey, PublicKey, error)
+	privateKey func([]byte) (PrivateKey, error)
+	publicKey  func([]byte) (PublicKey, error)
+	signature  func([]byte) (Signature, error)
+}
+
+// keyTypes is the registry of known format versions, indexed by version byte
+var keyTypes = map[byte]keyType{}
+
+// init fills the registry, parsers of wrapping versions decode through it.
+// Version 5 registers itself in msignv5.go, built from Go 1.27 on
+func init() {
+	keyTypes[VersionOne] = keyType{
+		name:       "Ed25519",
+		digest:     DigestSHA512,
+		generate:   newPrivateKeyV1,
+		privateKey: getPrivateKeyV1,
+		publicKey:  getPublicKeyV1,
+		signature:  getSignatureV1,
+	}
+	keyTypes[VersionTwo] = keyType{
+		name:      "Ed25519 (digest)",
+		signature: getSignatureV2,
+	}
+	keyTypes[VersionThree] = keyType{
+		name:       "ECDSA P-256",
+		digest:     defaultDigestv3,
+		generate:   newPrivateKeyV3,
+		privateKey: getPrivateKeyV3,
+		publicKey:  getPublicKeyV3,
+		signature:  getSignatureV3,
+	}
+	keyTypes[VersionFour] = keyType{
+		name:       "RSA-PSS",
+		digest:     defaultDigestv4,
+		generate:   newPrivateKeyV4,
+		privateKey: getPrivateKeyV4,
+		publicKey:  getPublicKeyV4,
+		signature:  getSignatureV4,
+	}
+	keyTypes[VersionSix] = keyType{
+		name:      "fingerprint",
+		signature: getSignatureV6,
+	}
+}
+
+// KeyTypeName returns the algorithm name of a format version, empty when unknown
+func KeyTypeName(version byte) string {
+	return keyTypes[version].name
+}
+
+// signDigest returns the digest a key signs with under opts
+func signDigest(key PrivateKey, opts *SignOptions) Digest {
+	if d := opts.digest(); d != 0 {
+		return d
+	}
+
+	return keyTypes[key.version()].digest
+}
+
+// decodePrivateKey dispatches the decoded bytes to the registered version
+func decodePrivateKey(key []byte) (PrivateKey, error) {
+	if len(key) > sizeVersion {
+		if t, ok := keyTypes[key[0]]; ok && t.privateKey != nil {
+			return t.privateKey(key)
+		}
+	}
+
+	return nil, ErrInvalidKeyFormat
+}
+
+// decodePublicKey dispatches the decoded bytes to the registered version
+func decodePublicKey(pub []byte) (PublicKey, error) {
+	if len(pub) > sizeVersion {
+		if t, ok := keyTypes[pub[0]]; ok && t.publicKey != nil {
+			return t.publicKey(pub)
+		}
+	}
+
+	return nil, ErrInvalidPubFormat
+}
+
+// decodeSignature dispatches the decoded bytes to the registered version
+func decodeSignature(sig []byte) (Signature, error) {
+	if len(sig) > sizeVersion {
+		if t, ok := keyTypes[sig[0]]; ok && t.signature != nil {
+			return t.signature(sig)
+		}
+	}
+
+	return nil, ErrInvalidSigFormat
+}
@@ -0,0 +1,148 @@
+package msign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testKeyVersions are the versions generating keys in tests, version 5 is
+// added where the toolchain builds it
+var testKeyVersions = []byte{VersionOne, VersionThree}
+
+// roundTrip exports and imports a key pair and a signature of the given version
+func roundTrip(t *testing.T, version byte) {
+	t.Helper()
+
+	priv, pub, err := NewPrivateKeyWithVersion(version)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion(%d) failed: %v", version, err)
+	}
+
+	if !bytes.Equal(priv.Id(), pub.Id()) {
+		t.Errorf("Id() mismatch: %v != %v", priv.Id(), pub.Id())
+	}
+	if priv.version() != version || priv.marshal()[0] != version {
+		t.Errorf("version() mismatch: %d", priv.version())
+	}
+
+	msg := "Hello World!"
+	sig, err := priv.Sign(strings.NewReader(msg))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	// export everything
+	keyBuf, pubBuf, sigBuf := new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)
+	if err = Export(keyBuf, priv); err != nil {
+		t.Fatalf("Export() with private key failed: %v", err)
+	}
+	if err = Export(pubBuf, pub); err != nil {
+		t.Fatalf("Export() with public key failed: %v", err)
+	}
+	if err = Export(sigBuf, sig); err != nil {
+		t.Fatalf("Export() with signature failed: %v", err)
+	}
+
+	// import everything back
+	priv2, err := ImportPrivateKey(strings.NewReader(keyBuf.String()))
+	if err != nil {
+		t.Fatalf("ImportPrivateKey() failed: %v", err)
+	}
+	pub2, err := ImportPublicKey(strings.NewReader(pubBuf.String()))
+	if err != nil {
+		t.Fatalf("ImportPublicKey() failed: %v", err)
+	}
+	sig2, err := ImportSignature(strings.NewReader(sigBuf.String()))
+	if err != nil {
+		t.Fatalf("ImportSignature() failed: %v", err)
+	}
+
+	// exports must be stable
+	buf := new(bytes.Buffer)
+	if err = Export(buf, priv2); err != nil || buf.String() != keyBuf.String() {
+		t.Errorf("Export() with private key failed by value mismatch: %v", err)
+	}
+	buf.Reset()
+	if err = Export(buf, priv2.Public()); err != nil || buf.String() != pubBuf.String() {
+		t.Errorf("Export() with public key failed by value mismatch: %v", err)
+	}
+	buf.Reset()
+	if err = Export(buf, sig2); err != nil || buf.String() != sigBuf.String() {
+		t.Errorf("Export() with signature failed by value mismatch: %v", err)
+	}
+
+	v, err := pub2.Verify(strings.NewReader(msg), sig2)
+	if err != nil || !v {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	v, err = pub2.Verify(strings.NewReader("hello World!"), sig2)
+	if err != nil || v {
+		t.Errorf("Verify() accepted a modified message: %v", err)
+	}
+
+	sig3, err := priv2.SignWithOptions(strings.NewReader(msg), &SignOptions{Digest: DigestSHA3_512})
+	if err != nil {
+		t.Fatalf("SignWithOptions() failed: %v", err)
+	}
+	v, err = pub.VerifyWithOptions(strings.NewReader(msg), sig3, &VerifyOptions{AllowedDigests: []Digest{DigestSHA3_512}})
+	if err != nil || !v {
+		t.Errorf("VerifyWithOptions() failed: %v", err)
+	}
+	_, err = pub.VerifyWithOptions(strings.NewReader(msg), sig3, &VerifyOptions{AllowedDigests: []Digest{DigestSHA256}})
+	if err != ErrDigestNotAllowed {
+		t.Errorf("VerifyWithOptions() failed: %v", err)
+	}
+}
+
+func TestRoundTrip_ECDSA(t *testing.T) {
+	roundTrip(t, VersionThree)
+}
+
+func TestRoundTrip_RSAPSS(t *testing.T) {
+	roundTrip(t, VersionFour)
+}
+
+func TestNewPrivateKeyWithVersion_Bad(t *testing.T) {
+	_, _, err := NewPrivateKeyWithVersion(0)
+	if err != ErrUnknownVersion {
+		t.Errorf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	// version 2 only defines signatures
+	_, _, err = NewPrivateKeyWithVersion(VersionTwo)
+	if err != ErrUnknownVersion {
+		t.Errorf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+}
+
+func TestVerify_CrossType(t *testing.T) {
+	priv, _, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+	_, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	sig, err := priv.Sign(strings.NewReader("Hello World!"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	_, err = pub.Verify(strings.NewReader("Hello World!"), sig)
+	if err != ErrInvalidSignature {
+		t.Errorf("Verify() failed: %v", err)
+	}
+}
+
+func TestKeyTypeName(t *testing.T) {
+	if KeyTypeName(VersionThree) != "ECDSA P-256" {
+		t.Errorf("KeyTypeName() failed: %v", KeyTypeName(VersionThree))
+	}
+	if KeyTypeName(0xff) != "" {
+		t.Errorf("KeyTypeName() failed: %v", KeyTypeName(0xff))
+	}
+}
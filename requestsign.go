@@ -0,0 +1,348 @@
+package msign
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signed HTTP requests between services, modeled on RFC 9421. The body is
+// bound by a Content-Digest header (RFC 9530) and the signature travels in
+//
+//	Msign-Signature: keyid="<hex>", created=<unix>, nonce="<base64url>", headers="<names>", sig="<base64url>"
+//
+// sig is the raw binary form of an msign signature over labelRequest followed
+// by the signature base
+//
+//	"@method": POST
+//	"@authority": api.example.com
+//	"@request-target": /v1/items?id=7
+//	"content-digest": sha-256=:<base64>:
+//	"<covered header>": <values joined by ", ">
+//	"@signature-params": keyid="<hex>";created=<unix>;nonce="<base64url>";headers="<names>"
+
+const (
+	RequestSignatureHeader = "Msign-Signature"
+	ContentDigestHeader    = "Content-Digest"
+
+	DefaultRequestMaxAge = 5 * time.Minute
+	DefaultMaxBodySize   = 1 << 20
+
+	sizeRequestNonce = 16
+
+	labelRequest = "msign request v1\x00"
+)
+
+var (
+	ErrInvalidRequestSignature = errors.New("invalid request signature")
+	ErrRequestStale            = errors.New("request signature outside the accepted time window")
+	ErrRequestReplayed         = errors.New("request nonce already seen")
+	ErrBodyTooLarge            = errors.New("request body too large")
+)
+
+// SigningTransport signs every request with Key before passing it to Base
+type SigningTransport struct {
+	Key     PrivateKey
+	Headers []string          // headers covered besides method, authority, target and body
+	Base    http.RoundTripper // nil uses http.DefaultTransport
+}
+
+// RequestVerifier accepts requests signed by SigningTransport with a key of
+// Keyring, rejecting stale and replayed ones. Nonces are remembered for twice
+// MaxAge, verifiers sharing a keyring must share Nonces
+type RequestVerifier struct {
+	Keyring     Keyring
+	MaxAge      time.Duration // accepted distance of created from now, zero uses DefaultRequestMaxAge
+	Headers     []string      // headers every request must cover
+	MaxBodySize int64         // zero uses DefaultMaxBodySize
+	Nonces      NonceCache    // nil uses a MemoryNonceCache of DefaultNonceCacheSize
+
+	once   sync.Once
+	nonces NonceCache
+}
+
+type requestKey struct{}
+
+type requestSignature struct {
+	keyId   string
+	created int64
+	nonce   string
+	headers []string
+	sig     []byte
+}
+
+func (t *SigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Key == nil {
+		return nil, ErrNilKey
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a RoundTripper must not modify the request it was given
+	out := req.Clone(req.Context())
+	out.Body, out.GetBody, out.ContentLength = http.NoBody, nil, 0
+	if len(body) > 0 {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		out.ContentLength = int64(len(body))
+	}
+	out.Header.Set(ContentDigestHeader, contentDigest(body))
+
+	var n [sizeRequestNonce]byte
+	_, err := rand.Read(n[:])
+	if err != nil {
+		return nil, err
+	}
+
+	params := &requestSignature{
+		keyId:   t.Key.Id().String(),
+		created: time.Now().Unix(),
+		nonce:   base64.RawURLEncoding.EncodeToString(n[:]),
+		headers: normalizeHeaders(t.Headers),
+	}
+
+	sig, err := t.Key.Sign(strings.NewReader(requestSignatureBase(out, params)))
+	if err != nil {
+		return nil, err
+	}
+	params.sig = sig.marshal()
+	out.Header.Set(RequestSignatureHeader, params.String())
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(out)
+}
+
+// Handler returns middleware passing verified requests to next, the signing
+// key is available to next through RequestKey. Other requests are answered
+// with 401 Unauthorized
+func (v *RequestVerifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pub, err := v.Verify(r)
+		if err == ErrBodyTooLarge {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestKey{}, pub)))
+	})
+}
+
+// Verify checks the signature of r and returns the signing key, the body of
+// r is read and replaced by an equal one
+func (v *RequestVerifier) Verify(r *http.Request) (PublicKey, error) {
+	return v.verify(r, time.Now())
+}
+
+// RequestKey returns the key that signed the request of ctx, see RequestVerifier.Handler
+func RequestKey(ctx context.Context) PublicKey {
+	pub, _ := ctx.Value(requestKey{}).(PublicKey)
+	return pub
+}
+
+// utility functions
+
+func (v *RequestVerifier) verify(r *http.Request, now time.Time) (PublicKey, error) {
+	params, err := parseRequestSignature(r.Header.Get(RequestSignatureHeader))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range normalizeHeaders(v.Headers) {
+		if !slices.Contains(params.headers, name) {
+			return nil, ErrInvalidRequestSignature
+		}
+	}
+
+	maxAge := v.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultRequestMaxAge
+	}
+	created := time.Unix(params.created, 0)
+	if created.Before(now.Add(-maxAge)) || created.After(now.Add(maxAge)) {
+		return nil, ErrRequestStale
+	}
+
+	body, err := readBody(r, v.MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	if r.Header.Get(ContentDigestHeader) != contentDigest(body) {
+		return nil, ErrInvalidRequestSignature
+	}
+
+	sig, err := decodeSignature(params.sig)
+	if err != nil || sig.KeyId().String() != params.keyId {
+		return nil, ErrInvalidRequestSignature
+	}
+
+	pub, err := v.Keyring.Verify(strings.NewReader(requestSignatureBase(r, params)), sig)
+	if err != nil {
+		return nil, err
+	}
+
+	// only authentic requests may occupy the nonce cache
+	fresh, err := v.nonceCache().Add(params.keyId+"/"+params.nonce, now, 2*maxAge)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrRequestReplayed
+	}
+
+	return pub, nil
+}
+
+func (v *RequestVerifier) nonceCache() NonceCache {
+	v.once.Do(func() {
+		v.nonces = v.Nonces
+		if v.nonces == nil {
+			v.nonces = NewMemoryNonceCache(0)
+		}
+	})
+	return v.nonces
+}
+
+// readBody reads the body of r up to max bytes and replaces it by an equal one
+func readBody(r *http.Request, max int64) ([]byte, error) {
+	if max <= 0 {
+		max = DefaultMaxBodySize
+	}
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > max {
+		return nil, ErrBodyTooLarge
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// contentDigest is the RFC 9530 Content-Digest value of body
+func contentDigest(body []byte) string {
+	d := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(d[:]) + ":"
+}
+
+func requestSignatureBase(r *http.Request, params *requestSignature) string {
+	authority := r.Host
+	if authority == "" {
+		authority = r.URL.Host
+	}
+
+	var b strings.Builder
+	b.WriteString(labelRequest)
+	b.WriteString(`"@method": ` + r.Method + "\n")
+	b.WriteString(`"@authority": ` + strings.ToLower(authority) + "\n")
+	b.WriteString(`"@request-target": ` + r.URL.RequestURI() + "\n")
+	b.WriteString(`"content-digest": ` + r.Header.Get(ContentDigestHeader) + "\n")
+	for _, name := range params.headers {
+		b.WriteString(`"` + name + `": ` + strings.Join(r.Header.Values(name), ", ") + "\n")
+	}
+	b.WriteString(`"@signature-params": ` + params.base())
+	return b.String()
+}
+
+// normalizeHeaders lowercases names and drops duplicates and headers that are
+// always covered
+func normalizeHeaders(names []string) []string {
+	var out []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "content-digest" || strings.ContainsAny(name, "\" ,;=") || slices.Contains(out, name) {
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+func (p *requestSignature) base() string {
+	return `keyid="` + p.keyId + `";created=` + strconv.FormatInt(p.created, 10) +
+		`;nonce="` + p.nonce + `";headers="` + strings.Join(p.headers, " ") + `"`
+}
+
+func (p *requestSignature) String() string {
+	return `keyid="` + p.keyId + `", created=` + strconv.FormatInt(p.created, 10) +
+		`, nonce="` + p.nonce + `", headers="` + strings.Join(p.headers, " ") +
+		`", sig="` + base64.RawURLEncoding.EncodeToString(p.sig) + `"`
+}
+
+func parseRequestSignature(header string) (*requestSignature, error) {
+	p := &requestSignature{}
+	seen := make(map[string]bool)
+
+	for field := range strings.SplitSeq(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || seen[name] {
+			return nil, ErrInvalidRequestSignature
+		}
+		seen[name] = true
+
+		quoted := len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"'
+		if quoted {
+			value = value[1 : len(value)-1]
+		}
+
+		var err error
+		switch {
+		case name == "keyid" && quoted:
+			p.keyId = value
+		case name == "created" && !quoted:
+			p.created, err = strconv.ParseInt(value, 10, 64)
+		case name == "nonce" && quoted:
+			p.nonce = value
+		case name == "headers" && quoted:
+			p.headers = strings.Fields(value)
+			if !slices.Equal(p.headers, normalizeHeaders(p.headers)) {
+				err = ErrInvalidRequestSignature
+			}
+		case name == "sig" && quoted:
+			p.sig, err = base64.RawURLEncoding.DecodeString(value)
+		default:
+			err = ErrInvalidRequestSignature
+		}
+		if err != nil {
+			return nil, ErrInvalidRequestSignature
+		}
+	}
+
+	if p.keyId == "" || p.nonce == "" || !seen["created"] || !seen["headers"] || p.sig == nil {
+		return nil, ErrInvalidRequestSignature
+	}
+
+	return p, nil
+}
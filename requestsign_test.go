@@ -0,0 +1,178 @@
+package msign
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestSigning(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	v := &RequestVerifier{Keyring: Keyring{other, pub}, Headers: []string{"Content-Type"}}
+	srv := httptest.NewServer(v.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if RequestKey(r.Context()) != pub {
+			t.Errorf("RequestKey() failed")
+		}
+		io.WriteString(w, r.Method+" "+string(body))
+	})))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &SigningTransport{Key: priv, Headers: []string{"content-type", "X-Request-Id"}}}
+
+	resp, err := client.Post(srv.URL+"/v1/items?id=7", "application/json", strings.NewReader(`{"name":"widget"}`))
+	if err != nil {
+		t.Fatalf("Post() failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `POST {"name":"widget"}` {
+		t.Errorf("Post() failed: %d %s", resp.StatusCode, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/items", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Do() of GET failed: %d", resp.StatusCode)
+	}
+	if req.Header.Get(RequestSignatureHeader) != "" {
+		t.Errorf("RoundTrip() modified the request")
+	}
+
+	// unsigned requests and keys outside the keyring are refused
+	resp, err = http.Get(srv.URL + "/v1/items")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Get() without signature failed: %d", resp.StatusCode)
+	}
+
+	stranger, _, _ := NewPrivateKey()
+	resp, err = (&http.Client{Transport: &SigningTransport{Key: stranger, Headers: []string{"Content-Type"}}}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Get() with unknown key failed: %d", resp.StatusCode)
+	}
+}
+
+func TestRequestVerifier(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	// sign captures a request as signed by the transport
+	sign := func(method, url, body string) *http.Request {
+		var signed *http.Request
+		transport := &SigningTransport{Key: priv, Headers: []string{"X-Tenant"}, Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			signed = r
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})}
+
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("X-Tenant", "acme")
+		req.RequestURI = ""
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("RoundTrip() failed: %v", err)
+		}
+
+		// as received by a server
+		received := httptest.NewRequest(signed.Method, signed.URL.String(), signed.Body)
+		received.Header = signed.Header
+		return received
+	}
+
+	now := time.Now()
+	v := &RequestVerifier{Keyring: Keyring{pub}, Headers: []string{"x-tenant"}, MaxBodySize: 64}
+
+	req := sign(http.MethodPost, "http://api.example.com/v1/items?id=7", "payload")
+	key, err := v.verify(req, now)
+	if err != nil || key != pub {
+		t.Fatalf("verify() failed: %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != "payload" {
+		t.Errorf("verify() failed by body: %s", body)
+	}
+
+	req = sign(http.MethodPost, "http://api.example.com/v1/items?id=7", "payload")
+	inputs := []struct {
+		name   string
+		modify func(r *http.Request)
+		at     time.Time
+		want   error
+	}{
+		{"method", func(r *http.Request) { r.Method = http.MethodPut }, now, ErrInvalidSignature},
+		{"path", func(r *http.Request) { r.URL.Path = "/v1/admin" }, now, ErrInvalidSignature},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "id=8" }, now, ErrInvalidSignature},
+		{"authority", func(r *http.Request) { r.Host = "evil.example.com" }, now, ErrInvalidSignature},
+		{"header", func(r *http.Request) { r.Header.Set("X-Tenant", "other") }, now, ErrInvalidSignature},
+		{"body", func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader("tampered")) }, now, ErrInvalidRequestSignature},
+		{"large body", func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader(strings.Repeat("x", 65))) }, now, ErrBodyTooLarge},
+		{"stale", func(r *http.Request) {}, now.Add(6 * time.Minute), ErrRequestStale},
+		{"future", func(r *http.Request) {}, now.Add(-6 * time.Minute), ErrRequestStale},
+		{"no signature", func(r *http.Request) { r.Header.Del(RequestSignatureHeader) }, now, ErrInvalidRequestSignature},
+		{"uncovered header", func(r *http.Request) {
+			r.Header.Set(RequestSignatureHeader, strings.Replace(r.Header.Get(RequestSignatureHeader), `headers="x-tenant"`, `headers=""`, 1))
+		}, now, ErrInvalidRequestSignature},
+		{"duplicate field", func(r *http.Request) {
+			r.Header.Set(RequestSignatureHeader, r.Header.Get(RequestSignatureHeader)+`, nonce="x"`)
+		}, now, ErrInvalidRequestSignature},
+		{"unlabeled", func(r *http.Request) {
+			params, _ := parseRequestSignature(r.Header.Get(RequestSignatureHeader))
+			sig, _ := priv.Sign(strings.NewReader(strings.TrimPrefix(requestSignatureBase(r, params), labelRequest)))
+			params.sig = sig.marshal()
+			r.Header.Set(RequestSignatureHeader, params.String())
+		}, now, ErrInvalidSignature},
+		{"valid", func(r *http.Request) {}, now, nil},
+		{"replayed", func(r *http.Request) {}, now, ErrRequestReplayed},
+	}
+
+	for _, input := range inputs {
+		r := req.Clone(req.Context())
+		r.Body = io.NopCloser(strings.NewReader("payload"))
+		input.modify(r)
+
+		if _, err := v.verify(r, input.at); err != input.want {
+			t.Errorf("verify() %s failed: %v", input.name, err)
+		}
+	}
+
+	// a shared cache rejects replays across verifiers
+	nonces := NewMemoryNonceCache(0)
+	first := &RequestVerifier{Keyring: Keyring{pub}, Nonces: nonces}
+	second := &RequestVerifier{Keyring: Keyring{pub}, Nonces: nonces}
+	req = sign(http.MethodGet, "http://api.example.com/v1/items", "")
+	if _, err = first.verify(req.Clone(req.Context()), now); err != nil {
+		t.Errorf("verify() failed: %v", err)
+	}
+	if _, err = second.verify(req.Clone(req.Context()), now); err != ErrRequestReplayed {
+		t.Errorf("verify() of replay on other verifier failed: %v", err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,224 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Sigstore style verification bundles for msign keys. The JSON layout follows
+// the Sigstore bundle v0.3: a message signature, a public key hint and optional
+// transparency log entries with an inclusion proof and a signed checkpoint.
+// Signatures hold the raw binary form of the msign signature, a log entry body
+// binds artifact digest and signature, and a log id is the KeyId of the log key.
+// Verification needs nothing but the bundle, the artifact and trusted keys
+
+const (
+	SigstoreBundleMediaType = "application/vnd.dev.sigstore.bundle.v0.3+json"
+
+	logEntryKind    = "msign"
+	logEntryVersion = "0.0.1"
+	sigstoreSHA256  = "SHA2_256"
+)
+
+var ErrInvalidBundle = errors.New("invalid verification bundle")
+
+type SigstoreBundle struct {
+	MediaType            string               `json:"mediaType"`
+	VerificationMaterial VerificationMaterial `json:"verificationMaterial"`
+	MessageSignature     MessageSignature     `json:"messageSignature"`
+}
+
+type VerificationMaterial struct {
+	PublicKey   PublicKeyHint `json:"publicKey"`
+	TlogEntries []TlogEntry   `json:"tlogEntries,omitempty"`
+}
+
+// PublicKeyHint names the signing key by its KeyId in hex
+type PublicKeyHint struct {
+	Hint string `json:"hint"`
+}
+
+type MessageSignature struct {
+	MessageDigest MessageDigest `json:"messageDigest"`
+	Signature     []byte        `json:"signature"`
+}
+
+type MessageDigest struct {
+	Algorithm string `json:"algorithm"`
+	Digest    []byte `json:"digest"`
+}
+
+// TlogEntry is the record of the signature in a transparency log,
+// IntegratedTime is informative and not verified
+type TlogEntry struct {
+	LogIndex          uint64          `json:"logIndex,string"`
+	LogId             LogId           `json:"logId"`
+	KindVersion       KindVersion     `json:"kindVersion"`
+	IntegratedTime    int64           `json:"integratedTime,string"`
+	InclusionProof    *InclusionProof `json:"inclusionProof,omitempty"`
+	CanonicalizedBody []byte          `json:"canonicalizedBody"`
+}
+
+type LogId struct {
+	KeyId []byte `json:"keyId"`
+}
+
+type KindVersion struct {
+	Kind    string `json:"kind"`
+	Version string `json:"version"`
+}
+
+type InclusionProof struct {
+	LogIndex   uint64             `json:"logIndex,string"`
+	RootHash   []byte             `json:"rootHash"`
+	TreeSize   uint64             `json:"treeSize,string"`
+	Hashes     [][]byte           `json:"hashes"`
+	Checkpoint CheckpointEnvelope `json:"checkpoint"`
+}
+
+// CheckpointEnvelope holds a checkpoint as returned by Checkpoint.Sign
+type CheckpointEnvelope struct {
+	Envelope string `json:"envelope"`
+}
+
+type logEntryBody struct {
+	Kind       string       `json:"kind"`
+	APIVersion string       `json:"apiVersion"`
+	Spec       logEntrySpec `json:"spec"`
+}
+
+type logEntrySpec struct {
+	Digest    MessageDigest `json:"digest"`
+	Signature []byte        `json:"signature"`
+	KeyId     string        `json:"keyid"`
+}
+
+// NewSigstoreBundle signs the artifact with key into a bundle without log entries
+func NewSigstoreBundle(artifact io.Reader, key PrivateKey) (*SigstoreBundle, error) {
+	if artifact == nil {
+		return nil, ErrNilReader
+	}
+	if key == nil {
+		return nil, ErrNilKey
+	}
+
+	h := sha256.New()
+	sig, err := key.Sign(io.TeeReader(artifact, h))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigstoreBundle{
+		MediaType: SigstoreBundleMediaType,
+		VerificationMaterial: VerificationMaterial{
+			PublicKey: PublicKeyHint{Hint: key.Id().String()},
+		},
+		MessageSignature: MessageSignature{
+			MessageDigest: MessageDigest{Algorithm: sigstoreSHA256, Digest: h.Sum(nil)},
+			Signature:     sig.marshal(),
+		},
+	}, nil
+}
+
+// LogEntryBody returns the canonicalized body under which the signature of the
+// bundle is recorded in a transparency log
+func (b *SigstoreBundle) LogEntryBody() ([]byte, error) {
+	sig, err := decodeSignature(b.MessageSignature.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(logEntryBody{
+		Kind:       logEntryKind,
+		APIVersion: logEntryVersion,
+		Spec: logEntrySpec{
+			Digest:    b.MessageSignature.MessageDigest,
+			Signature: b.MessageSignature.Signature,
+			KeyId:     sig.KeyId().String(),
+		},
+	})
+}
+
+// Verify checks the bundle offline against the artifact and returns the signing
+// key of keyring. When logs holds log keys, at least one log entry must prove
+// inclusion under a checkpoint signed by one of them, otherwise log entries
+// are not checked
+func (b *SigstoreBundle) Verify(artifact io.Reader, keyring Keyring, logs Keyring) (PublicKey, error) {
+	if artifact == nil {
+		return nil, ErrNilReader
+	}
+
+	ms := b.MessageSignature
+	if b.MediaType != SigstoreBundleMediaType || ms.MessageDigest.Algorithm != sigstoreSHA256 || len(ms.MessageDigest.Digest) != sha256.Size {
+		return nil, ErrInvalidBundle
+	}
+
+	sig, err := decodeSignature(ms.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	hint := b.VerificationMaterial.PublicKey.Hint
+	if hint != "" && hint != sig.KeyId().String() {
+		return nil, ErrInvalidBundle
+	}
+
+	h := sha256.New()
+	pub, err := keyring.Verify(io.TeeReader(artifact, h), sig)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(h.Sum(nil), ms.MessageDigest.Digest) {
+		return nil, ErrInvalidBundle
+	}
+
+	if len(logs) == 0 {
+		return pub, nil
+	}
+
+	body, err := b.LogEntryBody()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ErrInvalidBundle // no log entry
+	for _, e := range b.VerificationMaterial.TlogEntries {
+		err = e.verify(body, logs)
+		if err == nil {
+			return pub, nil
+		}
+	}
+
+	return nil, err
+}
+
+// utility functions
+
+// verify checks that the entry records body and is included in a checkpoint
+// signed by one of the log keys
+func (e *TlogEntry) verify(body []byte, logs Keyring) error {
+	if e.KindVersion != (KindVersion{Kind: logEntryKind, Version: logEntryVersion}) || !bytes.Equal(e.CanonicalizedBody, body) {
+		return ErrInvalidBundle
+	}
+
+	p := e.InclusionProof
+	if p == nil || p.LogIndex != e.LogIndex {
+		return ErrInvalidProof
+	}
+
+	cp, logKey, err := VerifyCheckpoint(p.Checkpoint.Envelope, logs)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(logKey.Id(), e.LogId.KeyId) {
+		return ErrInvalidBundle
+	}
+	if cp.Size != p.TreeSize || !bytes.Equal(cp.RootHash, p.RootHash) {
+		return ErrInvalidProof
+	}
+
+	return VerifyInclusion(MerkleLeafHash(e.CanonicalizedBody), p.LogIndex, p.TreeSize, p.Hashes, p.RootHash)
+}
@@ -0,0 +1,161 @@
+package msign
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testTlogEntry records body as leaf 1 of a three leaf log signed by logKey
+func testTlogEntry(t *testing.T, logKey PrivateKey, body []byte) TlogEntry {
+	t.Helper()
+
+	leaves := [][]byte{[]byte("first"), body, []byte("third")}
+	root := testMerkleRoot(leaves)
+
+	cp, err := (&Checkpoint{Origin: "example.com/log", Size: 3, RootHash: root}).Sign(logKey)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	return TlogEntry{
+		LogIndex:          1,
+		LogId:             LogId{KeyId: logKey.Id()},
+		KindVersion:       KindVersion{Kind: logEntryKind, Version: logEntryVersion},
+		IntegratedTime:    1760000000,
+		CanonicalizedBody: body,
+		InclusionProof: &InclusionProof{
+			LogIndex:   1,
+			RootHash:   root,
+			TreeSize:   3,
+			Hashes:     testMerklePath(1, leaves),
+			Checkpoint: CheckpointEnvelope{Envelope: cp},
+		},
+	}
+}
+
+func TestSigstoreBundle(t *testing.T) {
+	logKey, logPub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	for _, version := range []byte{VersionOne, VersionThree, VersionFour} {
+		priv, pub, err := NewPrivateKeyWithVersion(version)
+		if err != nil {
+			t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+		}
+
+		b, err := NewSigstoreBundle(strings.NewReader("artifact"), priv)
+		if err != nil {
+			t.Fatalf("NewSigstoreBundle() failed: %v", err)
+		}
+
+		body, err := b.LogEntryBody()
+		if err != nil {
+			t.Fatalf("LogEntryBody() failed: %v", err)
+		}
+		b.VerificationMaterial.TlogEntries = append(b.VerificationMaterial.TlogEntries, testTlogEntry(t, logKey, body))
+
+		// round trip through JSON, integers are strings as in Sigstore
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("json.Marshal() failed: %v", err)
+		}
+		if !strings.Contains(string(data), `"logIndex":"1"`) || !strings.Contains(string(data), `"mediaType":"`+SigstoreBundleMediaType+`"`) {
+			t.Errorf("json.Marshal() failed by layout: %s", data)
+		}
+
+		var got SigstoreBundle
+		if err = json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("json.Unmarshal() failed: %v", err)
+		}
+
+		key, err := got.Verify(strings.NewReader("artifact"), Keyring{logPub, pub}, Keyring{logPub})
+		if err != nil || key != pub {
+			t.Errorf("Verify() version %d failed: %v", version, err)
+		}
+
+		key, err = got.Verify(strings.NewReader("artifact"), Keyring{pub}, nil)
+		if err != nil || key != pub {
+			t.Errorf("Verify() version %d without log keys failed: %v", version, err)
+		}
+
+		_, err = got.Verify(strings.NewReader("tampered"), Keyring{pub}, nil)
+		if err != ErrInvalidSignature {
+			t.Errorf("Verify() version %d of other artifact failed: %v", version, err)
+		}
+	}
+}
+
+func TestSigstoreBundle_Bad(t *testing.T) {
+	logKey, logPub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	otherLog, _, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	b, err := NewSigstoreBundle(strings.NewReader("artifact"), priv)
+	if err != nil {
+		t.Fatalf("NewSigstoreBundle() failed: %v", err)
+	}
+	body, err := b.LogEntryBody()
+	if err != nil {
+		t.Fatalf("LogEntryBody() failed: %v", err)
+	}
+
+	inputs := []struct {
+		name   string
+		modify func(b *SigstoreBundle)
+		want   error
+	}{
+		{"valid", func(b *SigstoreBundle) {}, nil},
+		{"no log entry", func(b *SigstoreBundle) { b.VerificationMaterial.TlogEntries = nil }, ErrInvalidBundle},
+		{"media type", func(b *SigstoreBundle) { b.MediaType = "application/json" }, ErrInvalidBundle},
+		{"hint", func(b *SigstoreBundle) { b.VerificationMaterial.PublicKey.Hint = "00" }, ErrInvalidBundle},
+		{"digest", func(b *SigstoreBundle) { b.MessageSignature.MessageDigest.Digest[0] ^= 1 }, ErrInvalidBundle},
+		{"digest algorithm", func(b *SigstoreBundle) { b.MessageSignature.MessageDigest.Algorithm = "SHA2_512" }, ErrInvalidBundle},
+		{"body", func(b *SigstoreBundle) { b.VerificationMaterial.TlogEntries[0].CanonicalizedBody = []byte("{}") }, ErrInvalidBundle},
+		{"kind", func(b *SigstoreBundle) { b.VerificationMaterial.TlogEntries[0].KindVersion.Kind = "hashedrekord" }, ErrInvalidBundle},
+		{"log id", func(b *SigstoreBundle) { b.VerificationMaterial.TlogEntries[0].LogId.KeyId = priv.Id() }, ErrInvalidBundle},
+		{"no proof", func(b *SigstoreBundle) { b.VerificationMaterial.TlogEntries[0].InclusionProof = nil }, ErrInvalidProof},
+		{"proof index", func(b *SigstoreBundle) { b.VerificationMaterial.TlogEntries[0].InclusionProof.LogIndex = 0 }, ErrInvalidProof},
+		{"proof size", func(b *SigstoreBundle) { b.VerificationMaterial.TlogEntries[0].InclusionProof.TreeSize = 4 }, ErrInvalidProof},
+		{"proof hashes", func(b *SigstoreBundle) { b.VerificationMaterial.TlogEntries[0].InclusionProof.Hashes[0][0] ^= 1 }, ErrInvalidProof},
+		{"untrusted log", func(b *SigstoreBundle) {
+			b.VerificationMaterial.TlogEntries[0] = testTlogEntry(t, otherLog, body)
+		}, ErrKeyNotFound},
+		{"second entry valid", func(b *SigstoreBundle) {
+			bad := testTlogEntry(t, otherLog, body)
+			b.VerificationMaterial.TlogEntries = append([]TlogEntry{bad}, b.VerificationMaterial.TlogEntries...)
+		}, nil},
+	}
+
+	for _, input := range inputs {
+		c := *b
+		c.MessageSignature.MessageDigest.Digest = append([]byte{}, b.MessageSignature.MessageDigest.Digest...)
+		c.VerificationMaterial.TlogEntries = []TlogEntry{testTlogEntry(t, logKey, body)}
+		input.modify(&c)
+
+		_, err := c.Verify(strings.NewReader("artifact"), Keyring{pub}, Keyring{logPub})
+		if err != input.want {
+			t.Errorf("Verify() %s failed: %v", input.name, err)
+		}
+	}
+
+	_, err = NewSigstoreBundle(nil, priv)
+	if err != ErrNilReader {
+		t.Errorf("NewSigstoreBundle() with nil reader failed: %v", err)
+	}
+	_, err = NewSigstoreBundle(strings.NewReader("x"), nil)
+	if err != ErrNilKey {
+		t.Errorf("NewSigstoreBundle() with nil key failed: %v", err)
+	}
+}
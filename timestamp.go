@@ -0,0 +1,275 @@
+package msign
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Timestamp tokens prove that a signature existed at a time: a timestamp
+// authority (TSA) signs the SHA-256 digest of the raw binary form of the
+// signature together with the time and a client nonce. The token layout is
+//
+//	version (1) | time, big endian unix nanoseconds (8) | nonce (8) | digest (32) | TSA signature
+//
+// and the TSA signature covers everything before it, prefixed by a fixed label.
+// Tokens export as TST: lines
+
+const (
+	timestampVersion  = 1
+	sizeTimestampBody = 1 + 8 + 8 + sha256.Size
+
+	TimestampQueryType = "application/vnd.msign.timestamp-query" // digest (32) | nonce (8)
+	TimestampReplyType = "application/vnd.msign.timestamp-reply" // token
+
+	labelTimestamp = "msign timestamp v1\x00"
+)
+
+var (
+	ErrInvalidTimestamp = errors.New("invalid timestamp token")
+	ErrKeyExpired       = errors.New("key expired")
+	ErrKeyRevoked       = errors.New("key revoked")
+)
+
+type TimestampToken struct {
+	Time      time.Time
+	Nonce     uint64
+	Digest    []byte    // SHA-256 of the raw binary form of the timestamped signature
+	Signature Signature // by the TSA key
+}
+
+// KeyValidity limits the time signatures of a key are accepted for
+type KeyValidity struct {
+	Key       PublicKey
+	NotAfter  time.Time // expiry, zero never expires
+	RevokedAt time.Time // revocation, zero if not revoked
+}
+
+// TimestampSignature returns a token of the TSA key for sig at the current time
+func TimestampSignature(sig Signature, tsa PrivateKey, nonce uint64) (*TimestampToken, error) {
+	if sig == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	return issueTimestamp(tsa, signatureDigest(sig), nonce, time.Now())
+}
+
+// ParseTimestamp decodes the raw binary form of a token, see Marshal
+func ParseTimestamp(data []byte) (*TimestampToken, error) {
+	if len(data) <= sizeTimestampBody || data[0] != timestampVersion {
+		return nil, ErrInvalidTimestamp
+	}
+
+	sig, err := decodeSignature(data[sizeTimestampBody:])
+	if err != nil {
+		return nil, ErrInvalidTimestamp
+	}
+
+	return &TimestampToken{
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(data[1:]))).UTC(),
+		Nonce:     binary.BigEndian.Uint64(data[9:]),
+		Digest:    bytes.Clone(data[17:sizeTimestampBody]),
+		Signature: sig,
+	}, nil
+}
+
+// ImportTimestamp reads a token exported as TST: line
+func ImportTimestamp(r io.Reader) (*TimestampToken, error) {
+	if r == nil {
+		return nil, ErrNilReader
+	}
+
+	raw, err := importLine(bufio.NewReader(r), PrefixTST, ErrInvalidTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTimestamp(raw)
+}
+
+// Verify checks that the token covers sig and is signed by a key of the TSA
+// keyring, which is returned
+func (t *TimestampToken) Verify(sig Signature, tsas Keyring) (PublicKey, error) {
+	if sig == nil || !bytes.Equal(t.Digest, signatureDigest(sig)) {
+		return nil, ErrInvalidTimestamp
+	}
+
+	return tsas.Verify(bytes.NewReader(t.message()), t.Signature)
+}
+
+// VerifyTimestamped verifies sig of message with the key of v. Once the key
+// expired or was revoked the signature is accepted only with a token of the
+// TSA keyring dating it before, otherwise token may be nil
+func VerifyTimestamped(message io.Reader, sig Signature, v KeyValidity, token *TimestampToken, tsas Keyring) error {
+	return verifyTimestamped(message, sig, v, token, tsas, time.Now())
+}
+
+// NewTimestampServer returns the handler of a TSA issuing tokens with key for
+// POST requests of type TimestampQueryType
+func NewTimestampServer(key PrivateKey) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		query, err := io.ReadAll(http.MaxBytesReader(w, r.Body, sha256.Size+8))
+		if err != nil || len(query) != sha256.Size+8 || r.Header.Get("Content-Type") != TimestampQueryType {
+			http.Error(w, "invalid timestamp query", http.StatusBadRequest)
+			return
+		}
+
+		token, err := issueTimestamp(key, query[:sha256.Size], binary.BigEndian.Uint64(query[sha256.Size:]), time.Now())
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", TimestampReplyType)
+		w.Write(token.marshal())
+	})
+
+	return mux
+}
+
+// TimestampClient requests tokens from a TSA served by NewTimestampServer
+type TimestampClient struct {
+	URL    string
+	Key    PublicKey    // TSA key tokens are verified with
+	Client *http.Client // nil uses http.DefaultClient
+}
+
+// NewTimestampClient returns a client of the TSA at url whose tokens are signed by key
+func NewTimestampClient(url string, key PublicKey) *TimestampClient {
+	return &TimestampClient{URL: url, Key: key}
+}
+
+// Timestamp returns a verified token for sig
+func (c *TimestampClient) Timestamp(ctx context.Context, sig Signature) (*TimestampToken, error) {
+	if c.Key == nil {
+		return nil, ErrNilKey
+	}
+	if sig == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	var n [8]byte
+	_, err := rand.Read(n[:])
+	if err != nil {
+		return nil, err
+	}
+	nonce := binary.BigEndian.Uint64(n[:])
+
+	query := append(signatureDigest(sig), n[:]...)
+	status, data, err := httpRequest(ctx, c.Client, http.MethodPost, c.URL, TimestampQueryType, query)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, ErrInvalidTimestamp
+	}
+
+	token, err := ParseTimestamp(data)
+	if err != nil {
+		return nil, err
+	}
+	if token.Nonce != nonce {
+		return nil, ErrInvalidTimestamp
+	}
+
+	_, err = token.Verify(sig, Keyring{c.Key})
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// utility functions
+
+func issueTimestamp(tsa PrivateKey, digest []byte, nonce uint64, now time.Time) (*TimestampToken, error) {
+	if tsa == nil {
+		return nil, ErrNilKey
+	}
+	if len(digest) != sha256.Size {
+		return nil, ErrInvalidTimestamp
+	}
+
+	t := &TimestampToken{Time: now.UTC(), Nonce: nonce, Digest: bytes.Clone(digest)}
+	sig, err := tsa.Sign(bytes.NewReader(t.message()))
+	if err != nil {
+		return nil, err
+	}
+	t.Signature = sig
+
+	return t, nil
+}
+
+func verifyTimestamped(message io.Reader, sig Signature, v KeyValidity, token *TimestampToken, tsas Keyring, now time.Time) error {
+	if v.Key == nil {
+		return ErrNilKey
+	}
+
+	// the earliest of expiry and revocation ends the validity
+	deadline, errDeadline := v.NotAfter, ErrKeyExpired
+	if !v.RevokedAt.IsZero() && (deadline.IsZero() || v.RevokedAt.Before(deadline)) {
+		deadline, errDeadline = v.RevokedAt, ErrKeyRevoked
+	}
+
+	if !deadline.IsZero() && !now.Before(deadline) {
+		if token == nil {
+			return errDeadline
+		}
+
+		_, err := token.Verify(sig, tsas)
+		if err != nil {
+			return err
+		}
+		if !token.Time.Before(deadline) {
+			return errDeadline
+		}
+	}
+
+	ok, err := v.Key.Verify(message, sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// signatureDigest is the digest a timestamp token covers
+func signatureDigest(sig Signature) []byte {
+	d := sha256.Sum256(sig.marshal())
+	return d[:]
+}
+
+// message is the message the TSA signs, labeled since queries choose the digest
+func (t *TimestampToken) message() []byte {
+	return append([]byte(labelTimestamp), t.body()...)
+}
+
+// body returns the raw binary form before the TSA signature
+func (t *TimestampToken) body() []byte {
+	b := make([]byte, sizeTimestampBody)
+	b[0] = timestampVersion
+	binary.BigEndian.PutUint64(b[1:], uint64(t.Time.UnixNano()))
+	binary.BigEndian.PutUint64(b[9:], t.Nonce)
+	copy(b[17:], t.Digest)
+	return b
+}
+
+func (t *TimestampToken) marshal() []byte {
+	return append(t.body(), t.Signature.marshal()...)
+}
+
+func (t *TimestampToken) export(w io.Writer) error {
+	return exportBytes(w, PrefixTST, t.marshal())
+}
@@ -0,0 +1,223 @@
+package msign
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+	tsa, tsaPub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	priv, _, err := NewPrivateKeyWithVersion(VersionThree)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	sig, err := priv.Sign(strings.NewReader("release"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	other, err := priv.Sign(strings.NewReader("other"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	token, err := TimestampSignature(sig, tsa, 42)
+	if err != nil {
+		t.Fatalf("TimestampSignature() failed: %v", err)
+	}
+	if time.Since(token.Time) > time.Minute || token.Nonce != 42 {
+		t.Errorf("TimestampSignature() failed by content: %v %d", token.Time, token.Nonce)
+	}
+
+	// round trip through a TST: line
+	var b bytes.Buffer
+	if err = Export(&b, token); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if !strings.HasPrefix(b.String(), PrefixTST) {
+		t.Errorf("Export() failed by prefix: %s", b.String())
+	}
+	got, err := ImportTimestamp(&b)
+	if err != nil || !got.Time.Equal(token.Time) || got.Nonce != 42 || !bytes.Equal(got.Digest, token.Digest) {
+		t.Fatalf("ImportTimestamp() failed: %v", err)
+	}
+
+	key, err := got.Verify(sig, Keyring{tsaPub})
+	if err != nil || key != tsaPub {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	_, err = got.Verify(other, Keyring{tsaPub})
+	if err != ErrInvalidTimestamp {
+		t.Errorf("Verify() of other signature failed: %v", err)
+	}
+
+	// a plain signature of the token body by the TSA key is no token
+	plain := *got
+	plain.Signature, _ = tsa.Sign(bytes.NewReader(plain.body()))
+	if _, err = plain.Verify(sig, Keyring{tsaPub}); err != ErrInvalidSignature {
+		t.Errorf("Verify() of plain signature failed: %v", err)
+	}
+
+	got.Time = got.Time.Add(-time.Hour)
+	_, err = got.Verify(sig, Keyring{tsaPub})
+	if err != ErrInvalidSignature {
+		t.Errorf("Verify() of backdated token failed: %v", err)
+	}
+
+	data, _ := Marshal(token)
+	inputs := [][]byte{nil, data[:sizeTimestampBody], append([]byte{2}, data[1:]...), append(data[:sizeTimestampBody:sizeTimestampBody], 0xff)}
+	for _, input := range inputs {
+		if _, err := ParseTimestamp(input); err != ErrInvalidTimestamp {
+			t.Errorf("ParseTimestamp(%x) failed: %v", input, err)
+		}
+	}
+
+	// tolerated as by the other importers
+	b.Reset()
+	Export(&b, token)
+	tolerant := "# token of the release\r\n\r\n" + strings.TrimSpace(b.String()) + "\r\nSIG:AAAA\r\n"
+	if got, err = ImportTimestamp(strings.NewReader(tolerant)); err != nil || got.Nonce != 42 {
+		t.Errorf("ImportTimestamp() of commented CRLF line failed: %v", err)
+	}
+
+	_, err = ImportTimestamp(strings.NewReader("SIG:AAAA"))
+	if err != ErrInvalidTimestamp {
+		t.Errorf("ImportTimestamp() of signature failed: %v", err)
+	}
+}
+
+func TestVerifyTimestamped(t *testing.T) {
+	tsa, tsaPub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	otherTSA, _, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	sig, err := priv.Sign(strings.NewReader("release"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	expiry := now.Add(-30 * 24 * time.Hour)
+	revocation := now.Add(-60 * 24 * time.Hour)
+
+	stamp := func(key PrivateKey, at time.Time) *TimestampToken {
+		token, err := issueTimestamp(key, signatureDigest(sig), 0, at)
+		if err != nil {
+			t.Fatalf("issueTimestamp() failed: %v", err)
+		}
+		return token
+	}
+	before := stamp(tsa, revocation.Add(-time.Hour))
+	between := stamp(tsa, expiry.Add(-time.Hour))
+	after := stamp(tsa, expiry.Add(time.Hour))
+
+	inputs := []struct {
+		name  string
+		v     KeyValidity
+		token *TimestampToken
+		want  error
+	}{
+		{"valid key", KeyValidity{Key: pub}, nil, nil},
+		{"valid until later", KeyValidity{Key: pub, NotAfter: now.Add(time.Hour)}, nil, nil},
+		{"expired", KeyValidity{Key: pub, NotAfter: expiry}, nil, ErrKeyExpired},
+		{"expired, stamped before", KeyValidity{Key: pub, NotAfter: expiry}, between, nil},
+		{"expired, stamped after", KeyValidity{Key: pub, NotAfter: expiry}, after, ErrKeyExpired},
+		{"expired, untrusted TSA", KeyValidity{Key: pub, NotAfter: expiry}, stamp(otherTSA, revocation), ErrKeyNotFound},
+		{"revoked", KeyValidity{Key: pub, RevokedAt: revocation}, nil, ErrKeyRevoked},
+		{"revoked, stamped before", KeyValidity{Key: pub, RevokedAt: revocation}, before, nil},
+		{"revoked, stamped after", KeyValidity{Key: pub, RevokedAt: revocation}, between, ErrKeyRevoked},
+		{"revoked before expiry", KeyValidity{Key: pub, NotAfter: expiry, RevokedAt: revocation}, between, ErrKeyRevoked},
+		{"revoked after expiry", KeyValidity{Key: pub, NotAfter: revocation, RevokedAt: expiry}, between, ErrKeyExpired},
+		{"no key", KeyValidity{}, nil, ErrNilKey},
+	}
+
+	for _, input := range inputs {
+		err := verifyTimestamped(strings.NewReader("release"), sig, input.v, input.token, Keyring{tsaPub}, now)
+		if err != input.want {
+			t.Errorf("verifyTimestamped() %s failed: %v", input.name, err)
+		}
+	}
+
+	err = verifyTimestamped(strings.NewReader("tampered"), sig, KeyValidity{Key: pub, NotAfter: expiry}, between, Keyring{tsaPub}, now)
+	if err != ErrInvalidSignature {
+		t.Errorf("verifyTimestamped() of other message failed: %v", err)
+	}
+
+	err = VerifyTimestamped(strings.NewReader("release"), sig, KeyValidity{Key: pub, NotAfter: expiry}, between, Keyring{tsaPub})
+	if err != nil {
+		t.Errorf("VerifyTimestamped() failed: %v", err)
+	}
+}
+
+func TestTimestampClient(t *testing.T) {
+	ctx := context.Background()
+	tsa, tsaPub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	priv, _, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	sig, err := priv.Sign(strings.NewReader("release"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	srv := httptest.NewServer(NewTimestampServer(tsa))
+	defer srv.Close()
+
+	token, err := NewTimestampClient(srv.URL, tsaPub).Timestamp(ctx, sig)
+	if err != nil {
+		t.Fatalf("Timestamp() failed: %v", err)
+	}
+	if _, err = token.Verify(sig, Keyring{tsaPub}); err != nil {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	_, err = NewTimestampClient(srv.URL, other).Timestamp(ctx, sig)
+	if err != ErrKeyNotFound {
+		t.Errorf("Timestamp() with other key failed: %v", err)
+	}
+
+	// a replayed reply carries a stale nonce
+	replay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(token.marshal())
+	}))
+	defer replay.Close()
+	_, err = NewTimestampClient(replay.URL, tsaPub).Timestamp(ctx, sig)
+	if err != ErrInvalidTimestamp {
+		t.Errorf("Timestamp() of replayed reply failed: %v", err)
+	}
+
+	resp, err := http.Post(srv.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("http.Post() failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST of invalid query failed: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,419 @@
+package msign
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+	"os"
+	"sync"
+	"time"
+)
+
+// Log is an append-only transparency log of RFC 6962 Merkle tree leaves,
+// typically signatures or bundle log entry bodies. Its state is published as
+// checkpoints signed with the log key, see Checkpoint
+
+// LogStorage persists the entries of a log
+type LogStorage interface {
+	Load() ([][]byte, error) // every entry in order
+	Append(entry []byte) error
+}
+
+var ErrLogIndex = errors.New("log index out of range")
+
+type Log struct {
+	mu      sync.RWMutex
+	origin  string
+	key     PrivateKey
+	storage LogStorage // nil keeps the log in memory only
+	entries [][]byte
+	tree    merkleTree
+}
+
+// merkleTree holds the leaf hashes of a log with the hash of every complete
+// subtree, so roots and proofs hash O(log n) nodes instead of the whole tree
+type merkleTree struct {
+	levels [][][]byte // levels[h][i] hashes the 2^h leaves from i<<h
+}
+
+// NewLog opens the log named origin, restoring the entries of storage which may be nil
+func NewLog(origin string, key PrivateKey, storage LogStorage) (*Log, error) {
+	if key == nil {
+		return nil, ErrNilKey
+	}
+
+	l := &Log{origin: origin, key: key, storage: storage}
+	if storage == nil {
+		return l, nil
+	}
+
+	entries, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		l.entries = append(l.entries, entry)
+		l.tree.append(MerkleLeafHash(entry))
+	}
+
+	return l, nil
+}
+
+// Public returns the key checkpoints of the log are verified with
+func (l *Log) Public() PublicKey {
+	return l.key.Public()
+}
+
+// Append stores entry and returns its index
+func (l *Log) Append(entry []byte) (uint64, error) {
+	if len(entry) > MaxFrameSize {
+		return 0, ErrFrameTooLarge
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.storage != nil {
+		err := l.storage.Append(entry)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	entry = append([]byte{}, entry...)
+	l.entries = append(l.entries, entry)
+	l.tree.append(MerkleLeafHash(entry))
+
+	return uint64(len(l.entries) - 1), nil
+}
+
+// AppendSignature logs the raw binary form of sig
+func (l *Log) AppendSignature(sig Signature) (uint64, error) {
+	if sig == nil {
+		return 0, ErrInvalidSignature
+	}
+
+	return l.Append(sig.marshal())
+}
+
+// AddBundle logs the signature of b and attaches the log entry with its
+// inclusion proof under a fresh checkpoint
+func (l *Log) AddBundle(b *SigstoreBundle) error {
+	body, err := b.LogEntryBody()
+	if err != nil {
+		return err
+	}
+
+	index, cp, checkpoint, proof, err := l.appendProved(body)
+	if err != nil {
+		return err
+	}
+
+	b.VerificationMaterial.TlogEntries = append(b.VerificationMaterial.TlogEntries, TlogEntry{
+		LogIndex:          index,
+		LogId:             LogId{KeyId: l.key.Id()},
+		KindVersion:       KindVersion{Kind: logEntryKind, Version: logEntryVersion},
+		IntegratedTime:    time.Now().Unix(),
+		CanonicalizedBody: body,
+		InclusionProof: &InclusionProof{
+			LogIndex:   index,
+			RootHash:   cp.RootHash,
+			TreeSize:   cp.Size,
+			Hashes:     proof,
+			Checkpoint: CheckpointEnvelope{Envelope: checkpoint},
+		},
+	})
+
+	return nil
+}
+
+// Size returns the number of entries
+func (l *Log) Size() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return uint64(len(l.entries))
+}
+
+// Entry returns the entry at index
+func (l *Log) Entry(index uint64) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if index >= uint64(len(l.entries)) {
+		return nil, ErrLogIndex
+	}
+
+	return append([]byte{}, l.entries[index]...), nil
+}
+
+// RootHash returns the root hash of the tree of the first size entries
+func (l *Log) RootHash(size uint64) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if size > uint64(l.tree.size()) {
+		return nil, ErrLogIndex
+	}
+
+	return l.tree.root(0, int(size)), nil
+}
+
+// Checkpoint returns the signed tree head of the current state of the log
+func (l *Log) Checkpoint() (string, error) {
+	_, text, err := l.checkpoint()
+	return text, err
+}
+
+// InclusionProof returns the audit path of the entry at index in the tree of
+// the first size entries, see VerifyInclusion
+func (l *Log) InclusionProof(index, size uint64) ([][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if size > uint64(l.tree.size()) || index >= size {
+		return nil, ErrLogIndex
+	}
+
+	return l.tree.path(int(index), 0, int(size)), nil
+}
+
+// ConsistencyProof proves that the tree of the first first entries is a prefix
+// of the tree of the first second entries, see VerifyConsistency
+func (l *Log) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if second > uint64(l.tree.size()) || first > second {
+		return nil, ErrLogIndex
+	}
+	if first == 0 {
+		return nil, nil
+	}
+
+	return l.tree.subproof(int(first), 0, int(second), true), nil
+}
+
+// FileLogStorage keeps log entries in an append-only file of frames tagged 'L',
+// see WriteFrame. A record torn by a crash during Append is dropped on Load
+type FileLogStorage struct {
+	f *os.File
+}
+
+const logRecordTag = 'L'
+
+// OpenFileLogStorage opens or creates the log file at path
+func OpenFileLogStorage(path string) (*FileLogStorage, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileLogStorage{f: f}, nil
+}
+
+func (s *FileLogStorage) Load() ([][]byte, error) {
+	_, err := s.f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries [][]byte
+		offset  int64
+	)
+
+	br := bufio.NewReader(s.f)
+	for {
+		var header [sizeFrameHeader]byte
+		_, err = io.ReadFull(br, header[:])
+		if err == io.EOF {
+			break
+		}
+
+		var entry []byte
+		if err == nil {
+			if header[0] != logRecordTag || binary.BigEndian.Uint32(header[1:]) > MaxFrameSize {
+				return nil, ErrInvalidFrame
+			}
+			entry = make([]byte, binary.BigEndian.Uint32(header[1:]))
+			_, err = io.ReadFull(br, entry)
+		}
+
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			// torn tail, never acknowledged by Append
+			err = s.f.Truncate(offset)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+		offset += int64(sizeFrameHeader + len(entry))
+	}
+
+	_, err = s.f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Append writes entry and syncs the file before returning, on failure the
+// file is truncated back to its previous size
+func (s *FileLogStorage) Append(entry []byte) error {
+	if len(entry) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+
+	record := make([]byte, sizeFrameHeader+len(entry))
+	record[0] = logRecordTag
+	binary.BigEndian.PutUint32(record[1:], uint32(len(entry)))
+	copy(record[sizeFrameHeader:], entry)
+
+	offset, err := s.f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.f.Write(record)
+	if err == nil {
+		err = s.f.Sync()
+	}
+	if err != nil {
+		// drop a partial or unsynced record so later ones stay aligned
+		s.f.Truncate(offset)
+		s.f.Seek(offset, io.SeekStart)
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileLogStorage) Close() error {
+	return s.f.Close()
+}
+
+// utility functions
+
+func (l *Log) checkpoint() (*Checkpoint, string, error) {
+	l.mu.RLock()
+	size := l.tree.size()
+	cp := &Checkpoint{Origin: l.origin, Size: uint64(size), RootHash: l.tree.root(0, size)}
+	l.mu.RUnlock()
+
+	text, err := cp.Sign(l.key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return cp, text, nil
+}
+
+// appendProved appends entry and returns its index with a fresh checkpoint,
+// as struct and text, and the inclusion proof of the entry under it
+func (l *Log) appendProved(entry []byte) (uint64, *Checkpoint, string, [][]byte, error) {
+	index, err := l.Append(entry)
+	if err != nil {
+		return 0, nil, "", nil, err
+	}
+
+	cp, text, err := l.checkpoint()
+	if err != nil {
+		return 0, nil, "", nil, err
+	}
+
+	proof, err := l.InclusionProof(index, cp.Size)
+	if err != nil {
+		return 0, nil, "", nil, err
+	}
+
+	return index, cp, text, proof, nil
+}
+
+// append adds a leaf hash and the complete subtrees it closes
+func (t *merkleTree) append(leafHash []byte) {
+	if len(t.levels) == 0 {
+		t.levels = make([][][]byte, 1)
+	}
+
+	t.levels[0] = append(t.levels[0], leafHash)
+	for h := 0; len(t.levels[h])%2 == 0; h++ {
+		n := len(t.levels[h])
+		if h+1 == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+		t.levels[h+1] = append(t.levels[h+1], merkleNodeHash(t.levels[h][n-2], t.levels[h][n-1]))
+	}
+}
+
+func (t *merkleTree) size() int {
+	if len(t.levels) == 0 {
+		return 0
+	}
+	return len(t.levels[0])
+}
+
+// root is MTH of RFC 6962 over the leaves from start to end, every left
+// subtree of the recursion is complete and read from the cache
+func (t *merkleTree) root(start, end int) []byte {
+	n := end - start
+	if n == 0 {
+		h := sha256.Sum256(nil)
+		return h[:]
+	}
+	if n&(n-1) == 0 && start%n == 0 {
+		h := bits.TrailingZeros(uint(n))
+		return append([]byte{}, t.levels[h][start>>h]...)
+	}
+
+	k := merkleSplit(n)
+	return merkleNodeHash(t.root(start, start+k), t.root(start+k, end))
+}
+
+// path is PATH of RFC 6962 for leaf m of the leaves from start to end
+func (t *merkleTree) path(m, start, end int) [][]byte {
+	if end-start <= 1 {
+		return nil
+	}
+
+	k := merkleSplit(end - start)
+	if m < k {
+		return append(t.path(m, start, start+k), t.root(start+k, end))
+	}
+	return append(t.path(m-k, start+k, end), t.root(start, start+k))
+}
+
+// subproof is SUBPROOF of RFC 6962 for the first m of the leaves from start to end
+func (t *merkleTree) subproof(m, start, end int, complete bool) [][]byte {
+	n := end - start
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{t.root(start, end)}
+	}
+
+	k := merkleSplit(n)
+	if m <= k {
+		return append(t.subproof(m, start, start+k, complete), t.root(start+k, end))
+	}
+	return append(t.subproof(m-k, start+k, end, false), t.root(start, start+k))
+}
+
+// merkleSplit returns the largest power of two smaller than n
+func merkleSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
@@ -0,0 +1,211 @@
+package msign
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	key, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	l, err := NewLog("example.com/log", key, nil)
+	if err != nil {
+		t.Fatalf("NewLog() failed: %v", err)
+	}
+
+	leaves := testLeaves(t, len(testMerkleLeaves))
+	for i, leaf := range leaves {
+		index, err := l.Append(leaf)
+		if err != nil || index != uint64(i) {
+			t.Fatalf("Append() failed: %d %v", index, err)
+		}
+
+		root, err := l.RootHash(l.Size())
+		if err != nil || hex.EncodeToString(root) != testMerkleRoots[i] {
+			t.Errorf("RootHash() of %d entries failed: %v", i+1, err)
+		}
+	}
+
+	checkpoint, err := l.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint() failed: %v", err)
+	}
+	cp, _, err := VerifyCheckpoint(checkpoint, Keyring{pub})
+	if err != nil || cp.Size != 8 || hex.EncodeToString(cp.RootHash) != testMerkleRoots[7] {
+		t.Fatalf("VerifyCheckpoint() failed: %v", err)
+	}
+
+	for size := uint64(1); size <= cp.Size; size++ {
+		root, _ := l.RootHash(size)
+
+		for index := range size {
+			proof, err := l.InclusionProof(index, size)
+			if err != nil {
+				t.Fatalf("InclusionProof() failed: %v", err)
+			}
+			entry, _ := l.Entry(index)
+			if err = VerifyInclusion(MerkleLeafHash(entry), index, size, proof, root); err != nil {
+				t.Errorf("VerifyInclusion() of %d in %d failed: %v", index, size, err)
+			}
+		}
+
+		proof, err := l.ConsistencyProof(size, cp.Size)
+		if err != nil {
+			t.Fatalf("ConsistencyProof() failed: %v", err)
+		}
+		if err = VerifyConsistency(size, cp.Size, proof, root, cp.RootHash); err != nil {
+			t.Errorf("VerifyConsistency() of %d and %d failed: %v", size, cp.Size, err)
+		}
+	}
+
+	// proofs do not share memory with the log
+	proof, _ := l.InclusionProof(1, 2)
+	proof[0][0] ^= 1
+	root, _ := l.RootHash(8)
+	if hex.EncodeToString(root) != testMerkleRoots[7] {
+		t.Errorf("InclusionProof() failed by aliasing the log")
+	}
+
+	inputs := []struct {
+		name string
+		err  error
+	}{
+		{"Entry", func() error { _, err := l.Entry(8); return err }()},
+		{"RootHash", func() error { _, err := l.RootHash(9); return err }()},
+		{"InclusionProof", func() error { _, err := l.InclusionProof(8, 8); return err }()},
+		{"InclusionProof beyond size", func() error { _, err := l.InclusionProof(0, 9); return err }()},
+		{"ConsistencyProof", func() error { _, err := l.ConsistencyProof(5, 4); return err }()},
+		{"ConsistencyProof beyond size", func() error { _, err := l.ConsistencyProof(1, 9); return err }()},
+	}
+
+	for _, input := range inputs {
+		if input.err != ErrLogIndex {
+			t.Errorf("%s() out of range failed: %v", input.name, input.err)
+		}
+	}
+}
+
+func TestLog_Bundle(t *testing.T) {
+	logKey, logPub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	l, err := NewLog("example.com/log", logKey, nil)
+	if err != nil {
+		t.Fatalf("NewLog() failed: %v", err)
+	}
+
+	sig, err := priv.Sign(strings.NewReader("earlier release"))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	index, err := l.AppendSignature(sig)
+	if err != nil || index != 0 {
+		t.Fatalf("AppendSignature() failed: %v", err)
+	}
+	entry, _ := l.Entry(0)
+	if got, err := ParseSignature(entry); err != nil || got.KeyId().String() != sig.KeyId().String() {
+		t.Errorf("Entry() of signature failed: %v", err)
+	}
+
+	b, err := NewSigstoreBundle(strings.NewReader("artifact"), priv)
+	if err != nil {
+		t.Fatalf("NewSigstoreBundle() failed: %v", err)
+	}
+	if err = l.AddBundle(b); err != nil {
+		t.Fatalf("AddBundle() failed: %v", err)
+	}
+
+	e := b.VerificationMaterial.TlogEntries[0]
+	if e.LogIndex != 1 || e.InclusionProof.TreeSize != 2 {
+		t.Errorf("AddBundle() failed by entry: %+v", e)
+	}
+
+	key, err := b.Verify(strings.NewReader("artifact"), Keyring{pub}, Keyring{logPub})
+	if err != nil || key != pub {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	_, err = l.AppendSignature(nil)
+	if err != ErrInvalidSignature {
+		t.Errorf("AppendSignature() with nil signature failed: %v", err)
+	}
+	_, err = NewLog("example.com/log", nil, nil)
+	if err != ErrNilKey {
+		t.Errorf("NewLog() with nil key failed: %v", err)
+	}
+}
+
+func TestFileLogStorage(t *testing.T) {
+	key, _, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "log")
+
+	storage, err := OpenFileLogStorage(path)
+	if err != nil {
+		t.Fatalf("OpenFileLogStorage() failed: %v", err)
+	}
+	l, err := NewLog("example.com/log", key, storage)
+	if err != nil {
+		t.Fatalf("NewLog() failed: %v", err)
+	}
+	for _, leaf := range testLeaves(t, 5) {
+		if _, err = l.Append(leaf); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+	storage.Close()
+
+	// a crash in the middle of the next append leaves a torn record
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("os.OpenFile() failed: %v", err)
+	}
+	f.Write([]byte{logRecordTag, 0, 0, 0, 8, 0x50})
+	f.Close()
+
+	storage, err = OpenFileLogStorage(path)
+	if err != nil {
+		t.Fatalf("OpenFileLogStorage() failed: %v", err)
+	}
+	defer storage.Close()
+
+	l, err = NewLog("example.com/log", key, storage)
+	if err != nil || l.Size() != 5 {
+		t.Fatalf("NewLog() of reopened storage failed: %v", err)
+	}
+
+	for _, leaf := range testLeaves(t, 8)[5:] {
+		if _, err = l.Append(leaf); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+
+	entries, err := storage.Load()
+	if err != nil || len(entries) != 8 {
+		t.Fatalf("Load() failed: %d %v", len(entries), err)
+	}
+	root, _ := l.RootHash(8)
+	if hex.EncodeToString(root) != testMerkleRoots[7] {
+		t.Errorf("RootHash() after reopening failed")
+	}
+
+	// anything but a torn tail is refused
+	os.WriteFile(path, []byte{'X', 0, 0, 0, 1, 0}, 0o644)
+	if _, err = storage.Load(); err != ErrInvalidFrame {
+		t.Errorf("Load() of corrupted file failed: %v", err)
+	}
+}
@@ -0,0 +1,323 @@
+package msign
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HTTP access to a Log. The server exposes
+//
+//	POST /add-entry                               {"entry":"<base64>"}
+//	GET  /get-sth                                 the current checkpoint as text
+//	GET  /get-inclusion-proof?index=<n>&size=<m>  {"hashes":["<base64>",...]}
+//	GET  /get-consistency-proof?first=<n>&second=<m>
+//
+// add-entry answers with the index of the entry, a checkpoint including it and
+// the inclusion proof under that checkpoint. The client verifies every
+// checkpoint and proof with the log key before returning it
+
+const maxHTTPResponse = 1 << 20
+
+var ErrLogResponse = errors.New("invalid log response")
+
+type logAddRequest struct {
+	Entry []byte `json:"entry"`
+}
+
+type logAddResponse struct {
+	Index      uint64   `json:"index"`
+	Checkpoint string   `json:"checkpoint"`
+	Hashes     [][]byte `json:"hashes"`
+}
+
+type logProofResponse struct {
+	Hashes [][]byte `json:"hashes"`
+}
+
+// NewLogServer returns the handler serving l, mount it with http.StripPrefix
+// to serve below a path
+func NewLogServer(l *Log) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /add-entry", func(w http.ResponseWriter, r *http.Request) {
+		var req logAddRequest
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*MaxFrameSize)).Decode(&req)
+		if err != nil || len(req.Entry) == 0 {
+			http.Error(w, "invalid entry", http.StatusBadRequest)
+			return
+		}
+
+		index, _, checkpoint, proof, err := l.appendProved(req.Entry)
+		if err != nil {
+			writeLogError(w, err)
+			return
+		}
+
+		writeLogJSON(w, logAddResponse{Index: index, Checkpoint: checkpoint, Hashes: proof})
+	})
+
+	mux.HandleFunc("GET /get-sth", func(w http.ResponseWriter, r *http.Request) {
+		checkpoint, err := l.Checkpoint()
+		if err != nil {
+			writeLogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, checkpoint)
+	})
+
+	mux.HandleFunc("GET /get-inclusion-proof", func(w http.ResponseWriter, r *http.Request) {
+		index, err1 := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+		size, err2 := strconv.ParseUint(r.URL.Query().Get("size"), 10, 64)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "invalid index or size", http.StatusBadRequest)
+			return
+		}
+
+		proof, err := l.InclusionProof(index, size)
+		if err != nil {
+			writeLogError(w, err)
+			return
+		}
+
+		writeLogJSON(w, logProofResponse{Hashes: proof})
+	})
+
+	mux.HandleFunc("GET /get-consistency-proof", func(w http.ResponseWriter, r *http.Request) {
+		first, err1 := strconv.ParseUint(r.URL.Query().Get("first"), 10, 64)
+		second, err2 := strconv.ParseUint(r.URL.Query().Get("second"), 10, 64)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "invalid tree sizes", http.StatusBadRequest)
+			return
+		}
+
+		proof, err := l.ConsistencyProof(first, second)
+		if err != nil {
+			writeLogError(w, err)
+			return
+		}
+
+		writeLogJSON(w, logProofResponse{Hashes: proof})
+	})
+
+	return mux
+}
+
+// LogClient talks to a log served by NewLogServer
+type LogClient struct {
+	BaseURL string       // URL the endpoints are relative to
+	Key     PublicKey    // log key checkpoints are verified with
+	Client  *http.Client // nil uses http.DefaultClient
+}
+
+// NewLogClient returns a client of the log at baseURL whose checkpoints are signed by key
+func NewLogClient(baseURL string, key PublicKey) *LogClient {
+	return &LogClient{BaseURL: strings.TrimSuffix(baseURL, "/"), Key: key}
+}
+
+// Add appends entry and returns its index and the checkpoint proven to include it
+func (c *LogClient) Add(ctx context.Context, entry []byte) (uint64, *Checkpoint, error) {
+	index, cp, _, _, err := c.add(ctx, entry)
+	return index, cp, err
+}
+
+// AddBundle logs the signature of b and attaches the log entry, see Log.AddBundle
+func (c *LogClient) AddBundle(ctx context.Context, b *SigstoreBundle) error {
+	body, err := b.LogEntryBody()
+	if err != nil {
+		return err
+	}
+
+	index, cp, checkpoint, proof, err := c.add(ctx, body)
+	if err != nil {
+		return err
+	}
+
+	b.VerificationMaterial.TlogEntries = append(b.VerificationMaterial.TlogEntries, TlogEntry{
+		LogIndex:          index,
+		LogId:             LogId{KeyId: c.Key.Id()},
+		KindVersion:       KindVersion{Kind: logEntryKind, Version: logEntryVersion},
+		CanonicalizedBody: body,
+		InclusionProof: &InclusionProof{
+			LogIndex:   index,
+			RootHash:   cp.RootHash,
+			TreeSize:   cp.Size,
+			Hashes:     proof,
+			Checkpoint: CheckpointEnvelope{Envelope: checkpoint},
+		},
+	})
+
+	return nil
+}
+
+// Checkpoint returns the verified current checkpoint of the log
+func (c *LogClient) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	data, err := c.do(ctx, http.MethodGet, "/get-sth", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cp, _, err := VerifyCheckpoint(string(data), Keyring{c.Key})
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// VerifyInclusion checks that entry is logged at index under the checkpoint
+func (c *LogClient) VerifyInclusion(ctx context.Context, entry []byte, index uint64, cp *Checkpoint) error {
+	query := url.Values{
+		"index": {strconv.FormatUint(index, 10)},
+		"size":  {strconv.FormatUint(cp.Size, 10)},
+	}
+
+	proof, err := c.proof(ctx, "/get-inclusion-proof?"+query.Encode())
+	if err != nil {
+		return err
+	}
+
+	return VerifyInclusion(MerkleLeafHash(entry), index, cp.Size, proof, cp.RootHash)
+}
+
+// VerifyConsistency checks that the log at the newer checkpoint extends the
+// log at the older one, which detects a rewritten history
+func (c *LogClient) VerifyConsistency(ctx context.Context, older, newer *Checkpoint) error {
+	query := url.Values{
+		"first":  {strconv.FormatUint(older.Size, 10)},
+		"second": {strconv.FormatUint(newer.Size, 10)},
+	}
+
+	proof, err := c.proof(ctx, "/get-consistency-proof?"+query.Encode())
+	if err != nil {
+		return err
+	}
+
+	return VerifyConsistency(older.Size, newer.Size, proof, older.RootHash, newer.RootHash)
+}
+
+// utility functions
+
+func (c *LogClient) add(ctx context.Context, entry []byte) (uint64, *Checkpoint, string, [][]byte, error) {
+	req, err := json.Marshal(logAddRequest{Entry: entry})
+	if err != nil {
+		return 0, nil, "", nil, err
+	}
+
+	data, err := c.do(ctx, http.MethodPost, "/add-entry", req)
+	if err != nil {
+		return 0, nil, "", nil, err
+	}
+
+	var resp logAddResponse
+	if json.Unmarshal(data, &resp) != nil {
+		return 0, nil, "", nil, ErrLogResponse
+	}
+
+	cp, _, err := VerifyCheckpoint(resp.Checkpoint, Keyring{c.Key})
+	if err != nil {
+		return 0, nil, "", nil, err
+	}
+
+	err = VerifyInclusion(MerkleLeafHash(entry), resp.Index, cp.Size, resp.Hashes, cp.RootHash)
+	if err != nil {
+		return 0, nil, "", nil, err
+	}
+
+	return resp.Index, cp, resp.Checkpoint, resp.Hashes, nil
+}
+
+func (c *LogClient) proof(ctx context.Context, path string) ([][]byte, error) {
+	data, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp logProofResponse
+	if json.Unmarshal(data, &resp) != nil {
+		return nil, ErrLogResponse
+	}
+
+	return resp.Hashes, nil
+}
+
+// do sends a request and returns the body of a 200 response
+func (c *LogClient) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	if c.Key == nil {
+		return nil, ErrNilKey
+	}
+
+	contentType := ""
+	if body != nil {
+		contentType = "application/json"
+	}
+
+	status, data, err := httpRequest(ctx, c.Client, method, c.BaseURL+path, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case http.StatusOK:
+		return data, nil
+	case http.StatusRequestEntityTooLarge:
+		return nil, ErrFrameTooLarge
+	case http.StatusNotFound:
+		return nil, ErrLogIndex
+	}
+
+	return nil, ErrLogResponse
+}
+
+// httpRequest sends a request with client, nil uses http.DefaultClient, and
+// returns the status and at most maxHTTPResponse bytes of the body
+func httpRequest(ctx context.Context, client *http.Client, method, url, contentType string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponse))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, data, nil
+}
+
+func writeLogJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeLogError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrLogIndex:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrFrameTooLarge:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
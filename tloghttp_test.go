@@ -0,0 +1,169 @@
+package msign
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testLogServer(t *testing.T, key PrivateKey) (*Log, *httptest.Server) {
+	t.Helper()
+
+	l, err := NewLog("example.com/log", key, nil)
+	if err != nil {
+		t.Fatalf("NewLog() failed: %v", err)
+	}
+
+	srv := httptest.NewServer(NewLogServer(l))
+	t.Cleanup(srv.Close)
+
+	return l, srv
+}
+
+func TestLogClient(t *testing.T) {
+	ctx := context.Background()
+	key, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, srv := testLogServer(t, key)
+	c := NewLogClient(srv.URL+"/", pub)
+
+	leaves := testLeaves(t, len(testMerkleLeaves))[1:]
+	var checkpoints []*Checkpoint
+	for i, leaf := range leaves {
+		index, cp, err := c.Add(ctx, leaf)
+		if err != nil || index != uint64(i) || cp.Size != uint64(i+1) {
+			t.Fatalf("Add() failed: %d %v", index, err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+
+	latest, err := c.Checkpoint(ctx)
+	if err != nil || latest.Size != uint64(len(leaves)) {
+		t.Fatalf("Checkpoint() failed: %v", err)
+	}
+
+	for i, leaf := range leaves {
+		if err = c.VerifyInclusion(ctx, leaf, uint64(i), latest); err != nil {
+			t.Errorf("VerifyInclusion() of %d failed: %v", i, err)
+		}
+		if err = c.VerifyConsistency(ctx, checkpoints[i], latest); err != nil {
+			t.Errorf("VerifyConsistency() of %d failed: %v", checkpoints[i].Size, err)
+		}
+	}
+
+	if err = c.VerifyInclusion(ctx, []byte("never logged"), 0, latest); err != ErrInvalidProof {
+		t.Errorf("VerifyInclusion() of other entry failed: %v", err)
+	}
+	if err = c.VerifyInclusion(ctx, leaves[0], uint64(len(leaves)), latest); err != ErrLogIndex {
+		t.Errorf("VerifyInclusion() out of range failed: %v", err)
+	}
+
+	// bundles logged remotely verify offline
+	priv, signer, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	b, err := NewSigstoreBundle(strings.NewReader("artifact"), priv)
+	if err != nil {
+		t.Fatalf("NewSigstoreBundle() failed: %v", err)
+	}
+	if err = c.AddBundle(ctx, b); err != nil {
+		t.Fatalf("AddBundle() failed: %v", err)
+	}
+	if _, err = b.Verify(strings.NewReader("artifact"), Keyring{signer}, Keyring{pub}); err != nil {
+		t.Errorf("Verify() of logged bundle failed: %v", err)
+	}
+}
+
+func TestLogClient_Bad(t *testing.T) {
+	ctx := context.Background()
+	key, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	_, other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	l, srv := testLogServer(t, key)
+	c := NewLogClient(srv.URL, pub)
+
+	_, old, err := c.Add(ctx, []byte("first"))
+	if err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	// a log with the same key and another history is caught by consistency
+	_, forked := testLogServer(t, key)
+	fc := NewLogClient(forked.URL, pub)
+	for _, entry := range []string{"other", "second"} {
+		if _, _, err = fc.Add(ctx, []byte(entry)); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	newer, err := fc.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint() failed: %v", err)
+	}
+	if err = fc.VerifyConsistency(ctx, old, newer); err != ErrInvalidProof {
+		t.Errorf("VerifyConsistency() of forked log failed: %v", err)
+	}
+
+	// checkpoints of another log key are refused
+	_, err = NewLogClient(srv.URL, other).Checkpoint(ctx)
+	if err != ErrKeyNotFound {
+		t.Errorf("Checkpoint() with other key failed: %v", err)
+	}
+
+	// a server answering with a wrong proof
+	lying := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkpoint, _ := l.Checkpoint()
+		writeLogJSON(w, logAddResponse{Index: 0, Checkpoint: checkpoint, Hashes: nil})
+	}))
+	defer lying.Close()
+	_, _, err = NewLogClient(lying.URL, pub).Add(ctx, []byte("dropped"))
+	if err != ErrInvalidProof {
+		t.Errorf("Add() with wrong proof failed: %v", err)
+	}
+
+	inputs := map[string]int{
+		"/get-inclusion-proof?index=x&size=1":     http.StatusBadRequest,
+		"/get-inclusion-proof?index=5&size=1":     http.StatusNotFound,
+		"/get-consistency-proof?first=2&second=1": http.StatusNotFound,
+		"/get-consistency-proof?first=1":          http.StatusBadRequest,
+		"/add-entry":                              http.StatusMethodNotAllowed,
+	}
+	for path, want := range inputs {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get() failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s failed: %d", path, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+"/add-entry", "application/json", strings.NewReader(`{"entry":"!"}`))
+	if err != nil {
+		t.Fatalf("http.Post() failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /add-entry with invalid entry failed: %d", resp.StatusCode)
+	}
+
+	_, _, err = c.Add(ctx, make([]byte, MaxFrameSize+1))
+	if err != ErrFrameTooLarge {
+		t.Errorf("Add() of large entry failed: %v", err)
+	}
+
+	_, err = NewLogClient(srv.URL, nil).Checkpoint(ctx)
+	if err != ErrNilKey {
+		t.Errorf("Checkpoint() without key failed: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package msign
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"time"
+)
+
+// Update metadata, modeled on The Update Framework (TUF). Four roles sign
+// JSON metadata as DSSE envelopes of UpdatePayloadType, each with a threshold
+// of the keys root assigns to it:
+//
+//	root       keys and thresholds of every role, rotated by publishing N+1.root.json
+//	targets    length and SHA-256 of every target, e.g. a binary per platform
+//	snapshot   version of targets.json
+//	timestamp  version and SHA-256 of snapshot.json, short lived
+//
+// Every metadata has a version that may never decrease and an expiry after
+// which it is refused, so an attacker can neither roll clients back nor
+// freeze them on stale metadata
+
+const (
+	UpdatePayloadType = "application/vnd.msign.update+json"
+
+	RoleRoot      = "root"
+	RoleTargets   = "targets"
+	RoleSnapshot  = "snapshot"
+	RoleTimestamp = "timestamp"
+
+	maxUpdateMetadataSize = 1 << 20
+)
+
+var (
+	ErrInvalidUpdateMetadata = errors.New("invalid update metadata")
+	ErrUpdateExpired         = errors.New("update metadata expired")
+	ErrUpdateRollback        = errors.New("update metadata version rolled back")
+	ErrUpdateTargetNotFound  = errors.New("update target not found")
+	ErrInvalidUpdateTarget   = errors.New("update target does not match its metadata")
+)
+
+// UpdateMetadata is implemented by the metadata of every role
+type UpdateMetadata interface {
+	header() *UpdateHeader
+}
+
+// UpdateHeader is common to the metadata of every role, the type is set by SignUpdateMetadata
+type UpdateHeader struct {
+	Type    string    `json:"_type"`
+	Version uint64    `json:"version"`
+	Expires time.Time `json:"expires"`
+}
+
+type UpdateRole struct {
+	KeyIds    []string `json:"keyids"` // KeyId in hex
+	Threshold int      `json:"threshold"`
+}
+
+type RootMetadata struct {
+	UpdateHeader
+	Keys  map[string][]byte      `json:"keys"` // KeyId in hex to raw binary public key
+	Roles map[string]*UpdateRole `json:"roles"`
+}
+
+type TargetsMetadata struct {
+	UpdateHeader
+	Targets map[string]UpdateTarget `json:"targets"`
+}
+
+type UpdateTarget struct {
+	Length int64  `json:"length"`
+	SHA256 string `json:"sha256"` // hex
+}
+
+// SnapshotMetadata lists targets.json, TimestampMetadata lists snapshot.json
+type SnapshotMetadata struct {
+	UpdateHeader
+	Meta map[string]UpdateMetaFile `json:"meta"`
+}
+
+type TimestampMetadata struct {
+	UpdateHeader
+	Meta map[string]UpdateMetaFile `json:"meta"`
+}
+
+type UpdateMetaFile struct {
+	Version uint64 `json:"version"`
+	SHA256  string `json:"sha256,omitempty"` // hex
+}
+
+// AddKey adds pub to the keys of role, creating the role with threshold 1
+func (r *RootMetadata) AddKey(role string, pub PublicKey) {
+	if r.Keys == nil {
+		r.Keys = make(map[string][]byte)
+	}
+	if r.Roles == nil {
+		r.Roles = make(map[string]*UpdateRole)
+	}
+	if r.Roles[role] == nil {
+		r.Roles[role] = &UpdateRole{Threshold: 1}
+	}
+
+	id := pub.Id().String()
+	r.Keys[id] = pub.marshal()
+	r.Roles[role].KeyIds = append(r.Roles[role].KeyIds, id)
+}
+
+// SignUpdateMetadata sets the type of m and returns it as DSSE envelope
+// signed by every key
+func SignUpdateMetadata(m UpdateMetadata, keys ...PrivateKey) ([]byte, error) {
+	switch m.(type) {
+	case *RootMetadata:
+		m.header().Type = RoleRoot
+	case *TargetsMetadata:
+		m.header().Type = RoleTargets
+	case *SnapshotMetadata:
+		m.header().Type = RoleSnapshot
+	case *TimestampMetadata:
+		m.header().Type = RoleTimestamp
+	default:
+		return nil, ErrUnknownType
+	}
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := SignDSSE(UpdatePayloadType, payload, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(e)
+}
+
+// NewUpdateTarget returns the targets entry of the content of r
+func NewUpdateTarget(r io.Reader) (UpdateTarget, error) {
+	if r == nil {
+		return UpdateTarget{}, ErrNilReader
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return UpdateTarget{}, err
+	}
+
+	return UpdateTarget{Length: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// ParseRootMetadata decodes root metadata signed by a threshold of its own
+// root keys, e.g. the initial root shipped with a binary. Expiry is not checked
+func ParseRootMetadata(data []byte) (*RootMetadata, error) {
+	e, err := parseUpdateEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &RootMetadata{}
+	err = decodeUpdatePayload(e, RoleRoot, root)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := &RootMetadata{}
+	err = root.verifyRole(RoleRoot, data, verified)
+	if err != nil {
+		return nil, err
+	}
+
+	return verified, nil
+}
+
+// utility functions
+
+func (h *UpdateHeader) header() *UpdateHeader {
+	return h
+}
+
+// verifyRole decodes data into m once signed by a threshold of the keys of role
+func (r *RootMetadata) verifyRole(role string, data []byte, m UpdateMetadata) error {
+	keyring, threshold, err := r.roleKeys(role)
+	if err != nil {
+		return err
+	}
+
+	e, err := parseUpdateEnvelope(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.Verify(keyring, threshold)
+	if err != nil {
+		return err
+	}
+
+	return decodeUpdatePayload(e, role, m)
+}
+
+// roleKeys returns the keys and threshold of role, checking every role of
+// root is usable
+func (r *RootMetadata) roleKeys(role string) (Keyring, int, error) {
+	var keyring Keyring
+	for _, name := range []string{RoleRoot, RoleTargets, RoleSnapshot, RoleTimestamp} {
+		rr := r.Roles[name]
+		if rr == nil || rr.Threshold < 1 || rr.Threshold > len(rr.KeyIds) {
+			return nil, 0, ErrInvalidUpdateMetadata
+		}
+
+		// a key listed twice would count once toward the threshold
+		for i, id := range rr.KeyIds {
+			if slices.Contains(rr.KeyIds[:i], id) {
+				return nil, 0, ErrInvalidUpdateMetadata
+			}
+
+			pub, err := ParsePublicKey(r.Keys[id])
+			if err != nil || pub.Id().String() != id {
+				return nil, 0, ErrInvalidUpdateMetadata
+			}
+			if name == role {
+				keyring = append(keyring, pub)
+			}
+		}
+	}
+
+	if keyring == nil {
+		return nil, 0, ErrInvalidUpdateMetadata
+	}
+
+	return keyring, r.Roles[role].Threshold, nil
+}
+
+func parseUpdateEnvelope(data []byte) (*DSSEEnvelope, error) {
+	e := &DSSEEnvelope{}
+	err := json.Unmarshal(data, e)
+	if err != nil || e.PayloadType != UpdatePayloadType {
+		return nil, ErrInvalidUpdateMetadata
+	}
+
+	return e, nil
+}
+
+// decodeUpdatePayload decodes the payload of e into m, which must be of role
+func decodeUpdatePayload(e *DSSEEnvelope, role string, m UpdateMetadata) error {
+	err := json.Unmarshal(e.Payload, m)
+	if err != nil || m.header().Type != role {
+		return ErrInvalidUpdateMetadata
+	}
+
+	return nil
+}
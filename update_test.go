@@ -0,0 +1,132 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testUpdateRoot returns root metadata with a key per role and two root keys
+// of threshold 2, with the private keys by role
+func testUpdateRoot(t *testing.T) (*RootMetadata, map[string][]PrivateKey) {
+	root := &RootMetadata{UpdateHeader: UpdateHeader{Version: 1, Expires: time.Now().Add(365 * 24 * time.Hour)}}
+	keys := make(map[string][]PrivateKey)
+
+	for _, role := range []string{RoleRoot, RoleRoot, RoleTargets, RoleSnapshot, RoleTimestamp} {
+		priv, pub, err := NewPrivateKey()
+		if err != nil {
+			t.Fatalf("NewPrivateKey() failed: %v", err)
+		}
+		root.AddKey(role, pub)
+		keys[role] = append(keys[role], priv)
+	}
+	root.Roles[RoleRoot].Threshold = 2
+
+	return root, keys
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	root, keys := testUpdateRoot(t)
+
+	data, err := SignUpdateMetadata(root, keys[RoleRoot]...)
+	if err != nil {
+		t.Fatalf("SignUpdateMetadata() failed: %v", err)
+	}
+	if root.Type != RoleRoot {
+		t.Errorf("SignUpdateMetadata() failed by type: %s", root.Type)
+	}
+
+	got, err := ParseRootMetadata(data)
+	if err != nil {
+		t.Fatalf("ParseRootMetadata() failed: %v", err)
+	}
+	if got.Version != 1 || len(got.Keys) != 5 || got.Roles[RoleRoot].Threshold != 2 {
+		t.Errorf("ParseRootMetadata() failed: %+v", got)
+	}
+
+	target, err := NewUpdateTarget(strings.NewReader("binary"))
+	if d := sha256.Sum256([]byte("binary")); err != nil || target.Length != 6 || target.SHA256 != hex.EncodeToString(d[:]) {
+		t.Fatalf("NewUpdateTarget() failed: %v", err)
+	}
+	targets := &TargetsMetadata{UpdateHeader: UpdateHeader{Version: 3}, Targets: map[string]UpdateTarget{"tool": target}}
+	data, err = SignUpdateMetadata(targets, keys[RoleTargets]...)
+	if err != nil {
+		t.Fatalf("SignUpdateMetadata() failed: %v", err)
+	}
+
+	decoded := &TargetsMetadata{}
+	if err = got.verifyRole(RoleTargets, data, decoded); err != nil || decoded.Targets["tool"] != target {
+		t.Errorf("verifyRole() failed: %v", err)
+	}
+
+	// metadata of one role is refused for another, also when its keys sign it
+	if err = got.verifyRole(RoleSnapshot, data, &SnapshotMetadata{}); err != ErrThreshold {
+		t.Errorf("verifyRole() with other keys failed: %v", err)
+	}
+	data, _ = SignUpdateMetadata(targets, keys[RoleSnapshot]...)
+	if err = got.verifyRole(RoleSnapshot, data, &SnapshotMetadata{}); err != ErrInvalidUpdateMetadata {
+		t.Errorf("verifyRole() of other type failed: %v", err)
+	}
+}
+
+func TestUpdateMetadata_Bad(t *testing.T) {
+	root, keys := testUpdateRoot(t)
+
+	// below the root threshold
+	data, _ := SignUpdateMetadata(root, keys[RoleRoot][0])
+	if _, err := ParseRootMetadata(data); err != ErrThreshold {
+		t.Errorf("ParseRootMetadata() below threshold failed: %v", err)
+	}
+
+	// signed by the same key twice
+	data, _ = SignUpdateMetadata(root, keys[RoleRoot][0], keys[RoleRoot][0])
+	if _, err := ParseRootMetadata(data); err != ErrThreshold {
+		t.Errorf("ParseRootMetadata() signed twice failed: %v", err)
+	}
+
+	inputs := []struct {
+		name   string
+		modify func(r *RootMetadata)
+	}{
+		{"missing role", func(r *RootMetadata) { delete(r.Roles, RoleTimestamp) }},
+		{"zero threshold", func(r *RootMetadata) { r.Roles[RoleTargets].Threshold = 0 }},
+		{"threshold above keys", func(r *RootMetadata) { r.Roles[RoleRoot].Threshold = 3 }},
+		{"missing key", func(r *RootMetadata) { r.Roles[RoleSnapshot].KeyIds = []string{"00"} }},
+		{"duplicate key", func(r *RootMetadata) {
+			r.Roles[RoleRoot].KeyIds[1] = r.Roles[RoleRoot].KeyIds[0]
+		}},
+		{"key id mismatch", func(r *RootMetadata) {
+			r.Keys[r.Roles[RoleTargets].KeyIds[0]] = r.Keys[r.Roles[RoleSnapshot].KeyIds[0]]
+		}},
+	}
+
+	for _, input := range inputs {
+		r, keys := testUpdateRoot(t)
+		input.modify(r)
+		data, _ := SignUpdateMetadata(r, keys[RoleRoot]...)
+		if _, err := ParseRootMetadata(data); err != ErrInvalidUpdateMetadata {
+			t.Errorf("ParseRootMetadata() %s failed: %v", input.name, err)
+		}
+	}
+
+	e, _ := json.Marshal(&DSSEEnvelope{PayloadType: "application/json", Payload: []byte("{}")})
+	for _, input := range [][]byte{nil, []byte("{"), e} {
+		if _, err := ParseRootMetadata(input); err != ErrInvalidUpdateMetadata {
+			t.Errorf("ParseRootMetadata(%s) failed: %v", input, err)
+		}
+	}
+
+	if _, err := SignUpdateMetadata(&UpdateHeader{}, keys[RoleRoot]...); err != ErrUnknownType {
+		t.Errorf("SignUpdateMetadata() of header failed: %v", err)
+	}
+	if _, err := NewUpdateTarget(nil); err != ErrNilReader {
+		t.Errorf("NewUpdateTarget() of nil reader failed: %v", err)
+	}
+	if _, err := NewUpdateTarget(bytes.NewReader(nil)); err != nil {
+		t.Errorf("NewUpdateTarget() of empty reader failed: %v", err)
+	}
+}
@@ -0,0 +1,411 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Updater follows the TUF client workflow against a repository laid out as
+//
+//	1.root.json, 2.root.json, ...  every root version, N+1 signed by the root keys of N and its own
+//	timestamp.json
+//	snapshot.json
+//	targets.json
+//	targets/<name>
+//
+// Root is rotated first, then timestamp, snapshot and targets are refreshed
+// in turn, each checked against the one before, and finally the target is
+// downloaded, checked against targets.json and written over the executable
+// with a rename. StateDir keeps the rotated roots under the same names and
+// they are trusted only through the chain from Root
+
+const (
+	maxRootRotations = 1024
+
+	updateTimestampFile = "timestamp.json"
+	updateSnapshotFile  = "snapshot.json"
+	updateTargetsFile   = "targets.json"
+	updateRootFile      = "root.json"
+	updateTargetsDir    = "targets/"
+)
+
+type Updater struct {
+	Root       []byte          // initial trusted root metadata, e.g. embedded in the binary
+	Repository http.FileSystem // http.Dir for a local directory
+	StateDir   string          // trusted metadata between runs, empty keeps it in memory only
+	Target     string          // name of the target replacing Executable
+	Executable string          // empty uses the running executable
+
+	mu    sync.Mutex
+	state *updateState
+}
+
+// updateState is the trusted metadata, versions of later metadata may not be lower
+type updateState struct {
+	root      *RootMetadata
+	timestamp *TimestampMetadata
+	snapshot  *SnapshotMetadata
+	targets   *TargetsMetadata
+	files     map[string][]byte // signed metadata by file name
+}
+
+// Check refreshes the trusted metadata from the repository and returns the
+// target and whether it differs from the executable
+func (u *Updater) Check() (*UpdateTarget, bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	target, _, changed, err := u.check(time.Now())
+	return target, changed, err
+}
+
+// Update refreshes the trusted metadata and, once the target differs from the
+// executable, downloads and verifies it and atomically replaces the
+// executable. It reports whether the executable was replaced, the new
+// version runs from the next start
+func (u *Updater) Update() (bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	target, exe, changed, err := u.check(time.Now())
+	if err != nil || !changed {
+		return false, err
+	}
+
+	data, err := readRepositoryFile(u.Repository, updateTargetsDir+u.Target, target.Length, ErrInvalidUpdateTarget)
+	if err != nil {
+		return false, err
+	}
+
+	got, err := NewUpdateTarget(bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	if got != *target {
+		return false, ErrInvalidUpdateTarget
+	}
+
+	info, err := os.Stat(exe)
+	if err != nil {
+		return false, err
+	}
+
+	err = writeFileAtomic(exe, data, info.Mode().Perm())
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// utility functions
+
+// check refreshes the metadata and returns the target, the executable and
+// whether they differ
+func (u *Updater) check(now time.Time) (*UpdateTarget, string, bool, error) {
+	if !fs.ValidPath(u.Target) {
+		return nil, "", false, ErrUpdateTargetNotFound
+	}
+
+	targets, err := u.refresh(now)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	target, ok := targets.Targets[u.Target]
+	if !ok {
+		return nil, "", false, ErrUpdateTargetNotFound
+	}
+
+	exe := u.Executable
+	if exe == "" {
+		exe, err = os.Executable()
+		if err != nil {
+			return nil, "", false, err
+		}
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	f, err := os.Open(exe)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer f.Close()
+
+	current, err := NewUpdateTarget(f)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	return &target, exe, current != target, nil
+}
+
+// refresh updates the trusted metadata from the repository and returns the targets
+func (u *Updater) refresh(now time.Time) (*TargetsMetadata, error) {
+	s := u.state
+	if s == nil {
+		var err error
+		s, err = u.loadState()
+		if err != nil {
+			return nil, err
+		}
+		u.state = s
+	}
+
+	// root rotation, each rotated root is trusted even if a later step fails
+	for range maxRootRotations {
+		name := rootFileName(s.root.Version + 1)
+		data, err := readRepositoryFile(u.Repository, name, maxUpdateMetadataSize, ErrInvalidUpdateMetadata)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.rotateRoot(data)
+		if err != nil {
+			return nil, err
+		}
+		err = u.saveFile(name, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !now.Before(s.root.Expires) {
+		return nil, ErrUpdateExpired
+	}
+
+	files := make(map[string][]byte)
+
+	timestamp := &TimestampMetadata{}
+	err := u.fetchMetadata(s, RoleTimestamp, updateTimestampFile, UpdateMetaFile{}, timestamp, files, now)
+	if err != nil {
+		return nil, err
+	}
+	if s.timestamp != nil && timestamp.Version < s.timestamp.Version {
+		return nil, ErrUpdateRollback
+	}
+
+	snapshot := &SnapshotMetadata{}
+	err = u.fetchMetadata(s, RoleSnapshot, updateSnapshotFile, timestamp.Meta[updateSnapshotFile], snapshot, files, now)
+	if err != nil {
+		return nil, err
+	}
+	if s.snapshot != nil && snapshot.Version < s.snapshot.Version {
+		return nil, ErrUpdateRollback
+	}
+
+	targets := &TargetsMetadata{}
+	err = u.fetchMetadata(s, RoleTargets, updateTargetsFile, snapshot.Meta[updateTargetsFile], targets, files, now)
+	if err != nil {
+		return nil, err
+	}
+	if s.targets != nil && targets.Version < s.targets.Version {
+		return nil, ErrUpdateRollback
+	}
+
+	s.timestamp, s.snapshot, s.targets = timestamp, snapshot, targets
+	for name, data := range files {
+		s.files[name] = data
+	}
+
+	err = u.saveState(s, updateTimestampFile, updateSnapshotFile, updateTargetsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// fetchMetadata reads the metadata of role from the repository into m,
+// checking it against meta listed by the metadata before, and records it in files
+func (u *Updater) fetchMetadata(s *updateState, role, name string, meta UpdateMetaFile, m UpdateMetadata, files map[string][]byte, now time.Time) error {
+	if role != RoleTimestamp && meta.Version == 0 {
+		return ErrInvalidUpdateMetadata
+	}
+
+	data, err := readRepositoryFile(u.Repository, name, maxUpdateMetadataSize, ErrInvalidUpdateMetadata)
+	if err != nil {
+		return err
+	}
+
+	if meta.SHA256 != "" {
+		d := sha256.Sum256(data)
+		if hex.EncodeToString(d[:]) != meta.SHA256 {
+			return ErrInvalidUpdateMetadata
+		}
+	}
+
+	err = s.root.verifyRole(role, data, m)
+	if err != nil {
+		return err
+	}
+
+	h := m.header()
+	if role != RoleTimestamp && h.Version != meta.Version {
+		return ErrInvalidUpdateMetadata
+	}
+	if !now.Before(h.Expires) {
+		return ErrUpdateExpired
+	}
+
+	files[name] = data
+	return nil
+}
+
+// loadState returns the initial root rotated through the roots saved in
+// StateDir, and the trusted metadata of StateDir
+func (u *Updater) loadState() (*updateState, error) {
+	root, err := ParseRootMetadata(u.Root)
+	if err != nil {
+		return nil, err
+	}
+	s := &updateState{files: make(map[string][]byte)}
+	s.setRoot(root)
+
+	if u.StateDir == "" {
+		return s, nil
+	}
+
+	// a saved root is trusted only when signed by the one before, as in refresh
+	for range maxRootRotations {
+		data, err := os.ReadFile(filepath.Join(u.StateDir, rootFileName(s.root.Version+1)))
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.rotateRoot(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range []string{updateTimestampFile, updateSnapshotFile, updateTargetsFile} {
+		data, err := os.ReadFile(filepath.Join(u.StateDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if data != nil {
+			s.files[name] = data
+		}
+	}
+	s.reverify()
+
+	return s, nil
+}
+
+// saveState writes the named metadata files of s to StateDir
+func (u *Updater) saveState(s *updateState, names ...string) error {
+	for _, name := range names {
+		err := u.saveFile(name, s.files[name])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveFile writes data as name to StateDir
+func (u *Updater) saveFile(name string, data []byte) error {
+	if u.StateDir == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(u.StateDir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(u.StateDir, name), data, 0o644)
+}
+
+// rotateRoot trusts the root metadata data when signed by the current root
+// keys and its own, as the version following the current root
+func (s *updateState) rotateRoot(data []byte) error {
+	next := &RootMetadata{}
+	err := s.root.verifyRole(RoleRoot, data, next)
+	if err == nil {
+		_, err = ParseRootMetadata(data)
+	}
+	if err != nil {
+		return err
+	}
+	if next.Version != s.root.Version+1 {
+		return ErrInvalidUpdateMetadata
+	}
+
+	s.setRoot(next)
+	return nil
+}
+
+// setRoot trusts root and drops the metadata its keys no longer sign, so
+// rotating a compromised key also discards versions it may have inflated
+func (s *updateState) setRoot(root *RootMetadata) {
+	s.root = root
+	s.reverify()
+}
+
+// reverify decodes the trusted metadata with the current root
+func (s *updateState) reverify() {
+	s.timestamp, s.snapshot, s.targets = nil, nil, nil
+
+	timestamp := &TimestampMetadata{}
+	if s.root.verifyRole(RoleTimestamp, s.files[updateTimestampFile], timestamp) == nil {
+		s.timestamp = timestamp
+	}
+	snapshot := &SnapshotMetadata{}
+	if s.root.verifyRole(RoleSnapshot, s.files[updateSnapshotFile], snapshot) == nil {
+		s.snapshot = snapshot
+	}
+	targets := &TargetsMetadata{}
+	if s.root.verifyRole(RoleTargets, s.files[updateTargetsFile], targets) == nil {
+		s.targets = targets
+	}
+}
+
+// rootFileName is the file name of the root metadata version
+func rootFileName(version uint64) string {
+	return strconv.FormatUint(version, 10) + "." + updateRootFile
+}
+
+// readRepositoryFile reads name from fsys, failing with errTooLarge beyond max bytes
+func readRepositoryFile(fsys http.FileSystem, name string, max int64, errTooLarge error) ([]byte, error) {
+	if fsys == nil {
+		return nil, fs.ErrNotExist
+	}
+
+	f, err := fsys.Open("/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, errTooLarge
+	}
+
+	return data, nil
+}
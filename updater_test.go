@@ -0,0 +1,298 @@
+package msign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testUpdateRepository is a repository directory with its signing keys
+type testUpdateRepository struct {
+	t        *testing.T
+	dir      string
+	root     *RootMetadata
+	keys     map[string][]PrivateKey
+	versions map[string]uint64
+	expires  time.Time
+}
+
+func newTestUpdateRepository(t *testing.T) *testUpdateRepository {
+	root, keys := testUpdateRoot(t)
+	r := &testUpdateRepository{
+		t:        t,
+		dir:      t.TempDir(),
+		root:     root,
+		keys:     keys,
+		versions: make(map[string]uint64),
+		expires:  time.Now().Add(24 * time.Hour),
+	}
+
+	os.Mkdir(filepath.Join(r.dir, "targets"), 0o755)
+	r.write("1.root.json", root, keys[RoleRoot]...)
+	return r
+}
+
+// write signs m into the file name of the repository and returns its content
+func (r *testUpdateRepository) write(name string, m UpdateMetadata, keys ...PrivateKey) []byte {
+	data, err := SignUpdateMetadata(m, keys...)
+	if err != nil {
+		r.t.Fatalf("SignUpdateMetadata() failed: %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(r.dir, name), data, 0o644); err != nil {
+		r.t.Fatalf("WriteFile() failed: %v", err)
+	}
+	return data
+}
+
+// publish adds a target and new targets, snapshot and timestamp metadata
+func (r *testUpdateRepository) publish(name string, content []byte) {
+	target, _ := NewUpdateTarget(bytes.NewReader(content))
+	os.WriteFile(filepath.Join(r.dir, "targets", name), content, 0o644)
+
+	for _, role := range []string{RoleTargets, RoleSnapshot, RoleTimestamp} {
+		r.versions[role]++
+	}
+
+	header := func(role string) UpdateHeader {
+		return UpdateHeader{Version: r.versions[role], Expires: r.expires}
+	}
+	r.write("targets.json", &TargetsMetadata{UpdateHeader: header(RoleTargets), Targets: map[string]UpdateTarget{name: target}}, r.keys[RoleTargets]...)
+	snapshot := r.write("snapshot.json", &SnapshotMetadata{UpdateHeader: header(RoleSnapshot), Meta: map[string]UpdateMetaFile{
+		"targets.json": {Version: r.versions[RoleTargets]},
+	}}, r.keys[RoleSnapshot]...)
+	r.write("timestamp.json", &TimestampMetadata{UpdateHeader: header(RoleTimestamp), Meta: map[string]UpdateMetaFile{
+		"snapshot.json": {Version: r.versions[RoleSnapshot], SHA256: testSHA256(snapshot)},
+	}}, r.keys[RoleTimestamp]...)
+}
+
+// rotate replaces the keys of role by a new key in the next root version
+func (r *testUpdateRepository) rotate(role string) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		r.t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	old := r.keys[RoleRoot]
+	r.root.Version++
+	r.root.Roles[role] = nil
+	r.root.AddKey(role, pub)
+	r.keys[role] = []PrivateKey{priv}
+	if role == RoleRoot {
+		r.root.Roles[RoleRoot].Threshold = 1
+	}
+
+	r.write(strconv.FormatUint(r.root.Version, 10)+".root.json", r.root, append(old, r.keys[RoleRoot]...)...)
+}
+
+func (r *testUpdateRepository) read(name string) []byte {
+	data, err := os.ReadFile(filepath.Join(r.dir, name))
+	if err != nil {
+		r.t.Fatalf("ReadFile() failed: %v", err)
+	}
+	return data
+}
+
+func testSHA256(data []byte) string {
+	d := sha256.Sum256(data)
+	return hex.EncodeToString(d[:])
+}
+
+func TestUpdater(t *testing.T) {
+	repo := newTestUpdateRepository(t)
+	repo.publish("tool", []byte("tool v2"))
+
+	exe := filepath.Join(t.TempDir(), "tool")
+	os.WriteFile(exe, []byte("tool v1"), 0o755)
+
+	state := filepath.Join(t.TempDir(), "state")
+	u := &Updater{Root: repo.read("1.root.json"), Repository: http.Dir(repo.dir), StateDir: state, Target: "tool", Executable: exe}
+
+	target, changed, err := u.Check()
+	if err != nil || !changed || target.Length != 7 {
+		t.Fatalf("Check() failed: %v", err)
+	}
+
+	updated, err := u.Update()
+	if err != nil || !updated {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "tool v2" {
+		t.Errorf("Update() failed by executable: %s", data)
+	}
+	if info, _ := os.Stat(exe); info.Mode().Perm() != 0o755 {
+		t.Errorf("Update() failed by mode: %v", info.Mode())
+	}
+
+	if updated, err = u.Update(); err != nil || updated {
+		t.Errorf("Update() without new target failed: %v", err)
+	}
+
+	// a tampered target is refused and the executable kept
+	repo.publish("tool", []byte("tool v3"))
+	os.WriteFile(filepath.Join(repo.dir, "targets", "tool"), []byte("tool v4"), 0o644)
+	if _, err = u.Update(); err != ErrInvalidUpdateTarget {
+		t.Errorf("Update() of tampered target failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(repo.dir, "targets", "tool"), []byte("tool v3 and more"), 0o644)
+	if _, err = u.Update(); err != ErrInvalidUpdateTarget {
+		t.Errorf("Update() of long target failed: %v", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "tool v2" {
+		t.Errorf("Update() replaced the executable by a tampered target: %s", data)
+	}
+
+	// unknown target
+	u.Target = "other"
+	if _, err = u.Update(); err != ErrUpdateTargetNotFound {
+		t.Errorf("Update() of unknown target failed: %v", err)
+	}
+	u.Target = "../tool"
+	if _, err = u.Update(); err != ErrUpdateTargetNotFound {
+		t.Errorf("Update() of invalid target name failed: %v", err)
+	}
+}
+
+func TestUpdater_Rollback(t *testing.T) {
+	repo := newTestUpdateRepository(t)
+	repo.publish("tool", []byte("tool v2"))
+	old := map[string][]byte{}
+	for _, name := range []string{"timestamp.json", "snapshot.json", "targets.json"} {
+		old[name] = repo.read(name)
+	}
+	repo.publish("tool", []byte("tool v3"))
+
+	exe := filepath.Join(t.TempDir(), "tool")
+	os.WriteFile(exe, []byte("tool v1"), 0o755)
+	state := filepath.Join(t.TempDir(), "state")
+	newUpdater := func() *Updater {
+		return &Updater{Root: repo.read("1.root.json"), Repository: http.Dir(repo.dir), StateDir: state, Target: "tool", Executable: exe}
+	}
+
+	if _, err := newUpdater().Update(); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	// serving older metadata is refused, also by a later run
+	for name, data := range old {
+		os.WriteFile(filepath.Join(repo.dir, name), data, 0o644)
+	}
+	if _, err := newUpdater().Update(); err != ErrUpdateRollback {
+		t.Errorf("Update() of older metadata failed: %v", err)
+	}
+
+	// mixing a new timestamp with an older snapshot
+	repo.publish("tool", []byte("tool v4"))
+	os.WriteFile(filepath.Join(repo.dir, "snapshot.json"), old["snapshot.json"], 0o644)
+	if _, err := newUpdater().Update(); err != ErrInvalidUpdateMetadata {
+		t.Errorf("Update() of mixed metadata failed: %v", err)
+	}
+}
+
+func TestUpdater_Freeze(t *testing.T) {
+	repo := newTestUpdateRepository(t)
+	repo.publish("tool", []byte("tool v2"))
+
+	exe := filepath.Join(t.TempDir(), "tool")
+	os.WriteFile(exe, []byte("tool v1"), 0o755)
+	u := &Updater{Root: repo.read("1.root.json"), Repository: http.Dir(repo.dir), Target: "tool", Executable: exe}
+
+	if _, err := u.refresh(time.Now()); err != nil {
+		t.Fatalf("refresh() failed: %v", err)
+	}
+
+	// metadata stops being served fresh, e.g. by a mirror replaying it
+	if _, err := u.refresh(time.Now().Add(48 * time.Hour)); err != ErrUpdateExpired {
+		t.Errorf("refresh() of expired metadata failed: %v", err)
+	}
+	if _, err := u.refresh(time.Now().Add(2 * 365 * 24 * time.Hour)); err != ErrUpdateExpired {
+		t.Errorf("refresh() of expired root failed: %v", err)
+	}
+
+	repo.expires = time.Now().Add(-time.Minute)
+	repo.publish("tool", []byte("tool v3"))
+	if _, err := u.Update(); err != ErrUpdateExpired {
+		t.Errorf("Update() of expired metadata failed: %v", err)
+	}
+}
+
+func TestUpdater_KeyRotation(t *testing.T) {
+	repo := newTestUpdateRepository(t)
+	repo.publish("tool", []byte("tool v2"))
+	initial := repo.read("1.root.json")
+	oldTimestamp := repo.keys[RoleTimestamp]
+
+	exe := filepath.Join(t.TempDir(), "tool")
+	os.WriteFile(exe, []byte("tool v1"), 0o755)
+	state := filepath.Join(t.TempDir(), "state")
+	newUpdater := func() *Updater {
+		return &Updater{Root: initial, Repository: http.Dir(repo.dir), StateDir: state, Target: "tool", Executable: exe}
+	}
+
+	if _, err := newUpdater().Update(); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	// the timestamp key is rotated through root 2 and the root keys through root 3
+	repo.rotate(RoleTimestamp)
+	repo.rotate(RoleRoot)
+	repo.publish("tool", []byte("tool v3"))
+
+	u := newUpdater()
+	if updated, err := u.Update(); err != nil || !updated {
+		t.Fatalf("Update() after rotation failed: %v", err)
+	}
+	if u.state.root.Version != 3 {
+		t.Errorf("Update() failed by root version: %d", u.state.root.Version)
+	}
+	for _, name := range []string{"2.root.json", "3.root.json"} {
+		if saved, _ := os.ReadFile(filepath.Join(state, name)); string(saved) != string(repo.read(name)) {
+			t.Errorf("Update() failed to save the rotated root %s", name)
+		}
+	}
+
+	// a self-signed root planted in the state is trusted only through the chain
+	planted, plantedKeys := testUpdateRoot(t)
+	planted.Version = 4
+	data, _ := SignUpdateMetadata(planted, plantedKeys[RoleRoot]...)
+	os.WriteFile(filepath.Join(state, "root.json"), data, 0o644)
+	u = newUpdater()
+	if _, err := u.Update(); err != nil || u.state.root.Version != 3 {
+		t.Errorf("Update() with planted root.json failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(state, "4.root.json"), data, 0o644)
+	if _, err := newUpdater().Update(); err != ErrThreshold {
+		t.Errorf("Update() with planted 4.root.json failed: %v", err)
+	}
+	os.Remove(filepath.Join(state, "4.root.json"))
+
+	// the retired timestamp key no longer counts
+	repo.versions[RoleTimestamp]++
+	repo.write("timestamp.json", &TimestampMetadata{
+		UpdateHeader: UpdateHeader{Version: repo.versions[RoleTimestamp], Expires: repo.expires},
+		Meta:         map[string]UpdateMetaFile{"snapshot.json": {Version: repo.versions[RoleSnapshot]}},
+	}, oldTimestamp...)
+	if _, err := newUpdater().Update(); err != ErrThreshold {
+		t.Errorf("Update() with retired timestamp key failed: %v", err)
+	}
+
+	// a root not signed by the current root keys is refused
+	current := repo.keys[RoleRoot]
+	repo.keys[RoleRoot] = nil
+	repo.rotate(RoleRoot)
+	if _, err := newUpdater().Update(); err != ErrThreshold {
+		t.Errorf("Update() of unauthorized root failed: %v", err)
+	}
+
+	// so is a root claiming another version than its file name
+	repo.root.Version = 5
+	repo.write("4.root.json", repo.root, append(current, repo.keys[RoleRoot]...)...)
+	if _, err := newUpdater().Update(); err != ErrInvalidUpdateMetadata {
+		t.Errorf("Update() of misnumbered root failed: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package msign
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhooks signed with a private key, so receivers verify them with the
+// sender's public key instead of a shared secret. The signature travels in
+//
+//	Msign-Webhook-Signature: t=<unix>,sig=<base64url>
+//
+// where sig is the raw binary form of an msign signature over "<unix>.<body>"
+// prefixed with a fixed label, so no other signature of the key passes for it.
+// Receivers accept every sig entry, letting senders add one per key while
+// rotating keys
+
+const (
+	WebhookSignatureHeader = "Msign-Webhook-Signature"
+
+	DefaultWebhookTolerance = 5 * time.Minute
+
+	labelWebhook = "msign webhook v1\x00"
+)
+
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// WebhookVerifier accepts webhooks signed by Key within Tolerance of now
+type WebhookVerifier struct {
+	Key         PublicKey
+	Tolerance   time.Duration // accepted distance of t from now, zero uses DefaultWebhookTolerance
+	MaxBodySize int64         // zero uses DefaultMaxBodySize
+}
+
+type webhookBodyKey struct{}
+
+// SignWebhook returns the WebhookSignatureHeader value of body signed by key at t
+func SignWebhook(key PrivateKey, body []byte, t time.Time) (string, error) {
+	if key == nil {
+		return "", ErrNilKey
+	}
+
+	ts := strconv.FormatInt(t.Unix(), 10)
+	sig, err := key.Sign(bytes.NewReader(webhookMessage(ts, body)))
+	if err != nil {
+		return "", err
+	}
+
+	return "t=" + ts + ",sig=" + base64.RawURLEncoding.EncodeToString(sig.marshal()), nil
+}
+
+// NewWebhookRequest returns a POST request of body to url signed by key now
+func NewWebhookRequest(ctx context.Context, key PrivateKey, url, contentType string, body []byte) (*http.Request, error) {
+	header, err := SignWebhook(key, body, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(WebhookSignatureHeader, header)
+
+	return req, nil
+}
+
+// VerifyWebhook checks a WebhookSignatureHeader value of body with pub, t
+// must be within tolerance of now, zero uses DefaultWebhookTolerance
+func VerifyWebhook(header string, body []byte, pub PublicKey, tolerance time.Duration) error {
+	return verifyWebhook(header, body, pub, tolerance, time.Now())
+}
+
+// Handler returns middleware passing verified webhooks to next. The body of
+// the request is the verified one and is also available through WebhookBody.
+// Other requests are answered with 401 Unauthorized
+func (v *WebhookVerifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(r, v.MaxBodySize)
+		if err == ErrBodyTooLarge {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err == nil {
+			err = VerifyWebhook(r.Header.Get(WebhookSignatureHeader), body, v.Key, v.Tolerance)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), webhookBodyKey{}, body)))
+	})
+}
+
+// WebhookBody returns the verified body of the webhook of ctx, see WebhookVerifier.Handler
+func WebhookBody(ctx context.Context) []byte {
+	body, _ := ctx.Value(webhookBodyKey{}).([]byte)
+	return body
+}
+
+// utility functions
+
+func verifyWebhook(header string, body []byte, pub PublicKey, tolerance time.Duration, now time.Time) error {
+	if pub == nil {
+		return ErrNilKey
+	}
+
+	var ts string
+	var sigs [][]byte
+	for field := range strings.SplitSeq(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch {
+		case name == "t" && ts == "":
+			ts = value
+		case name == "sig":
+			raw, err := base64.RawURLEncoding.DecodeString(value)
+			if err != nil {
+				return ErrInvalidWebhookSignature
+			}
+			sigs = append(sigs, raw)
+		default:
+			return ErrInvalidWebhookSignature
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidWebhookSignature
+	}
+
+	if tolerance <= 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+	t := time.Unix(unix, 0)
+	if t.Before(now.Add(-tolerance)) || t.After(now.Add(tolerance)) {
+		return ErrRequestStale
+	}
+
+	err = ErrKeyNotFound
+	for _, raw := range sigs {
+		sig, decodeErr := decodeSignature(raw)
+		if decodeErr != nil {
+			return ErrInvalidWebhookSignature
+		}
+
+		_, err = Keyring{pub}.Verify(bytes.NewReader(webhookMessage(ts, body)), sig)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// webhookMessage is the message a webhook signature signs
+func webhookMessage(ts string, body []byte) []byte {
+	m := make([]byte, 0, len(labelWebhook)+len(ts)+1+len(body))
+	m = append(m, labelWebhook+ts+"."...)
+	return append(m, body...)
+}
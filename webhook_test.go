@@ -0,0 +1,147 @@
+package msign
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	priv, pub, err := NewPrivateKeyWithVersion(VersionFour)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+	}
+
+	v := &WebhookVerifier{Key: pub, MaxBodySize: 64}
+	srv := httptest.NewServer(v.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(WebhookBody(r.Context())) != string(body) {
+			t.Errorf("WebhookBody() failed: %s", WebhookBody(r.Context()))
+		}
+		io.WriteString(w, "received "+string(body))
+	})))
+	defer srv.Close()
+
+	req, err := NewWebhookRequest(context.Background(), priv, srv.URL+"/hooks", "application/json", []byte(`{"event":"paid"}`))
+	if err != nil {
+		t.Fatalf("NewWebhookRequest() failed: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `received {"event":"paid"}` {
+		t.Errorf("Do() failed: %d %s", resp.StatusCode, body)
+	}
+
+	// unsigned, tampered and oversized webhooks are refused
+	inputs := []struct {
+		name   string
+		header string
+		body   string
+		want   int
+	}{
+		{"unsigned", "", "{}", http.StatusUnauthorized},
+		{"tampered", req.Header.Get(WebhookSignatureHeader), `{"event":"refunded"}`, http.StatusUnauthorized},
+		{"large", req.Header.Get(WebhookSignatureHeader), strings.Repeat("x", 65), http.StatusRequestEntityTooLarge},
+	}
+	for _, input := range inputs {
+		r, _ := http.NewRequest(http.MethodPost, srv.URL+"/hooks", strings.NewReader(input.body))
+		r.Header.Set(WebhookSignatureHeader, input.header)
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("Do() failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != input.want {
+			t.Errorf("Do() %s failed: %d", input.name, resp.StatusCode)
+		}
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	priv, pub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+	old, oldPub, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey() failed: %v", err)
+	}
+
+	now := time.Now()
+	body := []byte(`{"event":"paid"}`)
+	header, err := SignWebhook(priv, body, now)
+	if err != nil {
+		t.Fatalf("SignWebhook() failed: %v", err)
+	}
+	if !strings.HasPrefix(header, "t="+strconv.FormatInt(now.Unix(), 10)+",sig=") {
+		t.Errorf("SignWebhook() failed by header: %s", header)
+	}
+
+	if err = VerifyWebhook(header, body, pub, 0); err != nil {
+		t.Errorf("VerifyWebhook() failed: %v", err)
+	}
+
+	// during rotation the sender signs with both keys
+	oldHeader, _ := SignWebhook(old, body, now)
+	_, oldSig, _ := strings.Cut(oldHeader, ",")
+	rotated := header + "," + oldSig
+	if err = verifyWebhook(rotated, body, pub, 0, now); err != nil {
+		t.Errorf("verifyWebhook() of new key failed: %v", err)
+	}
+	if err = verifyWebhook(rotated, body, oldPub, 0, now); err != nil {
+		t.Errorf("verifyWebhook() of old key failed: %v", err)
+	}
+
+	// a plain signature of "<unix>.<body>" is no webhook signature
+	ts := strconv.FormatInt(now.Unix(), 10)
+	plain, err := priv.Sign(strings.NewReader(ts + "." + string(body)))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	plainHeader := "t=" + ts + ",sig=" + base64.RawURLEncoding.EncodeToString(plain.marshal())
+
+	inputs := []struct {
+		name      string
+		header    string
+		body      []byte
+		pub       PublicKey
+		tolerance time.Duration
+		at        time.Time
+		want      error
+	}{
+		{"body", header, []byte(`{"event":"refunded"}`), pub, 0, now, ErrInvalidSignature},
+		{"timestamp", strings.Replace(header, "t=", "t=1", 1), body, pub, 0, now, ErrRequestStale},
+		{"other key", header, body, oldPub, 0, now, ErrKeyNotFound},
+		{"stale", header, body, pub, 0, now.Add(6 * time.Minute), ErrRequestStale},
+		{"future", header, body, pub, 0, now.Add(-6 * time.Minute), ErrRequestStale},
+		{"tolerance", header, body, pub, 10 * time.Minute, now.Add(6 * time.Minute), nil},
+		{"empty", "", body, pub, 0, now, ErrInvalidWebhookSignature},
+		{"no signature", strings.Split(header, ",")[0], body, pub, 0, now, ErrInvalidWebhookSignature},
+		{"no timestamp", strings.Split(header, ",")[1], body, pub, 0, now, ErrInvalidWebhookSignature},
+		{"twice timestamp", "t=1," + header, body, pub, 0, now, ErrInvalidWebhookSignature},
+		{"unknown field", header + ",v=1", body, pub, 0, now, ErrInvalidWebhookSignature},
+		{"bad encoding", header + "!", body, pub, 0, now, ErrInvalidWebhookSignature},
+		{"nil key", header, body, nil, 0, now, ErrNilKey},
+		{"unlabeled", plainHeader, body, pub, 0, now, ErrInvalidSignature},
+	}
+
+	for _, input := range inputs {
+		if err := verifyWebhook(input.header, input.body, input.pub, input.tolerance, input.at); err != input.want {
+			t.Errorf("verifyWebhook() %s failed: %v", input.name, err)
+		}
+	}
+
+	if _, err = SignWebhook(nil, body, now); err != ErrNilKey {
+		t.Errorf("SignWebhook() with nil key failed: %v", err)
+	}
+}
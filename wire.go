@@ -0,0 +1,152 @@
+package msign
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// frame tags, one per export prefix
+const (
+	FramePrivateKey  = 'K' // private key frame, see PrefixKEY
+	FramePublicKey   = 'P' // public key frame, see PrefixPUB
+	FrameSignature   = 'S' // signature frame, see PrefixSIG
+	FrameCertificate = 'C' // X.509 certificate frame, DER encoded, see PrefixCRT
+)
+
+const (
+	sizeFrameHeader = 1 + 4   // tag, big endian payload length
+	MaxFrameSize    = 1 << 16 // largest payload accepted by ReadFrame
+)
+
+var (
+	ErrInvalidFrame  = errors.New("invalid frame")
+	ErrFrameTooLarge = errors.New("frame too large")
+)
+
+// Marshal returns the raw binary form of a key, signature, timestamp token or
+// countersignature, the bytes behind the base64 of Export
+func Marshal(item any) ([]byte, error) {
+	switch i := item.(type) {
+	case PublicKey:
+		return i.marshal(), nil
+	case PrivateKey:
+		return i.marshal(), nil
+	case Signature:
+		return i.marshal(), nil
+	case *TimestampToken:
+		return i.marshal(), nil
+	case *Countersignature:
+		return i.marshal(), nil
+	case *SignedEnvelope:
+		return i.marshal(), nil
+	}
+
+	return nil, ErrUnknownType
+}
+
+// ParsePublicKey decodes the raw binary form of a public key
+func ParsePublicKey(data []byte) (PublicKey, error) {
+	return decodePublicKey(data)
+}
+
+// ParsePrivateKey decodes the raw binary form of a private key
+func ParsePrivateKey(data []byte) (PrivateKey, error) {
+	return decodePrivateKey(data)
+}
+
+// ParseSignature decodes the raw binary form of a signature
+func ParseSignature(data []byte) (Signature, error) {
+	return decodeSignature(data)
+}
+
+// WriteFrame writes a key, signature or certificate as one frame: a tag byte,
+// the big endian uint32 payload length and the raw binary form
+func WriteFrame(w io.Writer, item any) error {
+	if w == nil {
+		return ErrNilWriter
+	}
+
+	var (
+		tag     byte
+		payload []byte
+	)
+
+	switch i := item.(type) {
+	case PublicKey:
+		tag, payload = FramePublicKey, i.marshal()
+	case PrivateKey:
+		tag, payload = FramePrivateKey, i.marshal()
+	case Signature:
+		tag, payload = FrameSignature, i.marshal()
+	case *x509.Certificate:
+		tag, payload = FrameCertificate, i.Raw
+	default:
+		return ErrUnknownType
+	}
+
+	if len(payload) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+
+	frame := make([]byte, sizeFrameHeader+len(payload))
+	frame[0] = tag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	copy(frame[sizeFrameHeader:], payload)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadFrame reads the next frame written by WriteFrame, io.EOF is returned
+// only at a frame boundary, a truncated frame returns io.ErrUnexpectedEOF
+func ReadFrame(r io.Reader) (*Item, error) {
+	if r == nil {
+		return nil, ErrNilReader
+	}
+
+	var header [sizeFrameHeader]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+
+	payload := make([]byte, size)
+	_, err = io.ReadFull(r, payload)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item := &Item{Format: FormatBinary}
+
+	switch header[0] {
+	case FramePublicKey:
+		item.PublicKey, err = decodePublicKey(payload)
+	case FramePrivateKey:
+		item.PrivateKey, err = decodePrivateKey(payload)
+	case FrameSignature:
+		item.Signature, err = decodeSignature(payload)
+	case FrameCertificate:
+		item.Certificate, err = x509.ParseCertificate(payload)
+		if err != nil {
+			err = ErrInvalidCrtFormat
+		}
+	default:
+		err = ErrInvalidFrame
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
@@ -0,0 +1,150 @@
+package msign
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	inputs := []struct {
+		text  string
+		parse func([]byte) (any, error)
+	}{
+		{testPrivateKey, func(b []byte) (any, error) { return ParsePrivateKey(b) }},
+		{testPublicKey, func(b []byte) (any, error) { return ParsePublicKey(b) }},
+		{testSignature, func(b []byte) (any, error) { return ParseSignature(b) }},
+	}
+
+	for _, input := range inputs {
+		item, err := ImportAny(strings.NewReader(input.text))
+		if err != nil {
+			t.Fatalf("ImportAny() failed: %v", err)
+		}
+
+		raw, err := Marshal(item.Value())
+		if err != nil {
+			t.Fatalf("Marshal() failed: %v", err)
+		}
+
+		// the raw form is the payload of the text form
+		prefix, payload, _ := strings.Cut(strings.TrimSpace(input.text), ":")
+		if base64.RawURLEncoding.EncodeToString(raw) != payload {
+			t.Errorf("Marshal() %s: failed by text mismatch", prefix)
+		}
+
+		parsed, err := input.parse(raw)
+		if err != nil {
+			t.Fatalf("Parse() %s: failed: %v", prefix, err)
+		}
+
+		buf := new(bytes.Buffer)
+		if err = Export(buf, parsed); err != nil || buf.String() != input.text {
+			t.Errorf("Export() %s: failed by round trip: %v", prefix, err)
+		}
+	}
+
+	_, err := Marshal(nil)
+	if err != ErrUnknownType {
+		t.Errorf("Marshal() failed: %v", err)
+	}
+
+	_, err = ParseSignature(nil)
+	if err != ErrInvalidSigFormat {
+		t.Errorf("ParseSignature() failed: %v", err)
+	}
+}
+
+func TestFrame(t *testing.T) {
+	var items []any
+	for _, version := range testKeyVersions {
+		priv, pub, err := NewPrivateKeyWithVersion(version)
+		if err != nil {
+			t.Fatalf("NewPrivateKeyWithVersion() failed: %v", err)
+		}
+		sig, err := priv.SignWithOptions(strings.NewReader("Hello World!"), &SignOptions{EmbedFingerprint: true})
+		if err != nil {
+			t.Fatalf("SignWithOptions() failed: %v", err)
+		}
+		items = append(items, priv, pub, sig)
+	}
+	items = append(items, testCertificate(t))
+
+	buf := new(bytes.Buffer)
+	for _, item := range items {
+		if err := WriteFrame(buf, item); err != nil {
+			t.Fatalf("WriteFrame() failed: %v", err)
+		}
+	}
+
+	for i, want := range items {
+		item, err := ReadFrame(buf)
+		if err != nil {
+			t.Fatalf("ReadFrame() %d: failed: %v", i, err)
+		}
+		if item.Format != FormatBinary {
+			t.Errorf("ReadFrame() %d: failed by format: %v", i, item.Format)
+		}
+
+		var wantText, gotText bytes.Buffer
+		Export(&wantText, want)
+		Export(&gotText, item.Value())
+		if wantText.String() != gotText.String() {
+			t.Errorf("ReadFrame() %d: failed by text mismatch", i)
+		}
+	}
+
+	_, err := ReadFrame(buf)
+	if err != io.EOF {
+		t.Errorf("ReadFrame() at end failed: %v", err)
+	}
+}
+
+func TestFrame_Bad(t *testing.T) {
+	buf := new(bytes.Buffer)
+	pub, err := ImportPublicKey(strings.NewReader(testPublicKey))
+	if err != nil {
+		t.Fatalf("ImportPublicKey() failed: %v", err)
+	}
+	if err = WriteFrame(buf, pub); err != nil {
+		t.Fatalf("WriteFrame() failed: %v", err)
+	}
+	frame := buf.Bytes()
+
+	_, err = ReadFrame(bytes.NewReader(frame[:len(frame)-1]))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadFrame() truncated failed: %v", err)
+	}
+
+	_, err = ReadFrame(bytes.NewReader(append([]byte{'X'}, frame[1:]...)))
+	if err != ErrInvalidFrame {
+		t.Errorf("ReadFrame() unknown tag failed: %v", err)
+	}
+
+	_, err = ReadFrame(bytes.NewReader(append([]byte{FrameSignature}, frame[1:]...)))
+	if err != ErrInvalidSigFormat {
+		t.Errorf("ReadFrame() mismatched tag failed: %v", err)
+	}
+
+	_, err = ReadFrame(bytes.NewReader([]byte{FramePublicKey, 0xff, 0xff, 0xff, 0xff}))
+	if err != ErrFrameTooLarge {
+		t.Errorf("ReadFrame() oversized failed: %v", err)
+	}
+
+	_, err = ReadFrame(nil)
+	if err != ErrNilReader {
+		t.Errorf("ReadFrame() failed: %v", err)
+	}
+
+	err = WriteFrame(nil, pub)
+	if err != ErrNilWriter {
+		t.Errorf("WriteFrame() failed: %v", err)
+	}
+
+	err = WriteFrame(buf, "text")
+	if err != ErrUnknownType {
+		t.Errorf("WriteFrame() failed: %v", err)
+	}
+}